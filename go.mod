@@ -7,15 +7,16 @@ toolchain go1.24.5
 require (
 	github.com/ebfe/scard v0.0.0-20190212122703-c3d1b1916a95
 	github.com/gen2brain/beeep v0.0.0-20240516210008-9c006672e7f4
+	github.com/hajimehoshi/go-mp3 v0.3.4
 	github.com/micmonay/keybd_event v1.1.0
 	github.com/skratchdot/open-golang v0.0.0-20200116055534-eef842397966
+	github.com/yuin/gopher-lua v1.1.1
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/go-toast/toast v0.0.0-20190211030409-01e6764cf0a4 // indirect
 	github.com/godbus/dbus/v5 v5.1.0 // indirect
-	github.com/hajimehoshi/go-mp3 v0.3.4 // indirect
 	github.com/nu7hatch/gouuid v0.0.0-20131221200532-179d4d0c4d8d // indirect
 	github.com/tadvi/systray v0.0.0-20190226123456-11a2b8fa57af // indirect
 	golang.org/x/sys v0.34.0 // indirect
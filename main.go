@@ -5,16 +5,31 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+
+	"github.com/taglme/nfcuid/pkg/nfcuid"
 )
 
+// browserRetryDelay is the base delay in seconds between attempts to open the browser on startup
+const browserRetryDelay = 2
+
 func main() {
+	// With -stdio stdout carries the protocol, all console output goes to stderr, starting
+	// with the banner so the supervisor's stream only holds JSON lines
+	stdioOut := os.Stdout
+	if nfcuid.StdioFromArgs(os.Args[1:]) {
+		os.Stdout = os.Stderr
+	}
+
 	fmt.Println("NFC UID Reader - Enhanced Version")
-	fmt.Printf("Version: %s\n", Version)
+	fmt.Printf("Version: %s\n", nfcuid.Version)
 	fmt.Println("==================================")
 
+	// Track uptime and last scan/error from the very start
+	statusManager := nfcuid.NewStatusManager()
+
 	// Check for existing instances
-	singleInstance := NewSingleInstance("nfcuid")
-	globalSingleInstance = singleInstance  // Store globally for cleanup
+	singleInstance := nfcuid.NewSingleInstance("nfcuid")
+	nfcuid.RegisterSingleInstance(singleInstance) // Store globally for cleanup
 	
 	if !singleInstance.TryLock() {
 		// Check if another instance is actually running
@@ -39,22 +54,48 @@ func main() {
 	}
 
 	// Setup cleanup on exit
-	setupGracefulShutdown(singleInstance)
+	setupGracefulShutdown(statusManager)
 
 	fmt.Println("✓ Single instance lock acquired successfully")
 
 	// Load configuration
-	config, err := LoadConfig()
+	config, err := nfcuid.LoadConfig()
 	if err != nil {
-		SafeExit(1, fmt.Sprintf("Failed to load configuration: %v", err), nil)
+		nfcuid.SafeExit(1, fmt.Sprintf("Failed to load configuration: %v", err), nil)
+	}
+
+	// A relaunched process would lose the supervisor's pipes, so it restarts us instead
+	if config.Stdio {
+		config.Advanced.RestartMode = "supervised"
 	}
 
+	statusManager.SetScanHistory(config.Advanced.ScanHistorySize, config.Advanced.ScanHistoryMaskUIDs)
+	statusManager.SetStatusFile(config.Advanced.StatusFile)
+
+	logLevel, _ := nfcuid.StringToLogLevel(config.Advanced.LogLevel)
+	nfcuid.SetLogLevel(logLevel)
+	nfcuid.SetLogTimestampFormat(config.Advanced.LogTimestampFormat)
+	nfcuid.SetLogRateLimit(config.Advanced.LogRateLimit)
+	nfcuid.SetKeyboardLayout(config.NFC.KeyboardLayout)
+	nfcuid.SetCapsLockStrategy(config.NFC.CapsLockStrategy)
+
 	// Initialize notification manager
-	notificationManager := NewNotificationManager(config)
+	notificationManager := nfcuid.NewNotificationManager(config)
+
+	// Field commissioning check, exits with the result
+	if config.SelfTest {
+		nfcuid.RunSelfTest(config, notificationManager, statusManager)
+	}
+	if config.Benchmark > 0 {
+		nfcuid.RunBenchmark(config, notificationManager, statusManager)
+	}
+	if config.Once {
+		nfcuid.RunOnce(config, notificationManager, statusManager)
+	}
 
 	// Initialize update checker and check for updates if enabled
 	if config.Updates.Enabled && config.Updates.CheckOnStartup {
-		updateChecker := NewUpdateChecker(config, notificationManager)
+		updateChecker := nfcuid.NewUpdateChecker(config, notificationManager)
 		go func() {
 			// Run update check in background to avoid blocking startup
 			if err := updateChecker.PerformUpdateCheck(); err != nil {
@@ -64,19 +105,45 @@ func main() {
 	}
 
 	// Initialize audio manager
-	audioManager := NewAudioManager(config)
+	audioManager := nfcuid.NewAudioManager(config)
+
+	// Initialize event log
+	eventLogger, err := nfcuid.NewEventLogger(config)
+	if err != nil {
+		fmt.Printf("Warning: %v, continuing without event log\n", err)
+	}
+	nfcuid.RegisterShutdown("event log", nfcuid.ShutdownLogs, eventLogger.Close)
+	nfcuid.RegisterShutdown("status file", nfcuid.ShutdownLogs, func() error {
+		statusManager.SetStatusFile("") // Waits for a write in progress
+		return nil
+	})
+
+	// Initialize pipe output
+	pipeOutput, err := nfcuid.NewPipeOutput(config)
+	if err != nil {
+		fmt.Printf("Warning: %v, continuing without pipe output\n", err)
+	}
+	nfcuid.RegisterShutdown("pipe output", nfcuid.ShutdownIntegrations, pipeOutput.Close)
 
 	// Initialize restart manager
-	restartManager := NewRestartManager(config, notificationManager)
+	restartManager := nfcuid.NewRestartManager(config, notificationManager, eventLogger)
+	if !config.Advanced.SelfRestart {
+		fmt.Println("Self-restart is disabled, PC/SC failures are handled by auto-reconnect only")
+	}
 
 	// Initialize browser manager
-	var browserManager *BrowserManager
+	var browserManager *nfcuid.BrowserManager
 	if config.Web.OpenWebsite {
-		browserManager = NewBrowserManager(config.Web.Fullscreen)
+		browserManager = nfcuid.NewBrowserManager(config.Web.Fullscreen)
 
-		// Open browser window on startup
+		// Open browser window on startup, retrying since the browser may not be
+		// ready yet right after boot/login
 		fmt.Printf("Opening browser: %s\n", config.Web.WebsiteURL)
-		if err := browserManager.OpenURL(config.Web.WebsiteURL); err != nil {
+		browserRetryManager := nfcuid.NewRetryManager(config.Advanced.RetryAttempts, browserRetryDelay)
+		err := browserRetryManager.Retry(func() error {
+			return browserManager.OpenURL(config.Web.WebsiteURL)
+		})
+		if err != nil {
 			notificationManager.NotifyErrorThrottled("browser-error", fmt.Sprintf("Failed to open browser: %v", err))
 			fmt.Printf("Warning: Failed to open browser: %v\n", err)
 		}
@@ -86,23 +153,35 @@ func main() {
 	appFlags := config.ToFlags()
 
 	// Initialize and start the NFC service
-	service := NewService(appFlags, config, notificationManager, restartManager, audioManager)
+	service := nfcuid.NewService(appFlags, config, notificationManager, restartManager, audioManager, statusManager, eventLogger)
+	if pipeOutput != nil {
+		service.RegisterScanHandler(pipeOutput.HandleScan)
+	}
+	if config.Stdio {
+		go nfcuid.NewStdioServer(service, statusManager, stdioOut).Serve(os.Stdin)
+	}
 
 	fmt.Println("Starting NFC card reader service...")
-	notificationManager.NotifyInfo("NFC Lesegerät", "Service gestartet - bereit zum Kartenlesen")
+	if notificationManager.IsAutoRestart() {
+		// Don't re-announce the service on every self-restart
+		fmt.Println("Started by self-restart, skipping startup notification")
+	} else {
+		notificationManager.NotifyInfo("NFC Lesegerät", "Service gestartet - bereit zum Kartenlesen")
+	}
 
 	service.Start()
 }
 
 // setupGracefulShutdown sets up signal handlers for graceful shutdown
-func setupGracefulShutdown(singleInstance *SingleInstance) {
+func setupGracefulShutdown(statusManager *nfcuid.StatusManager) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	
 	go func() {
 		<-c
 		fmt.Println("\nReceived shutdown signal, cleaning up...")
-		singleInstance.Release()
-		os.Exit(0)
+		fmt.Println(statusManager.GetStatus().Summary())
+		nfcuid.Shutdown()
+		nfcuid.SafeExit(0, "", nil)
 	}()
 }
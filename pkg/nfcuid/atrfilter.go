@@ -0,0 +1,58 @@
+package nfcuid
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// errCardNotAllowed is returned for cards whose ATR matches none of nfc.allowed_atr_prefixes
+var errCardNotAllowed = errors.New("card not allowed")
+
+// ParseATRPrefix parses an ATR prefix written as hex, bytes may be separated by spaces
+// or colons, e.g. "3B 8F 80 01"
+func ParseATRPrefix(prefix string) ([]byte, error) {
+	cleaned := strings.NewReplacer(" ", "", ":", "").Replace(prefix)
+	if cleaned == "" {
+		return nil, fmt.Errorf("ATR prefix is empty")
+	}
+	atr, err := hex.DecodeString(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("ATR prefix %q is not hex: %v", prefix, err)
+	}
+	return atr, nil
+}
+
+// checkCardATR rejects the card unless its ATR starts with one of the allowed prefixes.
+// Without prefixes every card is allowed.
+func (s *service) checkCardATR(card cardHandle) error {
+	if len(s.flags.AllowedATRs) == 0 {
+		return nil
+	}
+
+	status, err := card.Status()
+	if err != nil {
+		return fmt.Errorf("failed to read card ATR: %v", err)
+	}
+	for _, prefix := range s.flags.AllowedATRs {
+		if bytes.HasPrefix(status.Atr, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: ATR % X", errCardNotAllowed, status.Atr)
+}
+
+// handleRejectedCard reports a card that isn't allowed and waits for it to be removed,
+// so it isn't rejected over and over while it rests on the reader. It only returns errors
+// that stop the reading loop.
+func (s *service) handleRejectedCard(ctx cardContext, selectedReaders []string, index int, err error) error {
+	log.Printf("Card rejected on %s: %v", selectedReaders[index], err)
+	s.notificationManager.NotifyErrorThrottled("card-rejected", "Karte nicht zugelassen")
+	s.recordError(selectedReaders[index], err.Error())
+	s.audioManager.PlayErrorSound()
+
+	return s.waitForCardRemoval(ctx, selectedReaders, index)
+}
@@ -0,0 +1,116 @@
+package nfcuid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestReadNextCardAllowedATR(t *testing.T) {
+	atr := []byte{0x3b, 0x8f, 0x80, 0x01, 0x80, 0x4f, 0x0c, 0xa0, 0x00, 0x00, 0x03, 0x06, 0x03}
+
+	tests := []struct {
+		prefixes []string
+		expected string
+		rejected bool
+		name     string
+	}{
+		{nil, "04a22b91", false, "no allowlist"},
+		{[]string{"3B 8F 80 01 80 4F 0C A0 00 00 03 06 03"}, "04a22b91", false, "full prefix"},
+		{[]string{"3b:8f", "3B 8F 80 01 80 4F 0C A0 00 00 03 06 11"}, "04a22b91", false, "any prefix matches"},
+		{[]string{"3B 8F 80 01 80 4F 0C A0 00 00 03 06 11"}, "", true, "other vendor rejected"},
+		{[]string{"3B 8F 80 01 80 4F 0C A0 00 00 03 06 03 00 01"}, "", true, "prefix longer than atr"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &mockContext{
+				readers: []string{"Reader 0"},
+				states: map[string][]scard.StateFlag{
+					"Reader 0": {scard.StatePresent, scard.StateEmpty},
+				},
+				card: &mockCard{atr: atr, responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+			}
+			config := DefaultConfig()
+			config.NFC.AllowedATRs = test.prefixes
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result := kb.text(); result != test.expected {
+				t.Errorf("Expected %q to be typed, got %q", test.expected, result)
+			}
+
+			status := s.statusManager.GetStatus()
+			if rejected := strings.Contains(status.LastErrorMessage, "card not allowed"); rejected != test.rejected {
+				t.Errorf("Expected rejected %v, got last error %q", test.rejected, status.LastErrorMessage)
+			}
+			if test.rejected {
+				if len(ctx.card.commands) != 0 {
+					t.Errorf("Expected no commands sent to a rejected card, got %x", ctx.card.commands)
+				}
+				if len(ctx.protocols) != 1 {
+					t.Errorf("Expected no protocol fallback for a rejected card, got %d connects", len(ctx.protocols))
+				}
+				if remaining := len(ctx.states["Reader 0"]); remaining != 0 {
+					t.Errorf("Expected to wait for the rejected card to be removed, %d states left", remaining)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateConfigAllowedATR(t *testing.T) {
+	tests := []struct {
+		prefixes []string
+		readAll  bool
+		valid    bool
+		name     string
+	}{
+		{[]string{"3B8F8001"}, false, true, "compact hex"},
+		{[]string{"3B 8F 80 01"}, false, true, "spaced hex"},
+		{[]string{"3B 8F 8"}, false, false, "odd digits"},
+		{[]string{"vendor"}, false, false, "not hex"},
+		{[]string{" "}, false, false, "empty prefix"},
+		{[]string{"3B8F"}, true, false, "combined with read all"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.AllowedATRs = test.prefixes
+			config.NFC.ReadAll.Enabled = test.readAll
+			if err := validateConfig(config); (err == nil) != test.valid {
+				t.Errorf("Expected valid %v, got error %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestRejectedCardShutdown(t *testing.T) {
+	ctx := &mockContext{readers: []string{"Reader 0"}}
+	s := newTestService(DefaultConfig())
+	rejected := fmt.Errorf("%w: ATR 3B 8F", errCardNotAllowed)
+
+	// A failed wait for the removal is reported and reading goes on
+	if err := s.handleRejectedCard(ctx, ctx.readers, 0, rejected); err != nil {
+		t.Errorf("Expected a failed release wait to be swallowed, got %v", err)
+	}
+
+	// A shutdown during the wait stops the reading loop
+	shutdown, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.shutdown = shutdown
+	if err := s.handleRejectedCard(ctx, ctx.readers, 0, rejected); !errors.Is(err, errShutdownRequested) {
+		t.Errorf("Expected errShutdownRequested, got %v", err)
+	}
+}
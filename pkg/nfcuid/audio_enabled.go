@@ -0,0 +1,7 @@
+//go:build !noaudio
+
+package nfcuid
+
+// audioSupported reports whether audio feedback is compiled in.
+// Build with -tags noaudio for headless servers without any audio stack.
+const audioSupported = true
@@ -0,0 +1,7 @@
+//go:build noaudio
+
+package nfcuid
+
+// audioSupported reports whether audio feedback is compiled in.
+// This build was made with -tags noaudio, so AudioManager is a no-op.
+const audioSupported = false
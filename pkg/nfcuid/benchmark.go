@@ -0,0 +1,196 @@
+package nfcuid
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// benchmarkCardTimeout is how long -benchmark waits for the next card
+const benchmarkCardTimeout = 5 * time.Minute
+
+// benchmarkStages are the measured steps of a scan once the card is detected
+var benchmarkStages = []string{"connect", "read", "format", "type"}
+
+// benchmarkBuckets are the upper bounds of the total latency histogram
+var benchmarkBuckets = []time.Duration{
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// benchmarkScan holds the stage durations of one scan, in benchmarkStages order
+type benchmarkScan []time.Duration
+
+// total is the latency from card detection to the last typed key
+func (scan benchmarkScan) total() time.Duration {
+	var total time.Duration
+	for _, d := range scan {
+		total += d
+	}
+	return total
+}
+
+// RunBenchmark runs the -benchmark command: it reads and types the configured number
+// of cards, prints the latency summary and exits
+func RunBenchmark(config *Config, notificationManager *NotificationManager, statusManager *StatusManager) {
+	config.Advanced.SelfRestart = false
+
+	service := NewService(config.ToFlags(), config, notificationManager, NewRestartManager(config, notificationManager, nil), NewAudioManager(config), statusManager, nil).(*service)
+
+	scardCtx, err := scard.EstablishContext()
+	if err != nil {
+		SafeExit(1, fmt.Sprintf("Benchmark failed: PC/SC context: %v", err), nil)
+	}
+	ctx := scardContext{scardCtx}
+	defer ctx.Release()
+
+	readers, err := ctx.ListReaders()
+	if err != nil || len(readers) == 0 {
+		SafeExit(1, fmt.Sprintf("Benchmark failed: no reader found (%v)", err), nil)
+	}
+	selectedReaders, err := service.selectReaders(readers)
+	if err != nil {
+		SafeExit(1, fmt.Sprintf("Benchmark failed: %v", err), nil)
+	}
+	kb, err := service.initKeyboard()
+	if err != nil {
+		SafeExit(1, fmt.Sprintf("Benchmark failed: %v", err), nil)
+	}
+
+	fmt.Printf("Benchmark: present %d cards, the output is typed into the focused window\n", config.Benchmark)
+	scans, err := service.benchmark(ctx, selectedReaders, kb, config.Benchmark)
+	printBenchmarkSummary(os.Stdout, scans)
+	if err != nil {
+		SafeExit(1, fmt.Sprintf("Benchmark stopped: %v", err), nil)
+	}
+	SafeExit(0, "", nil)
+}
+
+// benchmark times count scans from card detection to the last typed key. It reads a
+// single card per scan with the protocol negotiated by the reader, without read_all
+// or the protocol fallback. The scans measured so far are returned on error.
+func (s *service) benchmark(ctx cardContext, readers []string, kb keyboard, count int) ([]benchmarkScan, error) {
+	var scans []benchmarkScan
+	for len(scans) < count {
+		index, mute, err := s.waitForCardTimeout(ctx, readers, benchmarkCardTimeout)
+		if err != nil {
+			return scans, err
+		}
+		reader := readers[index]
+
+		if !mute {
+			scan, err := s.benchmarkScan(ctx, reader, kb)
+			if err != nil {
+				fmt.Printf("Scan failed, not counted: %v\n", err)
+			} else {
+				scans = append(scans, scan)
+				fmt.Printf("Scan %d/%d: %v\n", len(scans), count, scan.total().Round(time.Millisecond))
+			}
+		}
+
+		if err := s.waitUntilCardRelease(ctx, readers, index); err != nil {
+			return scans, err
+		}
+	}
+	return scans, nil
+}
+
+// benchmarkScan connects to, reads, formats and types the card present on the reader
+func (s *service) benchmarkScan(ctx cardContext, reader string, kb keyboard) (benchmarkScan, error) {
+	scan := make(benchmarkScan, 0, len(benchmarkStages))
+	start := time.Now()
+	lap := func() {
+		now := time.Now()
+		scan = append(scan, now.Sub(start))
+		start = now
+	}
+
+	card, err := s.connectShared(ctx, reader, scard.ProtocolAny)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to card: %v", err)
+	}
+	lap()
+
+	uid, err := s.readCardData(card)
+	card.Disconnect(scard.ResetCard)
+	if err != nil {
+		return nil, err
+	}
+	lap()
+
+	output := s.formatOutput(uid, reader)
+	lap()
+
+	if err := KeyboardWrite(output, kb, s.keyPacer); err != nil {
+		return nil, fmt.Errorf("failed to write keyboard output: %v", err)
+	}
+	lap()
+
+	return scan, nil
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of the sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// printBenchmarkSummary prints the latency percentiles per stage and a histogram of
+// the total latency
+func printBenchmarkSummary(out io.Writer, scans []benchmarkScan) {
+	fmt.Fprintf(out, "\nLatency over %d scans\n", len(scans))
+	if len(scans) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "%-8s %9s %9s %9s %9s\n", "stage", "p50", "p90", "p99", "max")
+	row := func(name string, durations []time.Duration) {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		fmt.Fprintf(out, "%-8s %9v %9v %9v %9v\n", name,
+			percentile(durations, 50).Round(10*time.Microsecond),
+			percentile(durations, 90).Round(10*time.Microsecond),
+			percentile(durations, 99).Round(10*time.Microsecond),
+			durations[len(durations)-1].Round(10*time.Microsecond))
+	}
+
+	totals := make([]time.Duration, len(scans))
+	for i, stage := range benchmarkStages {
+		durations := make([]time.Duration, len(scans))
+		for j, scan := range scans {
+			durations[j] = scan[i]
+			totals[j] = scan.total()
+		}
+		row(stage, durations)
+	}
+	row("total", totals)
+
+	// Histogram of the total latency, one # per scan
+	counts := make([]int, len(benchmarkBuckets)+1)
+	for _, total := range totals {
+		bucket := sort.Search(len(benchmarkBuckets), func(i int) bool { return total < benchmarkBuckets[i] })
+		counts[bucket]++
+	}
+	fmt.Fprintln(out, "\nTotal latency")
+	for i, count := range counts {
+		label := fmt.Sprintf(">=%v", benchmarkBuckets[len(benchmarkBuckets)-1])
+		if i < len(benchmarkBuckets) {
+			label = fmt.Sprintf("<%v", benchmarkBuckets[i])
+		}
+		fmt.Fprintf(out, "%8s %4d %s\n", label, count, strings.Repeat("#", count))
+	}
+}
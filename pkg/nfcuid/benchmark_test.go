@@ -0,0 +1,82 @@
+package nfcuid
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+func TestBenchmark(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states: map[string][]scard.StateFlag{
+			"Reader 0": {
+				scard.StatePresent, scard.StateEmpty,
+				scard.StatePresent, scard.StateEmpty, // Read fails, not counted
+				scard.StatePresent, scard.StateEmpty,
+			},
+		},
+		card: &mockCard{responses: [][]byte{
+			{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00},
+			{0x63, 0x00},
+			{0x04, 0x11, 0x22, 0x33, 0x90, 0x00},
+		}},
+	}
+	config := DefaultConfig()
+	config.NFC.EndChar = "enter"
+	config.Advanced.CardReadAttempts = 1
+	s := newTestService(config)
+
+	kb := &mockKeyboard{}
+	scans, err := s.benchmark(ctx, ctx.readers, kb, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(scans) != 2 {
+		t.Fatalf("Expected 2 counted scans, got %d", len(scans))
+	}
+	for i, scan := range scans {
+		if len(scan) != len(benchmarkStages) {
+			t.Errorf("Scan %d: expected %d stage durations, got %d", i, len(benchmarkStages), len(scan))
+		}
+	}
+	if result := kb.text(); result != "04a22b91\n04112233\n" {
+		t.Errorf("Expected both cards to be typed, got %q", result)
+	}
+
+	// Out of cards: the scans so far are returned with the error
+	scans, err = s.benchmark(ctx, ctx.readers, kb, 1)
+	if err == nil || len(scans) != 0 {
+		t.Errorf("Expected an error without scans, got %d scans and %v", len(scans), err)
+	}
+}
+
+func TestPrintBenchmarkSummary(t *testing.T) {
+	var scans []benchmarkScan
+	for i := 1; i <= 10; i++ {
+		ms := time.Duration(i) * time.Millisecond
+		scans = append(scans, benchmarkScan{ms, ms, 0, 10 * ms}) // Totals 12ms to 120ms
+	}
+
+	var out bytes.Buffer
+	printBenchmarkSummary(&out, scans)
+	summary := out.String()
+
+	for _, expected := range []string{
+		"Latency over 10 scans",
+		"connect        5ms       9ms      10ms      10ms",
+		"total         60ms     108ms     120ms     120ms",
+		"   <25ms    2 ##\n",
+		"   <50ms    2 ##\n",
+		"  <100ms    4 ####\n",
+		"  <250ms    2 ##\n",
+		"    >=1s    0 \n",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("Expected %q in the summary:\n%s", expected, summary)
+		}
+	}
+}
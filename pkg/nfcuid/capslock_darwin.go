@@ -1,17 +1,17 @@
-package main
+package nfcuid
 
-import (
-	"github.com/micmonay/keybd_event"
-)
+// capsLockDetectable reports whether IsCapsLockOn reads the real CAPS Lock state, it
+// always reports off on macOS
+const capsLockDetectable = false
 
 // CapsLockManager handles CAPS Lock state management during keyboard input (macOS stub)
 type CapsLockManager struct {
 	originalState bool
-	kb            keybd_event.KeyBonding
+	kb            keyboard
 }
 
 // NewCapsLockManager creates a new CAPS Lock manager
-func NewCapsLockManager(kb keybd_event.KeyBonding) *CapsLockManager {
+func NewCapsLockManager(kb keyboard) *CapsLockManager {
 	return &CapsLockManager{
 		kb: kb,
 	}
@@ -26,7 +26,7 @@ func (c *CapsLockManager) IsCapsLockOn() bool {
 
 // DisableCapsLock disables CAPS Lock and saves the original state
 func (c *CapsLockManager) DisableCapsLock() error {
-	c.originalState = c.IsCapsLockOn()
+	c.originalState = isCapsLockOn(c)
 	
 	if c.originalState {
 		// CAPS Lock is on, turn it off
@@ -41,7 +41,7 @@ func (c *CapsLockManager) DisableCapsLock() error {
 
 // RestoreCapsLock restores the original CAPS Lock state
 func (c *CapsLockManager) RestoreCapsLock() error {
-	currentState := c.IsCapsLockOn()
+	currentState := isCapsLockOn(c)
 	
 	// Only toggle if the current state differs from the original state
 	if currentState != c.originalState {
@@ -52,4 +52,4 @@ func (c *CapsLockManager) RestoreCapsLock() error {
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
@@ -1,4 +1,4 @@
-package main
+package nfcuid
 
 import (
 	"syscall"
@@ -11,14 +11,17 @@ var (
 	getKeyState = user32.NewProc("GetKeyState")
 )
 
+// capsLockDetectable reports whether IsCapsLockOn reads the real CAPS Lock state
+const capsLockDetectable = true
+
 // CapsLockManager handles CAPS Lock state management during keyboard input
 type CapsLockManager struct {
 	originalState bool
-	kb            keybd_event.KeyBonding
+	kb            keyboard
 }
 
 // NewCapsLockManager creates a new CAPS Lock manager
-func NewCapsLockManager(kb keybd_event.KeyBonding) *CapsLockManager {
+func NewCapsLockManager(kb keyboard) *CapsLockManager {
 	return &CapsLockManager{
 		kb: kb,
 	}
@@ -37,7 +40,7 @@ func (c *CapsLockManager) IsCapsLockOn() bool {
 
 // DisableCapsLock disables CAPS Lock and saves the original state
 func (c *CapsLockManager) DisableCapsLock() error {
-	c.originalState = c.IsCapsLockOn()
+	c.originalState = isCapsLockOn(c)
 	
 	if c.originalState {
 		// CAPS Lock is on, turn it off
@@ -52,7 +55,7 @@ func (c *CapsLockManager) DisableCapsLock() error {
 
 // RestoreCapsLock restores the original CAPS Lock state
 func (c *CapsLockManager) RestoreCapsLock() error {
-	currentState := c.IsCapsLockOn()
+	currentState := isCapsLockOn(c)
 
 	// Only toggle if the current state differs from the original state
 	if currentState != c.originalState {
@@ -1,4 +1,4 @@
-package main
+package nfcuid
 
 type CharFlag int
 
@@ -11,6 +11,9 @@ const (
 	CharFlagSemiColon
 	CharFlagColon
 	CharFlagComma
+	CharFlagBackspace
+	CharFlagSelectAll
+	CharFlagEscape
 )
 
 type CharFlagDef struct {
@@ -27,6 +30,9 @@ var charFlagStrings = map[CharFlag]CharFlagDef{
 	CharFlagSemiColon: CharFlagDef{"semicolon", ";"},
 	CharFlagColon:     CharFlagDef{"colon", ":"},
 	CharFlagComma:     CharFlagDef{"comma", ","},
+	CharFlagBackspace: CharFlagDef{"backspace", "\\b"},
+	CharFlagSelectAll: CharFlagDef{"select_all", "\\a"}, // Ctrl+A, Cmd+A on macOS
+	CharFlagEscape:    CharFlagDef{"escape", "\\e"},
 }
 
 func StringToCharFlag(s string) (CharFlag, bool) {
@@ -0,0 +1,1488 @@
+package nfcuid
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config represents the complete application configuration
+type Config struct {
+	NFC struct {
+		Device           int      `yaml:"device"`
+		Devices          []string `yaml:"devices"`
+		FallbackDevice   string   `yaml:"fallback_device"`
+		SwitchBack       bool     `yaml:"fallback_switch_back"`
+		WaitForReader    bool     `yaml:"wait_for_reader"`
+		CapsLock         bool     `yaml:"caps_lock"`
+		Reverse          bool     `yaml:"reverse"`
+		SwapNibbles      bool     `yaml:"swap_nibbles"`
+		Decimal          bool     `yaml:"decimal"`
+		DecimalPadding   int      `yaml:"decimal_padding"`
+		LegacyFormat     string   `yaml:"legacy_format"`
+		UIDBytes         string   `yaml:"uid_bytes"`
+		UIDByteRange     string   `yaml:"uid_byte_range"`
+		UIDPadBytes      int      `yaml:"uid_pad_bytes"`
+		MinUIDBytes      int      `yaml:"min_uid_bytes"`
+		EndChar          string   `yaml:"end_char"`
+		EndSequence      []string `yaml:"end_sequence"`
+		PostOutputClear  []string `yaml:"post_output_clear"`
+		OnReleaseKeys    []string `yaml:"on_release_keys"`
+		InChar           string   `yaml:"in_char"`
+		OutputFormat     string   `yaml:"output_format"`
+		OutputRegex      string   `yaml:"output_regex"`
+		OutputReplace    string   `yaml:"output_replace"`
+		Script           string   `yaml:"script"`
+		ScriptTimeoutMs  int      `yaml:"script_timeout_ms"`
+		PrefixFrom       string   `yaml:"prefix_from"`
+		Prefix           string   `yaml:"prefix"`
+		PrefixEnv        string   `yaml:"prefix_env"`
+		PrefixPattern    string   `yaml:"prefix_pattern"`
+		TypeProfile      string   `yaml:"type_profile"`
+		KeyboardLayout   string   `yaml:"keyboard_layout"`
+		CapsLockStrategy string   `yaml:"capslock_strategy"`
+		UnicodeInput     bool     `yaml:"windows_unicode_input"`
+		RequireTextFocus bool     `yaml:"require_text_focus"`
+		TextFocusWaitMs  int      `yaml:"text_focus_wait_ms"`
+		OnWriteError     string   `yaml:"on_write_error"`
+		Trigger          string   `yaml:"trigger"`
+		TriggerHotkey    string   `yaml:"trigger_hotkey"`
+		WaitForRelease   bool     `yaml:"wait_for_release"`
+		PresentBeep      bool     `yaml:"present_beep"`
+		StrictRelease    bool     `yaml:"strict_release"`
+		IgnoreFirstScan  bool     `yaml:"ignore_first_scan"`
+		StartupGraceMs   int      `yaml:"startup_grace_ms"`
+		ReemitIntervalMs int      `yaml:"reemit_interval_ms"`
+		DebounceMs       int      `yaml:"debounce_ms"`
+		IdleAlertMinutes int      `yaml:"idle_alert_minutes"`
+		AllowedATRs      []string `yaml:"allowed_atr_prefixes"`
+		SplitOutput      struct {
+			Enabled   bool   `yaml:"enabled"`
+			Parity    string `yaml:"parity"`
+			Separator string `yaml:"separator"`
+		} `yaml:"split_output"`
+		DuplicateOutput struct {
+			Enabled   bool   `yaml:"enabled"`
+			Separator string `yaml:"separator"`
+		} `yaml:"duplicate_output"`
+		ReadAll struct {
+			Enabled   bool   `yaml:"enabled"`
+			Separator string `yaml:"separator"`
+		} `yaml:"read_all"`
+		Sequence struct {
+			Enabled     bool   `yaml:"enabled"`
+			Width       int    `yaml:"width"`
+			PersistFile string `yaml:"persist_file"`
+		} `yaml:"sequence"`
+		RepeatAlert struct {
+			Enabled       bool `yaml:"enabled"`
+			Count         int  `yaml:"count"`
+			WindowSeconds int  `yaml:"window_seconds"`
+			BlockSeconds  int  `yaml:"block_seconds"`
+		} `yaml:"repeat_alert"`
+		Wiegand struct {
+			Enabled      bool `yaml:"enabled"`
+			FacilityCode int  `yaml:"facility_code"`
+			FacilityBits int  `yaml:"facility_bits"`
+			CardBits     int  `yaml:"card_bits"`
+		} `yaml:"wiegand"`
+		ReaderProfiles map[string]ReaderProfile `yaml:"reader_profiles"`
+		ReadMode       string                   `yaml:"read_mode"`
+		TagStandard    string                   `yaml:"tag_standard"`
+		WarmupRead     bool                     `yaml:"warmup_read"`
+		MifareBlock    struct {
+			Block   int    `yaml:"block"`
+			KeyType string `yaml:"key_type"`
+			Key     string `yaml:"key"`
+		} `yaml:"mifare_block"`
+	} `yaml:"nfc"`
+	Web struct {
+		OpenWebsite bool   `yaml:"open_website"`
+		WebsiteURL  string `yaml:"website_url"`
+		Fullscreen  bool   `yaml:"fullscreen"`
+	} `yaml:"web"`
+	Notifications struct {
+		Enabled         bool                  `yaml:"enabled"`
+		ShowSuccess     bool                  `yaml:"show_success"`
+		ShowErrors      bool                  `yaml:"show_errors"`
+		RestartCooldown int                   `yaml:"restart_cooldown"`
+		MaxSendFailures int                   `yaml:"max_send_failures"`
+		ThrottleJitter  int                   `yaml:"throttle_jitter"`
+		Categories      map[string]bool       `yaml:"categories"`
+		Templates       NotificationTemplates `yaml:"templates"`
+	} `yaml:"notifications"`
+	Audio struct {
+		Enabled      bool   `yaml:"enabled"`
+		SuccessSound string `yaml:"success_sound"`
+		ErrorSound   string `yaml:"error_sound"`
+		Volume       int    `yaml:"volume"`
+		PCSpeaker    bool   `yaml:"pc_speaker"`
+	} `yaml:"audio"`
+	Advanced struct {
+		RetryAttempts        int    `yaml:"retry_attempts"`
+		ReconnectDelay       int    `yaml:"reconnect_delay"`
+		MaxReconnectDelay    int    `yaml:"max_reconnect_delay"`
+		OutageAlertAfter     int    `yaml:"outage_alert_after"`
+		WatchdogSeconds      int    `yaml:"watchdog_seconds"`
+		AutoReconnect        bool   `yaml:"auto_reconnect"`
+		SelfRestart          bool   `yaml:"self_restart"`
+		RestartPcscd         bool   `yaml:"restart_pcscd"`
+		PcscdCommand         string `yaml:"pcscd_restart_command"`
+		MaxContextFailures   int    `yaml:"max_context_failures"`
+		RestartDelay         int    `yaml:"restart_delay"`
+		RestartMode          string `yaml:"restart_mode"`
+		SupervisedExit       int    `yaml:"supervised_exit_code"`
+		LogLevel             string `yaml:"log_level"`
+		LogTimestampFormat   string `yaml:"log_timestamp_format"`
+		LogRateLimit         int    `yaml:"log_rate_limit"`
+		ConsoleVerboseScan   bool   `yaml:"console_verbose_scan"`
+		CardReadAttempts     int    `yaml:"card_read_attempts"`
+		CardReadDelayMs      int    `yaml:"card_read_delay_ms"`
+		KeyboardInitAttempts int    `yaml:"keyboard_init_attempts"`
+		KeyboardInitDelayMs  int    `yaml:"keyboard_init_delay_ms"`
+		ScanHistorySize      int    `yaml:"scan_history_size"`
+		ScanHistoryMaskUIDs  bool   `yaml:"scan_history_mask_uids"`
+		StatusFile           string `yaml:"status_file"`
+	} `yaml:"advanced"`
+	Updates struct {
+		Enabled            bool `yaml:"enabled"`
+		CheckOnStartup     bool `yaml:"check_on_startup"`
+		AutoDownload       bool `yaml:"auto_download"`
+		AutoInstall        bool `yaml:"auto_install"`
+		CheckIntervalHours int  `yaml:"check_interval_hours"`
+	} `yaml:"updates"`
+	EventLog struct {
+		Enabled bool   `yaml:"enabled"`
+		Path    string `yaml:"path"`
+	} `yaml:"event_log"`
+	Pipe struct {
+		Enabled bool   `yaml:"enabled"`
+		Path    string `yaml:"path"`
+	} `yaml:"pipe"`
+
+	// ConfigPath is the file the configuration was loaded from, set with the -config flag
+	ConfigPath string `yaml:"-"`
+
+	// Profile is the entry of the profiles map applied over the config file, set with the -profile flag
+	Profile string `yaml:"-"`
+
+	// AutoRestart is set from the internal --auto-restart flag, never from the config file
+	AutoRestart bool `yaml:"-"`
+
+	// SelfTest and SelfTestTimeout are set from the -selftest flags, never from the config file
+	SelfTest        bool `yaml:"-"`
+	SelfTestTimeout int  `yaml:"-"`
+
+	// Benchmark is set from the -benchmark flag, never from the config file
+	Benchmark int `yaml:"-"`
+
+	// Once, OnceTimeout and OnceType are set from the -once flags, never from the config file
+	Once        bool `yaml:"-"`
+	OnceTimeout int  `yaml:"-"`
+	OnceType    bool `yaml:"-"`
+
+	// Stdio is set from the -stdio flag, never from the config file
+	Stdio bool `yaml:"-"`
+
+	// Delimiter is set from the -delimiter flag for a one-off run, never from the config file
+	Delimiter string `yaml:"-"`
+
+	// SitePrefix is resolved from nfc.prefix_from once the configuration is loaded
+	SitePrefix string `yaml:"-"`
+}
+
+// NotificationTemplates customizes the notification texts. Placeholders: {uid} (success),
+// {device} (reader name), {message} (error and info text), {title} (info title).
+// Empty templates use the built-in texts.
+type NotificationTemplates struct {
+	SuccessTitle string `yaml:"success_title"`
+	SuccessBody  string `yaml:"success_body"`
+	ErrorTitle   string `yaml:"error_title"`
+	ErrorBody    string `yaml:"error_body"`
+	InfoTitle    string `yaml:"info_title"`
+	InfoBody     string `yaml:"info_body"`
+}
+
+// ReaderProfile overrides the output settings for one reader, selected by
+// device number or (part of) the reader name. Empty fields use the global nfc settings.
+type ReaderProfile struct {
+	EndChar       string   `yaml:"end_char,omitempty"`
+	InChar        string   `yaml:"in_char,omitempty"`
+	Prefix        string   `yaml:"prefix,omitempty"`
+	Decimal       *bool    `yaml:"decimal,omitempty"`
+	OnReleaseKeys []string `yaml:"on_release_keys,omitempty"`
+}
+
+// DefaultConfig returns a configuration with sensible defaults
+func DefaultConfig() *Config {
+	config := &Config{}
+
+	// NFC defaults
+	config.NFC.Device = 0
+	config.NFC.CapsLock = false
+	config.NFC.Reverse = false
+	config.NFC.SwapNibbles = false
+	config.NFC.Decimal = false
+	config.NFC.DecimalPadding = 0
+	config.NFC.LegacyFormat = "" // Use the individual format settings
+	config.NFC.UIDBytes = "all"  // Format the whole UID, whatever its length
+	config.NFC.UIDByteRange = "" // No range, uid_bytes applies
+	config.NFC.UIDPadBytes = 0
+	config.NFC.MinUIDBytes = defaultMinUIDBytes
+	config.NFC.EndChar = "none"
+	config.NFC.InChar = "none"
+	config.NFC.OutputFormat = "{uid}"
+	config.NFC.OutputRegex = "" // Type the output unchanged
+	config.NFC.OutputReplace = ""
+	config.NFC.Script = "" // No Lua script
+	config.NFC.ScriptTimeoutMs = 100
+	config.NFC.PrefixFrom = "static" // Type nfc.prefix, empty for no site prefix
+	config.NFC.Prefix = ""
+	config.NFC.PrefixEnv = "NFCUID_SITE"
+	config.NFC.PrefixPattern = ""
+	config.NFC.TypeProfile = "instant"
+	config.NFC.KeyboardLayout = "us"
+	config.NFC.CapsLockStrategy = "toggle" // Switch CAPS Lock off while typing
+	config.NFC.UnicodeInput = false
+	config.NFC.FallbackDevice = "" // No standby reader
+	config.NFC.SwitchBack = true
+	config.NFC.WaitForReader = false // A missing reader fails the service loop
+	config.NFC.RequireTextFocus = false
+	config.NFC.TextFocusWaitMs = 2000
+	config.NFC.OnWriteError = "keep"
+	config.NFC.Trigger = "auto" // Read every presented card
+	config.NFC.TriggerHotkey = "ctrl+alt+n"
+	config.NFC.WaitForRelease = true
+	config.NFC.PresentBeep = false
+	config.NFC.StrictRelease = false
+	config.NFC.IgnoreFirstScan = false
+	config.NFC.StartupGraceMs = 0
+	config.NFC.ReemitIntervalMs = 0 // Type the output once per card
+	config.NFC.DebounceMs = 1500
+	config.NFC.IdleAlertMinutes = 0 // No alert for readers without card activity
+	config.NFC.SplitOutput.Enabled = false
+	config.NFC.SplitOutput.Parity = "even"
+	config.NFC.SplitOutput.Separator = "tab"
+	config.NFC.DuplicateOutput.Enabled = false
+	config.NFC.DuplicateOutput.Separator = "tab"
+	config.NFC.ReadAll.Enabled = false
+	config.NFC.ReadAll.Separator = "enter"
+	config.NFC.Sequence.Enabled = false
+	config.NFC.Sequence.Width = 4
+	config.NFC.Sequence.PersistFile = "" // Count from 1 every session
+	config.NFC.RepeatAlert.Enabled = false
+	config.NFC.RepeatAlert.Count = 3
+	config.NFC.RepeatAlert.WindowSeconds = 60
+	config.NFC.RepeatAlert.BlockSeconds = 0 // Only alert, keep typing the card
+	config.NFC.Wiegand.Enabled = false
+	config.NFC.Wiegand.FacilityCode = 0
+	config.NFC.Wiegand.FacilityBits = 8 // Standard 26-bit format: 8 bit facility, 16 bit card number
+	config.NFC.Wiegand.CardBits = 16
+	config.NFC.ReadMode = "uid"
+	config.NFC.TagStandard = "14443"
+	config.NFC.WarmupRead = false // Readers answer the first command after connecting
+	config.NFC.MifareBlock.Block = 4
+	config.NFC.MifareBlock.KeyType = "A"
+	config.NFC.MifareBlock.Key = "FFFFFFFFFFFF" // Transport key of blank Mifare Classic cards
+
+	// Web defaults
+	config.Web.OpenWebsite = false
+	config.Web.WebsiteURL = "https://example.com"
+	config.Web.Fullscreen = true
+
+	// Notification defaults
+	config.Notifications.Enabled = true
+	config.Notifications.ShowSuccess = true
+	config.Notifications.ShowErrors = true
+	config.Notifications.RestartCooldown = 60 // Seconds to hold back error notifications after a self-restart
+	config.Notifications.MaxSendFailures = 3  // Suspend desktop notifications after 3 failed deliveries in a row
+	config.Notifications.ThrottleJitter = 0   // Seconds of random delay added to repeated error notifications
+
+	// Advanced defaults
+	config.Advanced.RetryAttempts = 3
+	config.Advanced.ReconnectDelay = 2
+	config.Advanced.MaxReconnectDelay = 60 // Reconnect delay doubles per failed attempt up to this cap
+	config.Advanced.OutageAlertAfter = 300 // Report an outage once the reader has been unavailable for 5 minutes
+	config.Advanced.WatchdogSeconds = 0    // No watchdog for the card reading loop
+	config.Advanced.AutoReconnect = true
+	config.Advanced.SelfRestart = true
+	config.Advanced.RestartPcscd = false // Needs privileges, so opt-in
+	config.Advanced.PcscdCommand = "systemctl restart pcscd"
+	config.Advanced.MaxContextFailures = 5
+	config.Advanced.RestartDelay = 10
+	config.Advanced.RestartMode = "self" // Start the new process ourselves
+	config.Advanced.SupervisedExit = 75  // EX_TEMPFAIL, distinct from the exit code 1 of errors
+	config.Advanced.LogLevel = "info"
+	config.Advanced.LogTimestampFormat = "" // Go's default log timestamp
+	config.Advanced.LogRateLimit = 10       // Identical log messages per second before they are collapsed
+	config.Advanced.ConsoleVerboseScan = true
+	config.Advanced.CardReadAttempts = 2 // Card reads retry fast, a failed read is usually just a short tap
+	config.Advanced.CardReadDelayMs = 200
+	config.Advanced.KeyboardInitAttempts = 3 // The virtual keyboard may not be usable right after login
+	config.Advanced.KeyboardInitDelayMs = 500
+	config.Advanced.ScanHistorySize = defaultScanHistorySize
+	config.Advanced.ScanHistoryMaskUIDs = false
+	config.Advanced.StatusFile = "" // No status file unless configured
+
+	// Audio defaults
+	config.Audio.Enabled = true
+	config.Audio.SuccessSound = "beep" // Built-in beep sound
+	config.Audio.ErrorSound = "error"  // Built-in error sound
+	config.Audio.Volume = 70           // 70% volume
+	config.Audio.PCSpeaker = false
+
+	// Update checker defaults
+	config.Updates.Enabled = true
+	config.Updates.CheckOnStartup = true
+	config.Updates.AutoDownload = true
+	config.Updates.AutoInstall = false     // Safer default - require manual install
+	config.Updates.CheckIntervalHours = 24 // Check once per day
+
+	// Event log defaults
+	config.EventLog.Enabled = false
+	config.EventLog.Path = "events.jsonl"
+	config.Pipe.Enabled = false
+	config.Pipe.Path = defaultPipePath()
+
+	return config
+}
+
+// LoadConfig loads configuration from YAML file with fallback to command-line flags
+func LoadConfig() (*Config, error) {
+	config := DefaultConfig()
+
+	// Load from the -config path, or config.yaml in the working directory
+	configPath, explicit := configPathFromArgs(os.Args[1:])
+	config.ConfigPath = configPath
+	config.Profile, _ = flagValueFromArgs(os.Args[1:], "profile")
+	var missingConfigErr error
+	if _, err := os.Stat(configPath); err == nil {
+		fmt.Printf("Loading configuration from %s\n", configPath)
+		if err := loadConfigFromFile(config, configPath, config.Profile); err != nil {
+			return nil, fmt.Errorf("failed to load config file: %v", err)
+		}
+	} else if explicit {
+		// Reported after the flags are handled, so -init-config can create the file
+		missingConfigErr = fmt.Errorf("config file %s not found", configPath)
+	} else if config.Profile != "" {
+		missingConfigErr = fmt.Errorf("profile %q selected, but no config file found at %s", config.Profile, configPath)
+	} else {
+		fmt.Println("No config.yaml found, using defaults and command-line flags")
+	}
+
+	// Override with command-line flags if provided
+	printFormat := overrideWithFlags(config)
+	if missingConfigErr != nil {
+		return nil, missingConfigErr
+	}
+
+	// Validate configuration
+	if err := validateConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	// Handle print-config flag now that defaults, file and flags are all applied
+	if printFormat != "" {
+		output, err := MarshalConfig(config, printFormat)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println(output)
+		SafeExit(0, "", nil)
+	}
+
+	// Resolve the site prefix once, the hostname and environment don't change while running
+	sitePrefix, err := ResolveSitePrefix(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve site prefix: %v", err)
+	}
+	config.SitePrefix = sitePrefix
+
+	return config, nil
+}
+
+// configPathFromArgs returns the config file path given with -config (or --config) and
+// whether it was given at all. The path is needed before the flags are parsed, since the
+// file provides the flag defaults.
+func configPathFromArgs(args []string) (string, bool) {
+	if path, ok := flagValueFromArgs(args, "config"); ok {
+		return path, true
+	}
+	return "config.yaml", false
+}
+
+// flagValueFromArgs returns the value of a string flag from the raw command line, for
+// the flags that decide how the config file is read before the flags are parsed
+func flagValueFromArgs(args []string, flagName string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name == arg {
+			continue
+		}
+		if name == flagName && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if value, found := strings.CutPrefix(name, flagName+"="); found {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// StdioFromArgs reports whether -stdio is on the raw command line, so main can move the
+// console output to stderr before anything is printed and stdout only carries JSON lines
+func StdioFromArgs(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name == arg {
+			continue
+		}
+		if name == "stdio" {
+			return true
+		}
+		if value, found := strings.CutPrefix(name, "stdio="); found {
+			enabled, _ := strconv.ParseBool(value)
+			return enabled
+		}
+	}
+	return false
+}
+
+// MarshalConfig renders the configuration as "yaml" or "json" using the config file key names
+func MarshalConfig(config *Config, format string) (string, error) {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config: %v", err)
+	}
+
+	switch format {
+	case "yaml":
+		return strings.TrimRight(string(data), "\n"), nil
+	case "json":
+		// Round-trip through YAML so JSON uses the same key names as config.yaml
+		var generic map[string]interface{}
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return "", fmt.Errorf("failed to convert config: %v", err)
+		}
+		jsonData, err := json.MarshalIndent(generic, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal config as JSON: %v", err)
+		}
+		return string(jsonData), nil
+	default:
+		return "", fmt.Errorf("unknown config format: %s (options: yaml, json)", format)
+	}
+}
+
+// loadConfigFromFile applies the config file over the current settings and then, if
+// profile is set, the values of that entry of the top-level profiles map
+func loadConfigFromFile(config *Config, filename string, profile string) error {
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(absPath)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return err
+	}
+
+	if profile != "" {
+		if err := applyConfigProfile(config, data, profile); err != nil {
+			return err
+		}
+		fmt.Printf("Using configuration profile %q\n", profile)
+	}
+
+	// Unknown keys are only reported, so older configs keep loading
+	if unknown := findUnknownConfigKeys(data); len(unknown) > 0 {
+		fmt.Println("==========================================================")
+		fmt.Printf("Warning: %s contains unknown configuration keys:\n", filename)
+		for _, key := range unknown {
+			fmt.Printf("  - %s\n", key)
+		}
+		fmt.Println("These settings are ignored. Check them for typos.")
+		fmt.Println("==========================================================")
+	}
+
+	return nil
+}
+
+// applyConfigProfile applies the settings of the named profile over the config. Profiles
+// use the same keys as the config file and only need the keys they change.
+func applyConfigProfile(config *Config, data []byte, profile string) error {
+	var file struct {
+		Profiles map[string]yaml.Node `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return err
+	}
+
+	node, ok := file.Profiles[profile]
+	if !ok {
+		var names []string
+		for name := range file.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("profile %q not found, the config file has no profiles", profile)
+		}
+		return fmt.Errorf("profile %q not found (available: %s)", profile, strings.Join(names, ", "))
+	}
+
+	if err := node.Decode(config); err != nil {
+		return fmt.Errorf("invalid profile %q: %v", profile, err)
+	}
+	return nil
+}
+
+// findUnknownConfigKeys does a strict second pass over the YAML and returns
+// every key that does not map to a Config field, e.g. "line 2: nfc.decimel".
+func findUnknownConfigKeys(data []byte) []string {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+
+	// Profiles hold config keys themselves and are checked against the config
+	root := *doc.Content[0]
+	root.Content = nil
+	var profiles *yaml.Node
+	for i := 0; i+1 < len(doc.Content[0].Content); i += 2 {
+		key, value := doc.Content[0].Content[i], doc.Content[0].Content[i+1]
+		if key.Value == "profiles" {
+			profiles = value
+			continue
+		}
+		root.Content = append(root.Content, key, value)
+	}
+
+	var unknown []string
+	collectUnknownKeys(&root, reflect.TypeOf(Config{}), "", &unknown)
+	if profiles != nil && profiles.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(profiles.Content); i += 2 {
+			collectUnknownKeys(profiles.Content[i+1], reflect.TypeOf(Config{}), "profiles."+profiles.Content[i].Value+".", &unknown)
+		}
+	}
+	return unknown
+}
+
+// collectUnknownKeys walks a YAML mapping alongside the struct type it decodes into
+func collectUnknownKeys(node *yaml.Node, typ reflect.Type, prefix string, unknown *[]string) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	// Map keys are user-defined, only their values are checked
+	if typ.Kind() == reflect.Map {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			collectUnknownKeys(node.Content[i+1], typ.Elem(), prefix+node.Content[i].Value+".", unknown)
+		}
+		return
+	}
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = typ.Field(i).Type
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		fieldType, ok := fields[key.Value]
+		if !ok {
+			*unknown = append(*unknown, fmt.Sprintf("line %d: %s%s", key.Line, prefix, key.Value))
+			continue
+		}
+		collectUnknownKeys(node.Content[i+1], fieldType, prefix+key.Value+".", unknown)
+	}
+}
+
+// overrideWithFlags applies command-line flags over configuration file settings.
+// It returns the requested print-config format, or an empty string if the flag isn't set.
+func overrideWithFlags(config *Config) string {
+	var endChar, inChar, endSequence, postOutputClear, onReleaseKeys, devices, printFormat string
+	var autoRestart, noRestart, showVersion, updateNow, testNotify, testSound, printConfig, initConfig, force bool
+
+	// Define flags
+	flag.StringVar(&endChar, "end-char", config.NFC.EndChar, "Character at the end of UID. Options: "+CharFlagOptions())
+	flag.StringVar(&endSequence, "end-sequence", strings.Join(config.NFC.EndSequence, ","), "Comma-separated keys typed after the UID instead of end-char, e.g. tab,enter")
+	flag.StringVar(&postOutputClear, "post-output-clear", strings.Join(config.NFC.PostOutputClear, ","), "Comma-separated keys typed after the end keys to reset the field, e.g. select_all,backspace")
+	flag.StringVar(&config.NFC.Trigger, "trigger", config.NFC.Trigger, "When to read: auto (every presented card), manual (the card on the reader, on SIGUSR1 or the -stdio trigger command) or hotkey (the card on the reader, on -trigger-hotkey)")
+	flag.StringVar(&config.NFC.TriggerHotkey, "trigger-hotkey", config.NFC.TriggerHotkey, "Global hotkey reading the card with -trigger hotkey, e.g. ctrl+alt+n")
+	flag.StringVar(&onReleaseKeys, "on-release-keys", strings.Join(config.NFC.OnReleaseKeys, ","), "Comma-separated keys typed when the card is removed, e.g. escape")
+	flag.StringVar(&inChar, "in-char", config.NFC.InChar, "Character between bytes of UID. Options: "+CharFlagOptions())
+	flag.StringVar(&config.Delimiter, "delimiter", "", "Literal character between bytes of UID for this run, e.g. / or . (replaces -in-char and in_char)")
+	flag.BoolVar(&config.NFC.CapsLock, "caps-lock", config.NFC.CapsLock, "UID with Caps Lock")
+	flag.BoolVar(&config.NFC.Reverse, "reverse", config.NFC.Reverse, "UID reverse order")
+	flag.BoolVar(&config.NFC.PresentBeep, "present-beep", config.NFC.PresentBeep, "Pulse the reader buzzer (ACR122U) as soon as a card is read, before typing")
+	flag.BoolVar(&config.NFC.StrictRelease, "strict-release", config.NFC.StrictRelease, "Only read a card after the reader was empty since its last read, even for a different UID")
+	flag.BoolVar(&config.NFC.IgnoreFirstScan, "ignore-first-scan", config.NFC.IgnoreFirstScan, "Ignore a card already on the reader at startup until it is removed")
+	flag.BoolVar(&config.NFC.WarmupRead, "warmup-read", config.NFC.WarmupRead, "Send one discarded GET DATA after connecting, for readers whose first command fails")
+	flag.IntVar(&config.NFC.StartupGraceMs, "startup-grace-ms", config.NFC.StartupGraceMs, "Ignore cards presented within this many milliseconds after startup (0 = disabled)")
+	flag.IntVar(&config.NFC.ReemitIntervalMs, "reemit-interval-ms", config.NFC.ReemitIntervalMs, "Type the output again every this many milliseconds while the card stays on the reader (0 = disabled)")
+	flag.BoolVar(&config.NFC.SwapNibbles, "swap-nibbles", config.NFC.SwapNibbles, "Swap the nibbles within each UID byte (0x4A -> 0xA4)")
+	flag.BoolVar(&config.NFC.Decimal, "decimal", config.NFC.Decimal, "UID in decimal format")
+	flag.IntVar(&config.NFC.DecimalPadding, "decimal-padding", config.NFC.DecimalPadding, "Pad decimal numbers with leading zeros to this length (0 = no padding)")
+	flag.StringVar(&config.NFC.UIDBytes, "uid-bytes", config.NFC.UIDBytes, "Part of the UID to output, for one length across card types: "+strings.Join(uidByteSelections, ", "))
+	flag.StringVar(&config.NFC.UIDByteRange, "uid-byte-range", config.NFC.UIDByteRange, "Bytes of the UID to output as first:last counted from 1, e.g. 2:5 (empty = all)")
+	flag.IntVar(&config.NFC.MinUIDBytes, "min-uid-bytes", config.NFC.MinUIDBytes, "Reject UIDs shorter than this many bytes as failed reads (0 = accept all)")
+	flag.IntVar(&config.NFC.UIDPadBytes, "uid-pad-bytes", config.NFC.UIDPadBytes, "Pad shorter UIDs with leading zero bytes to this many bytes (0 = no padding)")
+	flag.StringVar(&config.NFC.LegacyFormat, "legacy-format", config.NFC.LegacyFormat, "UID format preset of a legacy system, replacing reverse/decimal/caps-lock/in-char: "+LegacyFormatOptions())
+	flag.IntVar(&config.NFC.Device, "device", config.NFC.Device, "Device number to use")
+	flag.StringVar(&devices, "devices", strings.Join(config.NFC.Devices, ","), "Comma-separated device numbers or reader names to watch simultaneously")
+	flag.StringVar(&config.NFC.FallbackDevice, "fallback-device", config.NFC.FallbackDevice, "Standby reader (number or name) used while the primary device is disconnected")
+	flag.BoolVar(&config.NFC.SwitchBack, "fallback-switch-back", config.NFC.SwitchBack, "Switch back to the primary reader when it's connected again")
+	flag.BoolVar(&config.NFC.WaitForReader, "wait-for-reader", config.NFC.WaitForReader, "Wait for the configured reader to be connected instead of restarting the service loop")
+	flag.StringVar(&config.NFC.OutputFormat, "output-format", config.NFC.OutputFormat, "Output template, tokens: {uid}, {reader}, {seq}")
+	flag.StringVar(&config.NFC.OutputRegex, "output-regex", config.NFC.OutputRegex, "Regular expression replaced in the output before the end keys (empty = off)")
+	flag.StringVar(&config.NFC.OutputReplace, "output-replace", config.NFC.OutputReplace, "Replacement for -output-regex matches, $1 for the first group")
+	flag.StringVar(&config.NFC.Script, "script", config.NFC.Script, "Lua script returning the text typed for a card (empty = off)")
+	flag.IntVar(&config.NFC.ScriptTimeoutMs, "script-timeout", config.NFC.ScriptTimeoutMs, "Time the -script may run per card in milliseconds")
+	flag.StringVar(&config.NFC.PrefixFrom, "prefix-from", config.NFC.PrefixFrom, "Site prefix source: static (-prefix), hostname (optionally via -prefix-pattern) or env (nfc.prefix_env)")
+	flag.StringVar(&config.NFC.Prefix, "prefix", config.NFC.Prefix, "Site prefix typed before every UID with -prefix-from static")
+	flag.StringVar(&config.NFC.PrefixPattern, "prefix-pattern", config.NFC.PrefixPattern, "Regular expression taking the site prefix from the hostname, its first group or the whole match")
+	flag.StringVar(&config.NFC.TypeProfile, "type-profile", config.NFC.TypeProfile, "Typing speed: "+TypeProfileOptions())
+	flag.StringVar(&config.NFC.KeyboardLayout, "keyboard-layout", config.NFC.KeyboardLayout, "Keyboard layout of the target machine: "+KeyboardLayoutOptions())
+	flag.StringVar(&config.NFC.CapsLockStrategy, "capslock-strategy", config.NFC.CapsLockStrategy, "CAPS Lock handling while typing: toggle (switch it off) or compensate (invert Shift for letters)")
+	flag.BoolVar(&config.NFC.UnicodeInput, "windows-unicode-input", config.NFC.UnicodeInput, "Windows: type characters by codepoint with SendInput, independent of the keyboard layout")
+	flag.BoolVar(&config.NFC.RequireTextFocus, "require-text-focus", config.NFC.RequireTextFocus, "Windows: only type the UID when a text field is focused")
+	flag.StringVar(&config.NFC.OnWriteError, "on-write-error", config.NFC.OnWriteError, "Typing error partway through the output: keep (leave the typed part), erase (backspace it) or retry (erase and type again)")
+	flag.BoolVar(&config.Web.OpenWebsite, "open-website", config.Web.OpenWebsite, "Open website URL in browser on startup")
+	flag.StringVar(&config.Web.WebsiteURL, "website-url", config.Web.WebsiteURL, "URL to open in browser")
+	flag.BoolVar(&config.Web.Fullscreen, "fullscreen", config.Web.Fullscreen, "Open browser in fullscreen mode")
+	flag.StringVar(&config.Advanced.LogLevel, "log-level", config.Advanced.LogLevel, "Log level: info, debug")
+	flag.BoolVar(&config.Updates.Enabled, "updates", config.Updates.Enabled, "Enable automatic update checking")
+	flag.BoolVar(&config.Updates.CheckOnStartup, "check-updates", config.Updates.CheckOnStartup, "Check for updates on startup")
+	flag.BoolVar(&showVersion, "version", false, "Show version and exit")
+	flag.BoolVar(&updateNow, "update", false, "Check for updates and install if available, then exit")
+	flag.BoolVar(&testNotify, "test-notify", false, "Send a test desktop notification, then exit")
+	flag.BoolVar(&testSound, "test-sound", false, "Play the configured success and error sounds, then exit")
+	flag.BoolVar(&printConfig, "print-config", false, "Print the effective configuration (defaults, file and flags), then exit")
+	flag.StringVar(&printFormat, "print-format", "yaml", "Format for -print-config: yaml, json")
+	flag.StringVar(&config.ConfigPath, "config", config.ConfigPath, "Path of the config file")
+	flag.StringVar(&config.Profile, "profile", config.Profile, "Name of the entry in the config file's profiles map to apply over its settings")
+	flag.BoolVar(&initConfig, "init-config", false, "Write a commented default config file to the -config path (default config.yaml), then exit")
+	flag.BoolVar(&force, "force", false, "Allow -init-config to overwrite an existing config file")
+	flag.BoolVar(&config.SelfTest, "selftest", false, "Read one card without typing to check drivers, reader and config, then exit with pass/fail")
+	flag.IntVar(&config.SelfTestTimeout, "selftest-timeout", 30, "Seconds -selftest waits for a card")
+	flag.IntVar(&config.Benchmark, "benchmark", 0, "Read and type this many cards, then print the read-to-type latency percentiles and exit")
+	flag.BoolVar(&config.Once, "once", false, "Read a single card, type it and print it as JSON to stdout, then exit (0 = read, 1 = error, 3 = timeout)")
+	flag.IntVar(&config.OnceTimeout, "once-timeout", 0, "Seconds -once waits for a card (0 = no limit)")
+	flag.BoolVar(&config.OnceType, "once-type", true, "Type the card read by -once, false to only print it")
+	flag.BoolVar(&config.Stdio, "stdio", false, "Take commands as JSON lines on stdin and write scans, errors and status as JSON lines to stdout, for a supervising process")
+	flag.BoolVar(&noRestart, "no-restart", false, "Never self-restart on PC/SC failures, overrides advanced.self_restart (for debugging)")
+	flag.StringVar(&config.Advanced.RestartMode, "restart-mode", config.Advanced.RestartMode, "How to restart on PC/SC failures: self (start a new process) or supervised (exit with advanced.supervised_exit_code for systemd/NSSM)")
+	flag.BoolVar(&autoRestart, "auto-restart", false, "Internal flag indicating automatic restart")
+
+	// Parse flags
+	flag.Parse()
+
+	// Handle version flag
+	if showVersion {
+		fmt.Printf("NFC UID Reader Version: %s\n", Version)
+		os.Exit(0)
+	}
+
+	// Handle init-config flag
+	if initConfig {
+		if err := WriteDefaultConfig(config.ConfigPath, force); err != nil {
+			SafeExit(1, fmt.Sprintf("Failed to write default config: %v", err), nil)
+		}
+		fmt.Printf("Default configuration written to %s\n", config.ConfigPath)
+		SafeExit(0, "", nil)
+	}
+
+	// Handle update flag
+	if updateNow {
+		fmt.Printf("NFC UID Reader Version: %s\n", Version)
+		fmt.Println("Checking for updates...")
+
+		// Force enable updates for manual update check
+		config.Updates.Enabled = true
+		config.Updates.AutoDownload = true
+		config.Updates.AutoInstall = true
+
+		// Create a basic notification manager for the update process
+		notificationManager := NewNotificationManager(config)
+		updateChecker := NewUpdateChecker(config, notificationManager)
+
+		if err := updateChecker.PerformUpdateCheck(); err != nil {
+			fmt.Printf("Update failed: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Update check completed.")
+		os.Exit(0)
+	}
+
+	// Handle notification/sound test flags
+	if testNotify || testSound {
+		exitCode := 0
+
+		if testNotify {
+			fmt.Println("Sending test notification...")
+			if !config.Notifications.Enabled {
+				fmt.Println("Note: notifications are disabled in the configuration, sending anyway")
+			}
+			notificationManager := NewNotificationManager(config)
+			if err := notificationManager.SendTestNotification(); err != nil {
+				fmt.Printf("Test notification failed: %v\n", err)
+				if runtime.GOOS == "linux" {
+					fmt.Println("Is a notification daemon running? Headless systems usually have none.")
+				}
+				exitCode = 1
+			} else {
+				fmt.Println("Test notification sent.")
+			}
+		}
+
+		if testSound {
+			fmt.Println("Playing test sounds...")
+			if !config.Audio.Enabled {
+				fmt.Println("Note: audio is disabled in the configuration, playing anyway")
+			}
+			audioManager := NewAudioManager(config)
+			audioManager.PlayTestSounds()
+			fmt.Println("Test sounds played.")
+		}
+
+		os.Exit(exitCode)
+	}
+
+	// Keep the failing process around for debugging instead of relaunching it
+	if noRestart {
+		config.Advanced.SelfRestart = false
+	}
+
+	// If this is an auto-restart, disable browser opening
+	if autoRestart {
+		config.AutoRestart = true
+		config.Web.OpenWebsite = false
+	}
+
+	// Apply character flags
+	if endChar != config.NFC.EndChar {
+		config.NFC.EndChar = endChar
+	}
+	if inChar != config.NFC.InChar {
+		config.NFC.InChar = inChar
+	}
+
+	// Apply device list flag
+	if endSequence != strings.Join(config.NFC.EndSequence, ",") {
+		config.NFC.EndSequence = nil
+		for _, key := range strings.Split(endSequence, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				config.NFC.EndSequence = append(config.NFC.EndSequence, key)
+			}
+		}
+	}
+	if postOutputClear != strings.Join(config.NFC.PostOutputClear, ",") {
+		config.NFC.PostOutputClear = nil
+		for _, key := range strings.Split(postOutputClear, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				config.NFC.PostOutputClear = append(config.NFC.PostOutputClear, key)
+			}
+		}
+	}
+	if onReleaseKeys != strings.Join(config.NFC.OnReleaseKeys, ",") {
+		config.NFC.OnReleaseKeys = nil
+		for _, key := range strings.Split(onReleaseKeys, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				config.NFC.OnReleaseKeys = append(config.NFC.OnReleaseKeys, key)
+			}
+		}
+	}
+	if devices != strings.Join(config.NFC.Devices, ",") {
+		config.NFC.Devices = nil
+		for _, device := range strings.Split(devices, ",") {
+			if device = strings.TrimSpace(device); device != "" {
+				config.NFC.Devices = append(config.NFC.Devices, device)
+			}
+		}
+	}
+
+	if !printConfig {
+		return ""
+	}
+	return printFormat
+}
+
+// validateConfig validates the configuration values
+func validateConfig(config *Config) error {
+	// Validate character flags
+	if _, ok := StringToCharFlag(config.NFC.EndChar); !ok {
+		return fmt.Errorf("invalid end character: %s", config.NFC.EndChar)
+	}
+
+	if _, ok := StringToCharFlag(config.NFC.InChar); !ok {
+		return fmt.Errorf("invalid in character: %s", config.NFC.InChar)
+	}
+	if err := validateDelimiter(config); err != nil {
+		return err
+	}
+	if err := validateTypable(config); err != nil {
+		return err
+	}
+
+	// Validate split output
+	if config.NFC.SplitOutput.Enabled {
+		if !IsValidParityMode(config.NFC.SplitOutput.Parity) {
+			return fmt.Errorf("invalid split output parity: %s (options: even, odd, xor)", config.NFC.SplitOutput.Parity)
+		}
+		if _, ok := StringToCharFlag(config.NFC.SplitOutput.Separator); !ok {
+			return fmt.Errorf("invalid split output separator: %s", config.NFC.SplitOutput.Separator)
+		}
+	}
+
+	// Validate duplicate output
+	if config.NFC.DuplicateOutput.Enabled {
+		if _, ok := StringToCharFlag(config.NFC.DuplicateOutput.Separator); !ok {
+			return fmt.Errorf("invalid duplicate output separator: %s", config.NFC.DuplicateOutput.Separator)
+		}
+		if config.NFC.SplitOutput.Enabled {
+			return fmt.Errorf("duplicate_output can't be combined with split_output, both fill the second field")
+		}
+	}
+
+	// Validate debounce
+	if config.NFC.DebounceMs < 0 {
+		return fmt.Errorf("debounce must be non-negative, got: %d", config.NFC.DebounceMs)
+	}
+	if config.NFC.DebounceMs == 0 && !config.NFC.WaitForRelease {
+		return fmt.Errorf("debounce_ms must be positive without wait_for_release, a card left on the reader would be typed again and again")
+	}
+
+	// Validate startup grace period
+	if config.NFC.StartupGraceMs < 0 {
+		return fmt.Errorf("startup grace must be non-negative, got: %d", config.NFC.StartupGraceMs)
+	}
+
+	// Validate output format
+	if !strings.Contains(config.NFC.OutputFormat, "{uid}") {
+		return fmt.Errorf("output format must contain the {uid} token, got: %q", config.NFC.OutputFormat)
+	}
+
+	if config.NFC.OutputRegex != "" {
+		if _, err := regexp.Compile(config.NFC.OutputRegex); err != nil {
+			return fmt.Errorf("invalid output regex: %v", err)
+		}
+	} else if config.NFC.OutputReplace != "" {
+		return fmt.Errorf("output_replace needs output_regex")
+	}
+
+	if config.NFC.Script != "" {
+		if config.NFC.ScriptTimeoutMs < 1 || config.NFC.ScriptTimeoutMs > 5000 {
+			return fmt.Errorf("script timeout must be between 1 and 5000 ms, got: %d", config.NFC.ScriptTimeoutMs)
+		}
+		if config.NFC.ReadAll.Enabled {
+			return fmt.Errorf("script can't be combined with read_all")
+		}
+		if _, err := loadUIDScript(config); err != nil {
+			return err
+		}
+	}
+
+	// Validate the scan sequence number and its {seq} token
+	hasSeq := strings.Contains(config.NFC.OutputFormat, "{seq}")
+	if config.NFC.Sequence.Enabled != hasSeq {
+		return fmt.Errorf("the {seq} output token and nfc.sequence.enabled go together, got enabled %v with output format %q", config.NFC.Sequence.Enabled, config.NFC.OutputFormat)
+	}
+	if config.NFC.Sequence.Width < 1 || config.NFC.Sequence.Width > maxSequenceWidth {
+		return fmt.Errorf("sequence width must be between 1 and %d, got: %d", maxSequenceWidth, config.NFC.Sequence.Width)
+	}
+
+	if config.NFC.RepeatAlert.Enabled {
+		if config.NFC.RepeatAlert.Count < 2 {
+			return fmt.Errorf("repeat alert count must be at least 2, got: %d", config.NFC.RepeatAlert.Count)
+		}
+		if config.NFC.RepeatAlert.WindowSeconds < 1 {
+			return fmt.Errorf("repeat alert window must be at least 1 second, got: %d", config.NFC.RepeatAlert.WindowSeconds)
+		}
+		if config.NFC.RepeatAlert.BlockSeconds < 0 {
+			return fmt.Errorf("repeat alert block must be non-negative, got: %d", config.NFC.RepeatAlert.BlockSeconds)
+		}
+	}
+
+	// Validate device list
+	for _, device := range config.NFC.Devices {
+		if strings.TrimSpace(device) == "" {
+			return fmt.Errorf("device list must not contain empty entries")
+		}
+	}
+	if config.NFC.FallbackDevice != "" {
+		// The fallback stands in for one primary reader, which can't be chosen interactively
+		if len(config.NFC.Devices) > 1 {
+			return fmt.Errorf("fallback_device needs a single primary reader, got %d devices", len(config.NFC.Devices))
+		}
+		if len(config.NFC.Devices) == 0 && config.NFC.Device < 1 {
+			return fmt.Errorf("fallback_device needs the primary reader set with device or devices")
+		}
+	}
+
+	if config.Benchmark < 0 {
+		return fmt.Errorf("benchmark scan count must be non-negative, got: %d", config.Benchmark)
+	}
+
+	if config.OnceTimeout < 0 {
+		return fmt.Errorf("once timeout must be non-negative, got: %d", config.OnceTimeout)
+	}
+
+	// stdin carries the commands, so the device can't be chosen at the prompt
+	if config.Stdio && config.NFC.Device < 1 && len(config.NFC.Devices) == 0 {
+		return fmt.Errorf("-stdio needs the reader set with device or devices")
+	}
+
+	// Validate self-test timeout
+	if config.SelfTest && config.SelfTestTimeout < 1 {
+		return fmt.Errorf("self-test timeout must be at least 1 second, got: %d", config.SelfTestTimeout)
+	}
+
+	// Validate idle alert
+	if config.NFC.IdleAlertMinutes < 0 {
+		return fmt.Errorf("idle alert minutes must be non-negative, got: %d", config.NFC.IdleAlertMinutes)
+	}
+
+	// Validate end sequence
+	for _, key := range config.NFC.EndSequence {
+		if _, ok := StringToCharFlag(key); !ok {
+			return fmt.Errorf("invalid key in end sequence: %s (options: %s)", key, CharFlagOptions())
+		}
+	}
+
+	// Validate post output clear sequence
+	for _, key := range config.NFC.PostOutputClear {
+		if _, ok := StringToCharFlag(key); !ok {
+			return fmt.Errorf("invalid key in post output clear: %s (options: %s)", key, CharFlagOptions())
+		}
+	}
+
+	// Validate release keys, typed after waiting for the card removal
+	for _, key := range config.NFC.OnReleaseKeys {
+		if _, ok := StringToCharFlag(key); !ok {
+			return fmt.Errorf("invalid key in on release keys: %s (options: %s)", key, CharFlagOptions())
+		}
+	}
+	// Validate the trigger, triggered reads don't wait for the card removal
+	if !IsValidTriggerMode(config.NFC.Trigger) {
+		return fmt.Errorf("invalid trigger: %s (options: %s)", config.NFC.Trigger, strings.Join(triggerModes, ", "))
+	}
+	if isTriggeredMode(config.NFC.Trigger) && (len(config.NFC.OnReleaseKeys) > 0 || config.NFC.ReemitIntervalMs > 0) {
+		return fmt.Errorf("trigger %s can't be combined with on_release_keys or reemit_interval_ms, card removal isn't tracked", config.NFC.Trigger)
+	}
+	if config.NFC.Trigger == "manual" && !triggerSignalAvailable && !config.Stdio {
+		return fmt.Errorf("trigger manual needs -stdio on %s, there is no trigger signal and no card would ever be read", runtime.GOOS)
+	}
+	if config.NFC.Trigger == "hotkey" {
+		if !triggerHotkeyAvailable {
+			return fmt.Errorf("trigger hotkey is not supported on %s, use trigger manual", runtime.GOOS)
+		}
+		if _, err := parseTriggerHotkey(config.NFC.TriggerHotkey); err != nil {
+			return fmt.Errorf("invalid trigger_hotkey: %v", err)
+		}
+	}
+
+	if len(config.NFC.OnReleaseKeys) > 0 && !config.NFC.WaitForRelease {
+		return fmt.Errorf("on_release_keys needs wait_for_release, card removal isn't tracked without it")
+	}
+
+	// Validate the site prefix source
+	if !IsValidPrefixSource(config.NFC.PrefixFrom) {
+		return fmt.Errorf("invalid prefix source: %s (options: %s)", config.NFC.PrefixFrom, strings.Join(prefixSources, ", "))
+	}
+	if config.NFC.PrefixFrom == "env" && config.NFC.PrefixEnv == "" {
+		return fmt.Errorf("prefix_env must name an environment variable with prefix_from env")
+	}
+	if config.NFC.PrefixPattern != "" {
+		if config.NFC.PrefixFrom != "hostname" {
+			return fmt.Errorf("prefix_pattern only applies with prefix_from hostname")
+		}
+		if _, err := regexp.Compile(config.NFC.PrefixPattern); err != nil {
+			return fmt.Errorf("invalid prefix pattern: %v", err)
+		}
+	}
+
+	// Validate re-emitting, which happens while waiting for the card removal
+	if config.NFC.ReemitIntervalMs != 0 && config.NFC.ReemitIntervalMs < minReemitIntervalMs {
+		return fmt.Errorf("reemit interval must be 0 or at least %d ms, got: %d", minReemitIntervalMs, config.NFC.ReemitIntervalMs)
+	}
+	if config.NFC.ReemitIntervalMs > 0 && !config.NFC.WaitForRelease {
+		return fmt.Errorf("reemit_interval_ms needs wait_for_release, card removal isn't tracked without it")
+	}
+
+	// Validate type profile
+	if _, ok := StringToTypeProfile(config.NFC.TypeProfile); !ok {
+		return fmt.Errorf("invalid type profile: %s (options: %s)", config.NFC.TypeProfile, TypeProfileOptions())
+	}
+
+	// Validate keyboard layout
+	if _, err := keysForLayout(config.NFC.KeyboardLayout); err != nil {
+		return err
+	}
+
+	// Validate CAPS Lock strategy
+	if !IsValidCapsLockStrategy(config.NFC.CapsLockStrategy) {
+		return fmt.Errorf("invalid capslock strategy: %s (options: %s)", config.NFC.CapsLockStrategy, strings.Join(capsLockStrategies, ", "))
+	}
+
+	// Validate read mode
+	switch config.NFC.ReadMode {
+	case "uid":
+	case "mifare_block":
+		if config.NFC.MifareBlock.Block < 0 || config.NFC.MifareBlock.Block > 255 {
+			return fmt.Errorf("mifare block must be between 0 and 255, got: %d", config.NFC.MifareBlock.Block)
+		}
+		if _, ok := MifareKeyTypeCode(config.NFC.MifareBlock.KeyType); !ok {
+			return fmt.Errorf("invalid mifare key type: %s (options: A, B)", config.NFC.MifareBlock.KeyType)
+		}
+		if key, err := hex.DecodeString(config.NFC.MifareBlock.Key); err != nil || len(key) != 6 {
+			return fmt.Errorf("mifare key must be 12 hex digits, got: %s", config.NFC.MifareBlock.Key)
+		}
+	default:
+		return fmt.Errorf("invalid read mode: %s (options: uid, mifare_block)", config.NFC.ReadMode)
+	}
+
+	// Validate read all
+	if config.NFC.ReadAll.Enabled {
+		if config.NFC.ReadMode != "uid" {
+			return fmt.Errorf("read all only works with read mode uid, got: %s", config.NFC.ReadMode)
+		}
+		if _, ok := StringToCharFlag(config.NFC.ReadAll.Separator); !ok {
+			return fmt.Errorf("invalid read all separator: %s", config.NFC.ReadAll.Separator)
+		}
+	}
+
+	// Validate tag standard
+	if !IsValidTagStandard(config.NFC.TagStandard) {
+		return fmt.Errorf("invalid tag standard: %s (options: auto, 14443, 15693)", config.NFC.TagStandard)
+	}
+
+	// Validate reader profiles
+	for device, profile := range config.NFC.ReaderProfiles {
+		if strings.TrimSpace(device) == "" {
+			return fmt.Errorf("reader profile must name a device")
+		}
+		if profile.EndChar != "" {
+			if _, ok := StringToCharFlag(profile.EndChar); !ok {
+				return fmt.Errorf("invalid end character in reader profile %q: %s", device, profile.EndChar)
+			}
+		}
+		if profile.InChar != "" {
+			if _, ok := StringToCharFlag(profile.InChar); !ok {
+				return fmt.Errorf("invalid in character in reader profile %q: %s", device, profile.InChar)
+			}
+		}
+		for _, key := range profile.OnReleaseKeys {
+			if _, ok := StringToCharFlag(key); !ok {
+				return fmt.Errorf("invalid key in on release keys of reader profile %q: %s", device, key)
+			}
+		}
+		if len(profile.OnReleaseKeys) > 0 && (!config.NFC.WaitForRelease || isTriggeredMode(config.NFC.Trigger)) {
+			return fmt.Errorf("on_release_keys in reader profile %q needs wait_for_release and trigger auto, card removal isn't tracked otherwise", device)
+		}
+	}
+
+	// Validate device number
+	if config.NFC.Device < 0 {
+		return fmt.Errorf("device number must be positive, got: %d", config.NFC.Device)
+	}
+
+	// Validate decimal padding
+	if config.NFC.DecimalPadding < 0 {
+		return fmt.Errorf("decimal padding must be non-negative, got: %d", config.NFC.DecimalPadding)
+	}
+
+	// Validate the card allowlist
+	for _, prefix := range config.NFC.AllowedATRs {
+		if _, err := ParseATRPrefix(prefix); err != nil {
+			return fmt.Errorf("invalid allowed ATR prefix: %v", err)
+		}
+	}
+	if len(config.NFC.AllowedATRs) > 0 && config.NFC.ReadAll.Enabled {
+		return fmt.Errorf("allowed_atr_prefixes can't be combined with read_all, only the ATR of one card is known")
+	}
+
+	if config.NFC.TextFocusWaitMs < 0 || config.NFC.TextFocusWaitMs > maxTextFocusWaitMs {
+		return fmt.Errorf("text focus wait must be between 0 and %d ms, got: %d", maxTextFocusWaitMs, config.NFC.TextFocusWaitMs)
+	}
+
+	if !IsValidWriteErrorAction(config.NFC.OnWriteError) {
+		return fmt.Errorf("invalid on write error action: %s (options: %s)", config.NFC.OnWriteError, strings.Join(writeErrorActions, ", "))
+	}
+
+	// Validate UID length rules
+	if !IsValidUIDBytes(config.NFC.UIDBytes) {
+		return fmt.Errorf("invalid uid bytes: %s (options: %s)", config.NFC.UIDBytes, strings.Join(uidByteSelections, ", "))
+	}
+	first, last, err := parseUIDByteRange(config.NFC.UIDByteRange)
+	if err != nil {
+		return err
+	}
+	if last > 0 && config.NFC.UIDBytes != "all" {
+		return fmt.Errorf("uid_byte_range can't be combined with uid_bytes %s, both pick the bytes to output", config.NFC.UIDBytes)
+	}
+	if last-first+1 > 4 && (config.NFC.Decimal || legacyFormats[config.NFC.LegacyFormat].Decimal) {
+		return fmt.Errorf("decimal output needs 4 byte UIDs, uid_byte_range %s picks %d bytes", config.NFC.UIDByteRange, last-first+1)
+	}
+	if config.NFC.MinUIDBytes < 0 || config.NFC.MinUIDBytes > maxUIDPadBytes {
+		return fmt.Errorf("min uid bytes must be between 0 and %d, got: %d", maxUIDPadBytes, config.NFC.MinUIDBytes)
+	}
+	if config.NFC.UIDPadBytes < 0 || config.NFC.UIDPadBytes > maxUIDPadBytes {
+		return fmt.Errorf("uid pad bytes must be between 0 and %d, got: %d", maxUIDPadBytes, config.NFC.UIDPadBytes)
+	}
+	if config.NFC.UIDPadBytes > 4 && (config.NFC.Decimal || legacyFormats[config.NFC.LegacyFormat].Decimal) {
+		return fmt.Errorf("decimal output needs 4 byte UIDs, use uid_bytes first4 or last4 instead of uid_pad_bytes %d", config.NFC.UIDPadBytes)
+	}
+
+	// Validate legacy format preset
+	if config.NFC.LegacyFormat != "" {
+		if _, ok := legacyFormats[config.NFC.LegacyFormat]; !ok {
+			return fmt.Errorf("invalid legacy format: %s (options: %s)", config.NFC.LegacyFormat, LegacyFormatOptions())
+		}
+		if config.NFC.Wiegand.Enabled {
+			return fmt.Errorf("legacy_format and wiegand can't be combined, both set the UID format")
+		}
+	}
+
+	// Validate Wiegand output
+	if config.NFC.Wiegand.Enabled {
+		if config.NFC.Wiegand.FacilityBits < 1 || config.NFC.Wiegand.FacilityBits > 16 {
+			return fmt.Errorf("wiegand facility bits must be between 1 and 16, got: %d", config.NFC.Wiegand.FacilityBits)
+		}
+		if config.NFC.Wiegand.CardBits < 1 || config.NFC.Wiegand.CardBits > 32 {
+			return fmt.Errorf("wiegand card bits must be between 1 and 32, got: %d", config.NFC.Wiegand.CardBits)
+		}
+		maxFacility := 1<<config.NFC.Wiegand.FacilityBits - 1
+		if config.NFC.Wiegand.FacilityCode < 0 || config.NFC.Wiegand.FacilityCode > maxFacility {
+			return fmt.Errorf("wiegand facility code must be between 0 and %d for %d facility bits, got: %d", maxFacility, config.NFC.Wiegand.FacilityBits, config.NFC.Wiegand.FacilityCode)
+		}
+	}
+
+	// Validate retry attempts
+	if config.Advanced.RetryAttempts < 1 {
+		return fmt.Errorf("retry attempts must be at least 1, got: %d", config.Advanced.RetryAttempts)
+	}
+
+	// Validate card read retries
+	if config.Advanced.CardReadAttempts < 1 {
+		return fmt.Errorf("card read attempts must be at least 1, got: %d", config.Advanced.CardReadAttempts)
+	}
+
+	if config.Advanced.CardReadDelayMs < 0 {
+		return fmt.Errorf("card read delay must be non-negative, got: %d", config.Advanced.CardReadDelayMs)
+	}
+
+	// Validate keyboard init retries
+	if config.Advanced.KeyboardInitAttempts < 1 {
+		return fmt.Errorf("keyboard init attempts must be at least 1, got: %d", config.Advanced.KeyboardInitAttempts)
+	}
+
+	if config.Advanced.KeyboardInitDelayMs < 0 {
+		return fmt.Errorf("keyboard init delay must be non-negative, got: %d", config.Advanced.KeyboardInitDelayMs)
+	}
+
+	if config.Advanced.LogRateLimit < 0 {
+		return fmt.Errorf("log rate limit must be non-negative, got: %d", config.Advanced.LogRateLimit)
+	}
+
+	// Validate scan history
+	if config.Advanced.ScanHistorySize < 0 {
+		return fmt.Errorf("scan history size must be non-negative, got: %d", config.Advanced.ScanHistorySize)
+	}
+
+	// Validate reconnect delay
+	if config.Advanced.ReconnectDelay < 0 {
+		return fmt.Errorf("reconnect delay must be non-negative, got: %d", config.Advanced.ReconnectDelay)
+	}
+
+	if config.Advanced.MaxReconnectDelay < 0 {
+		return fmt.Errorf("max reconnect delay must be non-negative, got: %d", config.Advanced.MaxReconnectDelay)
+	}
+
+	if config.Advanced.OutageAlertAfter < 0 {
+		return fmt.Errorf("outage alert delay must be non-negative, got: %d", config.Advanced.OutageAlertAfter)
+	}
+
+	if config.Advanced.WatchdogSeconds != 0 && config.Advanced.WatchdogSeconds < minWatchdogSeconds {
+		return fmt.Errorf("watchdog must be 0 (disabled) or at least %d seconds, got: %d", minWatchdogSeconds, config.Advanced.WatchdogSeconds)
+	}
+
+	// Validate self-restart settings
+	if config.Advanced.MaxContextFailures < 1 {
+		return fmt.Errorf("max context failures must be at least 1, got: %d", config.Advanced.MaxContextFailures)
+	}
+
+	if config.Advanced.RestartPcscd && strings.TrimSpace(config.Advanced.PcscdCommand) == "" {
+		return fmt.Errorf("restart_pcscd needs a pcscd_restart_command")
+	}
+
+	if !IsValidRestartMode(config.Advanced.RestartMode) {
+		return fmt.Errorf("invalid restart mode: %s (options: %s)", config.Advanced.RestartMode, strings.Join(restartModes, ", "))
+	}
+	if config.Advanced.SupervisedExit < 2 || config.Advanced.SupervisedExit > 255 {
+		return fmt.Errorf("supervised exit code must be between 2 and 255, got: %d", config.Advanced.SupervisedExit)
+	}
+
+	if config.Advanced.RestartDelay < 0 {
+		return fmt.Errorf("restart delay must be non-negative, got: %d", config.Advanced.RestartDelay)
+	}
+
+	// Validate log level
+	if _, ok := StringToLogLevel(config.Advanced.LogLevel); !ok {
+		return fmt.Errorf("invalid log level: %s (options: info, debug)", config.Advanced.LogLevel)
+	}
+
+	// Validate website URL
+	if config.Web.OpenWebsite {
+		if err := validateWebsiteURL(config.Web.WebsiteURL); err != nil {
+			return err
+		}
+	}
+
+	// Validate notification cooldown
+	if config.Notifications.RestartCooldown < 0 {
+		return fmt.Errorf("restart cooldown must be non-negative, got: %d", config.Notifications.RestartCooldown)
+	}
+
+	if config.Notifications.MaxSendFailures < 0 {
+		return fmt.Errorf("max send failures must be non-negative, got: %d", config.Notifications.MaxSendFailures)
+	}
+
+	if config.Notifications.ThrottleJitter < 0 {
+		return fmt.Errorf("throttle jitter must be non-negative, got: %d", config.Notifications.ThrottleJitter)
+	}
+
+	for category := range config.Notifications.Categories {
+		if !isNotificationCategory(category) {
+			return fmt.Errorf("invalid notification category: %s (options: %s)", category, strings.Join(notificationCategories, ", "))
+		}
+	}
+
+	// Validate event log
+	if config.EventLog.Enabled && strings.TrimSpace(config.EventLog.Path) == "" {
+		return fmt.Errorf("event log path must be set when the event log is enabled")
+	}
+
+	// Validate pipe output
+	if config.Pipe.Enabled && strings.TrimSpace(config.Pipe.Path) == "" {
+		return fmt.Errorf("pipe path must be set when the pipe output is enabled")
+	}
+
+	return nil
+}
+
+// validateDelimiter checks that the -delimiter is a single character the keyboard layout
+// can type, any character with windows_unicode_input on Windows
+func validateDelimiter(config *Config) error {
+	if config.Delimiter == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(config.Delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character, got: %q", config.Delimiter)
+	}
+	if config.NFC.LegacyFormat != "" {
+		return fmt.Errorf("delimiter can't be combined with legacy_format, the preset sets the characters between bytes")
+	}
+	if config.NFC.UnicodeInput && unicodeInputAvail {
+		if r, _ := utf8.DecodeRuneInString(config.Delimiter); !unicode.IsPrint(r) {
+			return fmt.Errorf("delimiter %q is not printable", config.Delimiter)
+		}
+		return nil
+	}
+	keys, err := keysForLayout(config.NFC.KeyboardLayout)
+	if err != nil {
+		return err
+	}
+	if _, ok := keys[config.Delimiter]; !ok {
+		return fmt.Errorf("delimiter %q can't be typed with the %s keyboard layout", config.Delimiter, config.NFC.KeyboardLayout)
+	}
+	return nil
+}
+
+// outputTokens removes the output format tokens, their values are only known when typing
+var outputTokens = strings.NewReplacer("{uid}", "", "{reader}", "", "{seq}", "")
+
+// typedOutputChars turns output text into the characters typed for it, the escape
+// sequences for Enter, Tab, Backspace, select all and Escape type keys instead
+var typedOutputChars = strings.NewReplacer(`\n`, "", `\t`, "", `\b`, "", `\a`, "", `\e`, "", `\\`, `\`, `\"`, `"`)
+
+// validateTypable checks that the fixed text of the static prefix and the output format
+// can be typed with the keyboard layout
+func validateTypable(config *Config) error {
+	if err := validateTypableText(config, "output format", outputTokens.Replace(config.NFC.OutputFormat)); err != nil {
+		return err
+	}
+	if config.NFC.PrefixFrom == "static" {
+		return validateTypableText(config, "prefix", config.NFC.Prefix)
+	}
+	return nil
+}
+
+// validateTypableText checks that every character of the output text has a key on the
+// keyboard layout, any text can be typed with windows_unicode_input on Windows
+func validateTypableText(config *Config, name, text string) error {
+	if config.NFC.UnicodeInput && unicodeInputAvail {
+		return nil
+	}
+	keys, err := keysForLayout(config.NFC.KeyboardLayout)
+	if err != nil {
+		return err
+	}
+	for _, char := range typedOutputChars.Replace(text) {
+		if _, ok := keys[string(char)]; !ok {
+			return fmt.Errorf("%s contains %q, which can't be typed with the %s keyboard layout", name, char, config.NFC.KeyboardLayout)
+		}
+	}
+	return nil
+}
+
+// validateWebsiteURL checks that the website URL is an absolute http(s) URL with a host
+func validateWebsiteURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid website URL %q: %v", rawURL, err)
+	}
+
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https":
+	case "":
+		return fmt.Errorf("website URL must start with http:// or https://, got: %s", rawURL)
+	default:
+		return fmt.Errorf("website URL scheme %q is not allowed, use http or https", parsed.Scheme)
+	}
+
+	if parsed.Host == "" {
+		return fmt.Errorf("website URL has no host: %s", rawURL)
+	}
+
+	return nil
+}
+
+// ToFlags converts Config to the legacy Flags struct for compatibility
+func (c *Config) ToFlags() Flags {
+	flags := Flags{
+		CapsLock:       c.NFC.CapsLock,
+		Reverse:        c.NFC.Reverse,
+		SwapNibbles:    c.NFC.SwapNibbles,
+		Decimal:        c.NFC.Decimal,
+		DecimalPadding: c.NFC.DecimalPadding,
+		Device:         c.NFC.Device,
+		Devices:        c.NFC.Devices,
+		ReaderProfiles: c.NFC.ReaderProfiles,
+		UIDBytes:       c.NFC.UIDBytes,
+		UIDByteRange:   c.NFC.UIDByteRange,
+		UIDPadBytes:    c.NFC.UIDPadBytes,
+		Delimiter:      c.Delimiter,
+		SitePrefix:     c.SitePrefix,
+	}
+
+	for _, prefix := range c.NFC.AllowedATRs {
+		atr, _ := ParseATRPrefix(prefix)
+		flags.AllowedATRs = append(flags.AllowedATRs, atr)
+	}
+
+	flags.TypeProfile, _ = StringToTypeProfile(c.NFC.TypeProfile)
+	flags.TagStandard = c.NFC.TagStandard
+
+	if c.NFC.ReadMode == "mifare_block" {
+		keyType, _ := MifareKeyTypeCode(c.NFC.MifareBlock.KeyType)
+		key, _ := hex.DecodeString(c.NFC.MifareBlock.Key)
+		flags.MifareBlock = &MifareBlockRead{
+			Block:   byte(c.NFC.MifareBlock.Block),
+			KeyType: keyType,
+			Key:     key,
+		}
+	}
+
+	if c.NFC.OutputFormat != "{uid}" {
+		flags.OutputFormat = c.NFC.OutputFormat
+	}
+	if c.NFC.OutputRegex != "" {
+		flags.OutputRegex, _ = regexp.Compile(c.NFC.OutputRegex)
+		flags.OutputReplace = c.NFC.OutputReplace
+	}
+
+	// Convert character flags
+	endChar, _ := StringToCharFlag(c.NFC.EndChar)
+	inChar, _ := StringToCharFlag(c.NFC.InChar)
+
+	flags.EndChar = endChar
+	flags.InChar = inChar
+
+	for _, key := range c.NFC.EndSequence {
+		charFlag, _ := StringToCharFlag(key)
+		flags.EndSequence = append(flags.EndSequence, charFlag)
+	}
+	if preset, ok := legacyFormats[c.NFC.LegacyFormat]; ok {
+		preset.apply(&flags)
+	}
+	for _, key := range c.NFC.PostOutputClear {
+		charFlag, _ := StringToCharFlag(key)
+		flags.PostOutputClear = append(flags.PostOutputClear, charFlag)
+	}
+	for _, key := range c.NFC.OnReleaseKeys {
+		charFlag, _ := StringToCharFlag(key)
+		flags.OnReleaseKeys = append(flags.OnReleaseKeys, charFlag)
+	}
+
+	if c.NFC.Wiegand.Enabled {
+		flags.Wiegand = &WiegandFormat{
+			FacilityCode: uint32(c.NFC.Wiegand.FacilityCode),
+			FacilityBits: c.NFC.Wiegand.FacilityBits,
+			CardBits:     c.NFC.Wiegand.CardBits,
+		}
+	}
+
+	if c.NFC.ReadAll.Enabled {
+		flags.ReadAll = true
+		flags.ReadAllSeparator, _ = StringToCharFlag(c.NFC.ReadAll.Separator)
+	}
+
+	if c.NFC.SplitOutput.Enabled {
+		splitSeparator, _ := StringToCharFlag(c.NFC.SplitOutput.Separator)
+		flags.SplitParity = c.NFC.SplitOutput.Parity
+		flags.SplitSeparator = splitSeparator
+	}
+
+	if c.NFC.DuplicateOutput.Enabled {
+		flags.Duplicate = true
+		flags.DuplicateSeparator, _ = StringToCharFlag(c.NFC.DuplicateOutput.Separator)
+	}
+
+	return flags
+}
@@ -0,0 +1,236 @@
+package nfcuid
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configSectionDocs documents the top-level configuration sections
+var configSectionDocs = map[string]string{
+	"nfc":           "NFC Reader Settings",
+	"web":           "Web Browser Integration",
+	"notifications": "System Notifications",
+	"audio":         "Audio Feedback Settings",
+	"advanced":      "Advanced Settings",
+	"updates":       "Update Checker Settings",
+	"event_log":     "Structured Event Log (JSON lines for downstream tools)",
+	"pipe":          "Named Pipe Output (scans for programs on the same machine)",
+}
+
+// configFieldDocs documents every configuration key, written as comments by -init-config
+var configFieldDocs = map[string]string{
+	"nfc.device":                      "Device number (0 for manual selection, or specific device number)",
+	"nfc.devices":                     "Watch several readers at once, by device number or (part of) reader name; overrides device when set",
+	"nfc.fallback_device":             "Standby reader (number or name) that takes over while the primary reader (device, or a single devices entry) is disconnected; empty for none",
+	"nfc.wait_for_reader":             "Check every 2 seconds until the configured reader is connected, with one notification, instead of failing and restarting the service loop (for machines that boot before the reader is plugged in)",
+	"nfc.fallback_switch_back":        "Switch back to the primary reader as soon as it's connected again, otherwise the fallback stays in use until the next reconnect",
+	"nfc.caps_lock":                   "UID output with uppercase letters",
+	"nfc.reverse":                     "Reverse the UID byte order",
+	"nfc.swap_nibbles":                "Swap the two hex digits of each UID byte (0x4A -> 0xA4), keeping the byte order; combines with reverse",
+	"nfc.decimal":                     "Output UID in decimal format instead of hex",
+	"nfc.decimal_padding":             "Pad decimal numbers with leading zeros to this length (0 = no padding)",
+	"nfc.legacy_format":               "UID format of a legacy system, replacing reverse, swap_nibbles, decimal, decimal_padding, caps_lock and in_char: reversed_decimal10, decimal10, reversed_hex or mac (04:A2:2B:91, also without end keys) (empty = use those settings)",
+	"nfc.uid_bytes":                   "Part of the UID to output so mixed card stock gives one output length: all, first4 or last4 (first/last 4 bytes of longer UIDs as read, before reverse)",
+	"nfc.uid_byte_range":              "Bytes of the UID to output as first:last counted from 1 (e.g. 2:5 for bytes 2 to 5 of a 7 byte UID), before reverse; UIDs too short for the range are typed whole with a warning (empty = all)",
+	"nfc.min_uid_bytes":               "Reject UIDs shorter than this many bytes as failed reads, truncated UIDs of glitchy reads aren't typed (0 = accept all, max 10)",
+	"nfc.uid_pad_bytes":               "Pad shorter UIDs with leading zero bytes to this many bytes for a fixed width hex output, longer UIDs are kept whole (0 = no padding, max 10)",
+	"nfc.end_char":                    "Character to append at end of UID: none, space, tab, hyphen, enter, semicolon, colon, comma",
+	"nfc.end_sequence":                "Keys typed in order after the UID instead of end_char, e.g. [tab, enter] (same key names as end_char)",
+	"nfc.post_output_clear":           "Keys typed after end_char/end_sequence to reset a field that keeps its input, e.g. [select_all, backspace]; adds the key names backspace and select_all (Ctrl+A, Cmd+A on macOS)",
+	"nfc.on_release_keys":             "Keys typed when the card is removed from the reader, e.g. [escape] to reset the input field for the next person; needs wait_for_release (empty = none)",
+	"nfc.in_char":                     "Character to insert between UID bytes (same options as end_char)",
+	"nfc.prefix_from":                 "Where the site prefix typed before every UID comes from: static (nfc.prefix), hostname or env (nfc.prefix_env), resolved once at startup",
+	"nfc.prefix":                      "Site prefix with prefix_from static, empty for none",
+	"nfc.prefix_env":                  "Environment variable holding the site prefix with prefix_from env",
+	"nfc.prefix_pattern":              "Regular expression taking the site prefix from the hostname with prefix_from hostname: its first group or the whole match, empty for the whole hostname",
+	"nfc.output_format":               "Output template, tokens: {uid} (formatted UID), {reader} (name of the tapped reader), {seq} (scan number with sequence enabled)",
+	"nfc.output_regex":                "Go regular expression whose matches in the output (prefixes, UID and template text, not the end keys) are replaced with output_replace (empty = off)",
+	"nfc.output_replace":              "Replacement for output_regex matches, ${1} inserts the first group; typed as written, a backslash is not a key",
+	"nfc.script":                      "Path of a Lua script returning the text typed for a card, see Output Script in the README (empty = off)",
+	"nfc.script_timeout_ms":           "Time the script may run per card in milliseconds, the standard output is typed when it takes longer",
+	"nfc.type_profile":                "Typing speed: instant (all keys at once), steady (fixed pause between keys) or human (randomized pauses), for applications that drop fast input",
+	"nfc.keyboard_layout":             "Keyboard layout of the machine the UID is typed on, so symbols use the right keys: us, de or fr. Symbols needing AltGr can't be typed",
+	"nfc.capslock_strategy":           "How CAPS Lock is handled while typing: toggle (switch it off and back on) or compensate (leave it on and invert Shift for letters, for machines where toggling is blocked; detection is Windows only)",
+	"nfc.windows_unicode_input":       "Windows only: type characters by codepoint (SendInput with KEYEVENTF_UNICODE) so non-US keyboard layouts don't mistype them",
+	"nfc.require_text_focus":          "Windows only: type the UID only when the focused control is a text field, not a button, list or menu; controls that can't be told apart (e.g. browser content) count as text fields",
+	"nfc.text_focus_wait_ms":          "How long the UID is held back waiting for a text field with require_text_focus before it's skipped with an error sound (0 = skip at once, max 10000)",
+	"nfc.on_write_error":              "Typing error partway through the output, e.g. lost focus: keep (leave the typed part), erase (backspace the typed characters) or retry (erase and type once more, unless Enter, Tab or Escape was already typed)",
+	"nfc.trigger":                     "When to read: auto (every presented card), manual (the card on the reader when triggered by SIGUSR1 on Linux/macOS, the -stdio trigger command (required on Windows) or Reader.Trigger) or hotkey (the card on the reader when trigger_hotkey is pressed, Windows and Linux); error sound without a card",
+	"nfc.trigger_hotkey":              "Global hotkey reading the card with trigger hotkey: ctrl, shift, alt or super joined by + with a-z, 0-9, f1-f12 or space, e.g. ctrl+alt+n. On Linux the user needs to be in the input group",
+	"nfc.wait_for_release":            "Wait for the card to be removed before reading the next one",
+	"nfc.present_beep":                "Pulse the reader buzzer once per card as soon as it is connected, before it is read and typed (ACS readers like the ACR122U, no-op on readers without buzzer control)",
+	"nfc.strict_release":              "Only read a card after the reader was seen empty since its last read (also at startup), so a card swapped in without lifting the first is ignored",
+	"nfc.ignore_first_scan":           "Ignore a card that is already on the reader at startup until it is removed, so a forgotten card isn't typed into the login screen",
+	"nfc.reemit_interval_ms":          "Type the output again every this many milliseconds while the card stays on the reader, for displays that need a steady signal. Needs wait_for_release, at least 100 (0 = disabled)",
+	"nfc.startup_grace_ms":            "Ignore cards presented within this many milliseconds after startup, they have to be presented again afterwards (0 = disabled)",
+	"nfc.debounce_ms":                 "Ignore repeated reads of the same UID within this window (ms) when not waiting for release, must be positive then",
+	"nfc.idle_alert_minutes":          "Show a notification once when no card was read for this many minutes while scanning (0 = disabled)",
+	"nfc.allowed_atr_prefixes":        "Only accept cards whose ATR starts with one of these hex prefixes, e.g. [\"3B 8F 80 01 80 4F 0C A0 00 00 03 06 03\"]; other cards are rejected with the error sound before anything is typed (empty = accept all)",
+	"nfc.split_output":                "Split output for two-field forms: types the UID, the separator, then a parity value",
+	"nfc.split_output.enabled":        "Enable split output",
+	"nfc.split_output.parity":         "even/odd: parity digit over all UID bits, xor: XOR of all bytes as hex",
+	"nfc.split_output.separator":      "Character between UID and parity (same options as end_char)",
+	"nfc.duplicate_output":            "Duplicate output for two-field forms that check the second field against the first: types the complete output (prefixes, template, output_regex and script applied), the separator, then the same output again",
+	"nfc.duplicate_output.enabled":    "Enable duplicate output, can't be combined with split_output",
+	"nfc.duplicate_output.separator":  "Character between the two copies (same options as end_char)",
+	"nfc.read_all":                    "Read every card in the field at once (inventory counting), ACR122U and other PN532 based readers only, at most 2 ISO14443A cards; other readers fall back to one card",
+	"nfc.read_all.enabled":            "Enable reading all cards, requires read_mode uid",
+	"nfc.read_all.separator":          "Character between the UIDs of the cards (same options as end_char)",
+	"nfc.sequence":                    "Number the typed scans for the {seq} output token, a scan that fails to type keeps its number",
+	"nfc.sequence.enabled":            "Enable the scan number, output_format must contain {seq}",
+	"nfc.sequence.width":              "Zero-pad the scan number to this many digits (1-20)",
+	"nfc.sequence.persist_file":       "File keeping the count across restarts, empty to count from 1 every session",
+	"nfc.repeat_alert":                "Flag one card presented many times in quick succession as possible card sharing or tailgating",
+	"nfc.repeat_alert.enabled":        "Enable the repeat alert: a warning in the log and event log plus a notification",
+	"nfc.repeat_alert.count":          "Presentations of the same UID within the window that raise the alert (at least 2)",
+	"nfc.repeat_alert.window_seconds": "Period in seconds the presentations are counted in",
+	"nfc.repeat_alert.block_seconds":  "Don't type the UID for this many seconds after an alert (0 = only alert)",
+	"nfc.wiegand":                     "Type the UID as fixed width Wiegand number: facility code then card number, zero padded (26-bit: FFFCCCCC); replaces hex/decimal",
+	"nfc.wiegand.enabled":             "Enable Wiegand output",
+	"nfc.wiegand.facility_code":       "Facility code typed before the card number, 0 up to the facility_bits maximum",
+	"nfc.wiegand.facility_bits":       "Facility code bits, sets its width (26-bit: 8, 37-bit H10304: 16)",
+	"nfc.wiegand.card_bits":           "Card number bits taken from the UID (low bits, decimal byte order), sets its width (26-bit: 16, 37-bit H10304: 19)",
+	"nfc.reader_profiles":             "Per-reader output overrides keyed by device number or reader name, e.g. \"2\": {end_char: none, prefix: \"OUT:\"}; keys: end_char, in_char, prefix, decimal, on_release_keys",
+	"nfc.read_mode":                   "What to read from the card: uid (card UID) or mifare_block (data block of a Mifare Classic card)",
+	"nfc.warmup_read":                 "Send one GET DATA right after connecting and ignore its result, for readers whose first command after connecting fails",
+	"nfc.tag_standard":                "Tag family for reading the UID: 14443 (ISO14443, most cards), 15693 (ISO15693 vicinity tags, 8 byte UID) or auto (detect from the card ATR)",
+	"nfc.mifare_block":                "Block to read when read_mode is mifare_block",
+	"nfc.mifare_block.block":          "Absolute block number (sector * 4 + block in sector), e.g. 4 for the first block of sector 1",
+	"nfc.mifare_block.key_type":       "Key used to authenticate the sector: A or B",
+	"nfc.mifare_block.key":            "Sector key as 12 hex digits",
+
+	"web.open_website": "Whether to open a browser window when the application starts",
+	"web.website_url":  "URL to open in the browser (http or https)",
+	"web.fullscreen":   "Try to open browser in fullscreen mode",
+
+	"notifications.enabled":                 "Enable system notifications",
+	"notifications.show_success":            "Show a notification when a card is read again after errors; independent of the success sound (audio.enabled)",
+	"notifications.show_errors":             "Show notifications for errors and issues",
+	"notifications.restart_cooldown":        "Seconds to hold back error notifications after an automatic self-restart (0 = no cooldown)",
+	"notifications.max_send_failures":       "Suspend desktop notifications after this many failed deliveries in a row (0 = never suspend)",
+	"notifications.throttle_jitter":         "Extend the throttle window of repeated error notifications by a random 0..N seconds, so a fleet hit by the same outage doesn't alert in lockstep (0 = off)",
+	"notifications.categories":              "Switch error notifications per category, e.g. card-error: false; categories: pc-sc-context, reader-error, card-error, keyboard-error, browser-error, service-error, general-error (missing = on)",
+	"notifications.templates":               "Custom notification texts for your branding, empty texts use the built-in ones. Placeholders: {uid}, {device}, {message}, {title}",
+	"notifications.templates.success_title": "Title of the success notification, e.g. \"{device}: Karte gelesen\"",
+	"notifications.templates.success_body":  "Text of the success notification (default \"Card UID: {uid}\")",
+	"notifications.templates.error_title":   "Title of error notifications, a repeat count like (x3) is appended",
+	"notifications.templates.error_body":    "Text of error notifications (default \"{message}\")",
+	"notifications.templates.info_title":    "Title of informational notifications (default \"{title}\")",
+	"notifications.templates.info_body":     "Text of informational notifications (default \"{message}\")",
+
+	"audio.enabled":       "Enable audio feedback for successful scans and errors",
+	"audio.success_sound": "Success sound: \"beep\", \"none\", or path to custom sound file",
+	"audio.error_sound":   "Error sound: \"error\", \"none\", or path to custom sound file",
+	"audio.volume":        "Volume level (0-100, currently not implemented but reserved for future use)",
+	"audio.pc_speaker":    "Linux: drive the PC speaker via the console when no beep tool is installed (needs write access to /dev/console or /dev/tty0)",
+
+	"advanced.retry_attempts":         "Number of times to retry failed system operations (PC/SC context, reader connection)",
+	"advanced.reconnect_delay":        "Seconds to wait before attempting to reconnect after disconnection",
+	"advanced.max_reconnect_delay":    "Upper limit in seconds for the reconnect delay, which doubles after each failed attempt",
+	"advanced.outage_alert_after":     "Seconds the reader may be unavailable before an outage notification is shown (0 = never)",
+	"advanced.watchdog_seconds":       "Restart the card reading loop when it hangs for this many seconds, e.g. on a reader that stopped answering; a hang that persists counts towards max_context_failures (0 = disabled, minimum 10)",
+	"advanced.auto_reconnect":         "Automatically attempt to reconnect to readers when disconnected",
+	"advanced.self_restart":           "Enable automatic application restart on critical failures",
+	"advanced.restart_mode":           "How the application restarts: self (starts a new process) or supervised (exits with supervised_exit_code so systemd or NSSM restarts it)",
+	"advanced.supervised_exit_code":   "Exit code of restart_mode supervised, distinct from the exit code 1 of errors (2-255)",
+	"advanced.restart_pcscd":          "Linux: run pcscd_restart_command once max_context_failures is reached, before the self-restart. Needs privileges to restart the service",
+	"advanced.pcscd_restart_command":  "Command that restarts the PC/SC service for restart_pcscd, run without a shell",
+	"advanced.max_context_failures":   "Max consecutive PC/SC failures before restart",
+	"advanced.restart_delay":          "Seconds to wait before restarting",
+	"advanced.log_timestamp_format":   "Log timestamp format: empty for the default, iso8601 (e.g. 2006-01-02T15:04:05Z07:00) or a Go time layout",
+	"advanced.log_rate_limit":         "Identical log messages in a row per second before further repeats are dropped and summarized as \"repeated N times\", keeps error loops from flooding the log (0 = no limit)",
+	"advanced.console_verbose_scan":   "Print the progress of every scan (waiting, connecting, writing, release); when false only errors and one line per read card are printed",
+	"advanced.log_level":              "Log level: \"info\" or \"debug\" (debug also logs raw PC/SC reader state transitions and card read retries)",
+	"advanced.card_read_attempts":     "Number of times to try reading a card before giving up",
+	"advanced.card_read_delay_ms":     "Base delay between card read attempts (ms)",
+	"advanced.keyboard_init_attempts": "Number of times to try initializing the virtual keyboard before the service loop fails",
+	"advanced.scan_history_size":      "Number of recent scans (UID, output, reader, result, time) kept for support and listed as recent_scans in the status file and the -stdio status (0 = disabled)",
+	"advanced.scan_history_mask_uids": "Keep only the last 4 hex digits of the UID in the scan history and leave out the typed output",
+	"advanced.status_file":            "Write the current status (uptime, last scan, last error, scanning) as JSON to this file on every change, for external monitoring (empty = off)",
+	"advanced.keyboard_init_delay_ms": "Base delay between keyboard init attempts (ms), grows with each attempt",
+
+	"updates.enabled":              "Enable automatic update checking",
+	"updates.check_on_startup":     "Check for updates on application startup",
+	"updates.auto_download":        "Automatically download available updates",
+	"updates.auto_install":         "Automatically install downloaded updates (requires restart)",
+	"updates.check_interval_hours": "Check interval in hours (for future periodic checks)",
+
+	"event_log.enabled": "Append one JSON object per scan, error, reconnect and restart to the event log",
+	"event_log.path":    "Event log file, relative paths are resolved from the working directory",
+	"pipe.enabled":      "Write the output of every scan as a line to a named pipe while a program reads it",
+	"pipe.path":         "FIFO created on Linux and macOS (default $XDG_RUNTIME_DIR/nfcuid.pipe, an existing FIFO must belong to the user with mode 0600), or named pipe on Windows (default \\\\.\\pipe\\nfcuid)",
+}
+
+// GenerateDefaultConfig renders the default configuration as YAML with a comment for every key
+func GenerateDefaultConfig() ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(DefaultConfig()); err != nil {
+		return nil, fmt.Errorf("failed to encode default config: %v", err)
+	}
+
+	annotateConfigNode(&doc, "")
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal default config: %v", err)
+	}
+	encoder.Close()
+
+	return buf.Bytes(), nil
+}
+
+// annotateConfigNode attaches the documentation comments to the keys of a mapping node
+func annotateConfigNode(node *yaml.Node, prefix string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		path := key.Value
+		if prefix != "" {
+			path = prefix + "." + key.Value
+		}
+
+		if prefix == "" {
+			key.HeadComment = configSectionDocs[path]
+		} else {
+			key.HeadComment = configFieldDocs[path]
+		}
+
+		if value.Kind == yaml.MappingNode {
+			annotateConfigNode(value, path)
+		}
+	}
+}
+
+// WriteDefaultConfig writes the commented default configuration, refusing to
+// overwrite an existing file unless force is set
+func WriteDefaultConfig(path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+	}
+
+	data, err := GenerateDefaultConfig()
+	if err != nil {
+		return err
+	}
+
+	content := []string{
+		"# NFC UID Reader Configuration",
+		"# Generated with 'nfcuid -init-config', edit as needed",
+	}
+
+	// Keep a blank line before each section for readability
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "# ") && (i == 0 || !strings.HasPrefix(lines[i-1], "#")) {
+			content = append(content, "")
+		}
+		content = append(content, line)
+	}
+
+	return os.WriteFile(path, []byte(strings.Join(content, "\n")), 0644)
+}
@@ -0,0 +1,55 @@
+package nfcuid
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteDefaultConfigRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	if err := WriteDefaultConfig(path, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.NFC.Device = 42 // Must be overwritten by the file
+	if err := loadConfigFromFile(config, path, ""); err != nil {
+		t.Fatalf("Generated config failed to load: %v", err)
+	}
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("Generated config is invalid: %v", err)
+	}
+	loaded, _ := MarshalConfig(config, "yaml")
+	defaults, _ := MarshalConfig(DefaultConfig(), "yaml")
+	if loaded != defaults {
+		t.Errorf("Generated config doesn't round-trip to the defaults, got:\n%s\nexpected:\n%s", loaded, defaults)
+	}
+
+	if err := WriteDefaultConfig(path, false); err == nil {
+		t.Errorf("Expected existing config to be left alone without force")
+	}
+	if err := WriteDefaultConfig(path, true); err != nil {
+		t.Errorf("Expected force to overwrite existing config, got: %v", err)
+	}
+}
+
+func TestGenerateDefaultConfigDocumentsEveryKey(t *testing.T) {
+	data, err := GenerateDefaultConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Every key line must be preceded by its comment line
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		if i == 0 || !strings.HasPrefix(strings.TrimSpace(lines[i-1]), "#") {
+			t.Errorf("Config key without documentation: %s", trimmed)
+		}
+	}
+}
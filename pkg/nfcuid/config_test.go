@@ -0,0 +1,322 @@
+package nfcuid
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateWebsiteURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		isValid bool
+		name    string
+	}{
+		{"https://example.com", true, "https url"},
+		{"http://localhost:3000/checkin", true, "http url with port and path"},
+		{"HTTPS://example.com", true, "uppercase scheme"},
+		{"https://app.kitafino.de/sys_k2/index.php?action=login", true, "url with query"},
+		{"example.com", false, "missing scheme"},
+		{"javascript:alert(1)", false, "javascript scheme"},
+		{"file:///etc/passwd", false, "file scheme"},
+		{"data:text/html,<script>", false, "data scheme"},
+		{"https://", false, "missing host"},
+		{"http://exa mple.com", false, "unparsable url"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateWebsiteURL(test.url)
+			if test.isValid && err != nil {
+				t.Errorf("Expected %s to be valid, got error: %v", test.url, err)
+			}
+			if !test.isValid && err == nil {
+				t.Errorf("Expected %s to be rejected", test.url)
+			}
+		})
+	}
+}
+
+func TestValidateConfigWebsiteURL(t *testing.T) {
+	config := DefaultConfig()
+	config.Web.WebsiteURL = "javascript:alert(1)"
+
+	// URL is only validated when the website is actually opened
+	if err := validateConfig(config); err != nil {
+		t.Errorf("Unexpected error with open_website disabled: %v", err)
+	}
+
+	config.Web.OpenWebsite = true
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected invalid website URL to be rejected")
+	}
+}
+
+func TestValidateConfigLegacyFormat(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.LegacyFormat = "vendor_x"
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected unknown legacy format to be rejected")
+	}
+
+	config.NFC.LegacyFormat = "decimal10"
+	config.NFC.Wiegand.Enabled = true
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected legacy format combined with wiegand to be rejected")
+	}
+}
+
+func TestValidateConfigDebounce(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.DebounceMs = 0
+	if err := validateConfig(config); err != nil {
+		t.Errorf("Unexpected error with wait_for_release: %v", err)
+	}
+
+	config.NFC.WaitForRelease = false
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected no debounce without wait_for_release to be rejected")
+	}
+}
+
+func TestMarshalConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.EndChar = "enter"
+
+	yamlOutput, err := MarshalConfig(config, "yaml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(yamlOutput, "end_char: enter") {
+		t.Errorf("Expected YAML to use config file key names, got:\n%s", yamlOutput)
+	}
+
+	jsonOutput, err := MarshalConfig(config, "json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var decoded map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonOutput), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got error: %v", err)
+	}
+	if decoded["nfc"]["end_char"] != "enter" {
+		t.Errorf("Expected nfc.end_char to be enter, got %v", decoded["nfc"]["end_char"])
+	}
+
+	if _, err := MarshalConfig(config, "toml"); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
+
+func TestFindUnknownConfigKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		unknown []string
+	}{
+		{"valid config", "nfc:\n  decimal: true\n  end_char: enter\n", nil},
+		{"reader profile", "nfc:\n  reader_profiles:\n    exit:\n      prefix: OUT\n      end_chr: none\n", []string{"line 5: nfc.reader_profiles.exit.end_chr"}},
+		{"misspelled nested key", "nfc:\n  split_output:\n    partiy: odd\n", []string{"line 3: nfc.split_output.partiy"}},
+		{"misspelled nfc key", "nfc:\n  decimel: true\n", []string{"line 2: nfc.decimel"}},
+		{"unknown section", "nfcc:\n  device: 1\n", []string{"line 1: nfcc"}},
+		{"multiple keys", "nfc:\n  decimel: true\n  revers: true\nweb:\n  open_website: true\n", []string{"line 2: nfc.decimel", "line 3: nfc.revers"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unknown := findUnknownConfigKeys([]byte(test.yaml))
+			if len(unknown) != len(test.unknown) {
+				t.Fatalf("Expected %d unknown keys, got %v", len(test.unknown), unknown)
+			}
+			for i, key := range test.unknown {
+				if unknown[i] != key {
+					t.Errorf("Expected %q, got %q", key, unknown[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigFromFileIgnoresUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("nfc:\n  decimel: true\n  reverse: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	if err := loadConfigFromFile(config, path, ""); err != nil {
+		t.Fatalf("Unknown keys should not be fatal, got: %v", err)
+	}
+	if !config.NFC.Reverse {
+		t.Error("Expected known keys to still be applied")
+	}
+	if config.NFC.Decimal {
+		t.Error("Expected misspelled key to be ignored")
+	}
+}
+
+func TestConfigPathFromArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+		explicit bool
+		name     string
+	}{
+		{nil, "config.yaml", false, "no arguments"},
+		{[]string{"-device", "1"}, "config.yaml", false, "other flags only"},
+		{[]string{"-config", "/etc/nfcuid/config.yaml"}, "/etc/nfcuid/config.yaml", true, "single dash"},
+		{[]string{"-device", "1", "--config", "kiosk.yaml"}, "kiosk.yaml", true, "double dash after other flags"},
+		{[]string{"-config=/opt/nfcuid.yaml"}, "/opt/nfcuid.yaml", true, "with equals sign"},
+		{[]string{"-init-config"}, "config.yaml", false, "similar flag name"},
+		{[]string{"--", "-config", "x.yaml"}, "config.yaml", false, "after end of flags"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, explicit := configPathFromArgs(test.args)
+			if path != test.expected || explicit != test.explicit {
+				t.Errorf("Expected %q (explicit=%v), got %q (explicit=%v)", test.expected, test.explicit, path, explicit)
+			}
+		})
+	}
+}
+
+func TestStdioFromArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+		name     string
+	}{
+		{nil, false, "no arguments"},
+		{[]string{"-device", "1", "-stdio"}, true, "single dash"},
+		{[]string{"--stdio"}, true, "double dash"},
+		{[]string{"-stdio=true"}, true, "with equals sign"},
+		{[]string{"-stdio=false"}, false, "disabled"},
+		{[]string{"-stdio-log"}, false, "similar flag name"},
+		{[]string{"--", "-stdio"}, false, "after end of flags"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if stdio := StdioFromArgs(test.args); stdio != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, stdio)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFromFileProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `nfc:
+  end_char: enter
+  reverse: true
+profiles:
+  lane-1:
+    nfc:
+      device: 1
+  lane-2: &lane2
+    nfc:
+      device: 2
+      end_char: tab
+  lane-2-decimal:
+    <<: *lane2
+    advanced:
+      log_level: debug
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		profile  string
+		device   int
+		endChar  string
+		logLevel string
+		name     string
+	}{
+		{"", 0, "enter", "info", "no profile"},
+		{"lane-1", 1, "enter", "info", "profile keeps unset file values"},
+		{"lane-2", 2, "tab", "info", "profile overrides file values"},
+		{"lane-2-decimal", 2, "tab", "debug", "profile built from an anchor"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			if err := loadConfigFromFile(config, path, test.profile); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if config.NFC.Device != test.device || config.NFC.EndChar != test.endChar || config.Advanced.LogLevel != test.logLevel {
+				t.Errorf("Expected device %d, end char %s, log level %s, got %d, %s, %s",
+					test.device, test.endChar, test.logLevel, config.NFC.Device, config.NFC.EndChar, config.Advanced.LogLevel)
+			}
+			if !config.NFC.Reverse {
+				t.Error("Expected the file values to apply under the profile")
+			}
+		})
+	}
+
+	err := loadConfigFromFile(DefaultConfig(), path, "lane-3")
+	if err == nil || !strings.Contains(err.Error(), "lane-1, lane-2, lane-2-decimal") {
+		t.Errorf("Expected a missing profile error listing the profiles, got: %v", err)
+	}
+}
+
+func TestFindUnknownConfigKeysInProfiles(t *testing.T) {
+	data := []byte("nfc:\n  reverse: true\nprofiles:\n  lane-1:\n    nfc:\n      devise: 1\n")
+
+	unknown := findUnknownConfigKeys(data)
+	if len(unknown) != 1 || unknown[0] != "line 6: profiles.lane-1.nfc.devise" {
+		t.Errorf("Expected only the misspelled profile key, got %v", unknown)
+	}
+}
+
+func TestDelimiter(t *testing.T) {
+	tests := []struct {
+		delimiter string
+		layout    string
+		expected  string
+		valid     bool
+		name      string
+	}{
+		{"/", "us", "04/a2/2b/91", true, "slash"},
+		{".", "de", "04.a2.2b.91", true, "dot on de"},
+		{"\\", "us", "04\\\\a2\\\\2b\\\\91", true, "backslash escaped"},
+		{"\\", "de", "", false, "backslash needs altgr on de"},
+		{"//", "us", "", false, "more than one character"},
+		{"€", "us", "", false, "not on the layout"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.InChar = "hyphen"
+			config.NFC.KeyboardLayout = test.layout
+			config.Delimiter = test.delimiter
+			err := validateConfig(config)
+			if !test.valid {
+				if err == nil {
+					t.Errorf("Expected delimiter %q to be rejected", test.delimiter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+
+			s := newTestService(config)
+			if result := s.formatOutput([]byte{0x04, 0xa2, 0x2b, 0x91}, "Reader 0"); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.Delimiter = "/"
+	config.NFC.LegacyFormat = "reversed_hex"
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected a delimiter with a legacy format to be rejected")
+	}
+}
@@ -0,0 +1,151 @@
+package nfcuid
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event types written to the event log. They are a stable contract for downstream
+// consumers, only add new types or fields, never rename them.
+const (
+	EventScan        = "scan"         // A card was read and typed
+	EventError       = "error"        // A card or reader error
+	EventReconnect   = "reconnect"    // The service is about to reconnect to the reader
+	EventRestart     = "restart"      // The application restarts itself
+	EventPcscd       = "pcscd"        // The PC/SC service was restarted with restart_pcscd
+	EventRepeatAlert = "repeat_alert" // A UID was presented repeat_alert.count times within its window
+)
+
+// Event is one line of the event log
+type Event struct {
+	Time         time.Time `json:"time"`
+	Type         string    `json:"type"`
+	UID          string    `json:"uid,omitempty"`           // scan, repeat_alert: raw UID as hex, before output formatting
+	Reader       string    `json:"reader,omitempty"`        // scan, error, repeat_alert: reader name if known
+	Message      string    `json:"message,omitempty"`       // error: error message; pcscd: restart error, empty on success; repeat_alert: alert text
+	Attempt      int       `json:"attempt,omitempty"`       // reconnect: consecutive reconnect attempt
+	DelaySeconds int       `json:"delay_seconds,omitempty"` // reconnect, restart: wait before reconnecting/restarting
+	Operation    string    `json:"operation,omitempty"`     // restart, pcscd: PC/SC operation that kept failing
+	CapsLock     string    `json:"caps_lock,omitempty"`     // scan: CAPS Lock protection, unavailable, off, toggled or compensated
+}
+
+// eventLogReopenInterval is how often the event log file is checked for having been
+// deleted, and how often a failed event log is reopened
+const eventLogReopenInterval = 30 * time.Second
+
+// EventLogger appends events as JSON lines to a file. A nil EventLogger discards all
+// events.
+//
+// When the file can't be written (disk full, directory removed) events are dropped with
+// a single warning and the service keeps running; the file is reopened periodically.
+type EventLogger struct {
+	mu             sync.Mutex
+	path           string
+	file           *os.File // nil while the event log is failed
+	lastCheck      time.Time
+	reopenInterval time.Duration
+}
+
+// NewEventLogger opens the event log for appending, or returns nil if it is disabled
+func NewEventLogger(config *Config) (*EventLogger, error) {
+	if !config.EventLog.Enabled {
+		return nil, nil
+	}
+
+	file, err := openEventLog(config.EventLog.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %v", err)
+	}
+	return &EventLogger{
+		path:           config.EventLog.Path,
+		file:           file,
+		lastCheck:      time.Now(),
+		reopenInterval: eventLogReopenInterval,
+	}, nil
+}
+
+// openEventLog opens the event log file for appending, creating it if needed
+func openEventLog(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+// Log writes one event, setting its time if unset. Each event is written with a
+// single unbuffered write, so tailing consumers never see partial lines.
+func (el *EventLogger) Log(event Event) {
+	if el == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		fmt.Printf("Failed to encode event: %v\n", err)
+		return
+	}
+
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	if time.Since(el.lastCheck) >= el.reopenInterval {
+		el.lastCheck = time.Now()
+		el.reopenIfNeeded()
+	}
+	if el.file == nil {
+		return
+	}
+
+	if _, err := el.file.Write(append(data, '\n')); err != nil {
+		el.fail(err)
+	}
+}
+
+// reopenIfNeeded reopens the event log after a failure, or when the file was deleted
+// while open (writes would go to the deleted file)
+func (el *EventLogger) reopenIfNeeded() {
+	if el.file != nil {
+		if _, err := os.Stat(el.path); err == nil {
+			return
+		}
+		el.file.Close()
+	}
+
+	file, err := openEventLog(el.path)
+	if err != nil {
+		el.fail(err)
+		return
+	}
+	if el.file == nil {
+		fmt.Fprintf(os.Stderr, "Event log %s is writable again, resuming\n", el.path)
+	}
+	el.file = file
+}
+
+// fail stops writing to the event log after a write or reopen error, warning only on
+// the first error until the event log works again
+func (el *EventLogger) fail(err error) {
+	if el.file == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: event log %s is not writable (%v), dropping events until it can be reopened\n", el.path, err)
+	el.file.Close()
+	el.file = nil
+}
+
+// Close closes the event log file
+func (el *EventLogger) Close() error {
+	if el == nil {
+		return nil
+	}
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	if el.file == nil {
+		return nil
+	}
+	err := el.file.Close()
+	el.file = nil
+	return err
+}
@@ -0,0 +1,193 @@
+package nfcuid
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+func TestEventLoggerSerialization(t *testing.T) {
+	eventTime := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		event    Event
+		expected string
+		name     string
+	}{
+		{
+			Event{Time: eventTime, Type: EventScan, UID: "04a22b91", Reader: "ACS ACR122U"},
+			`{"time":"2024-05-01T12:30:00Z","type":"scan","uid":"04a22b91","reader":"ACS ACR122U"}`,
+			"scan",
+		},
+		{
+			Event{Time: eventTime, Type: EventError, Reader: "ACS ACR122U", Message: "card is mute"},
+			`{"time":"2024-05-01T12:30:00Z","type":"error","reader":"ACS ACR122U","message":"card is mute"}`,
+			"error",
+		},
+		{
+			Event{Time: eventTime, Type: EventReconnect, Attempt: 3, DelaySeconds: 8},
+			`{"time":"2024-05-01T12:30:00Z","type":"reconnect","attempt":3,"delay_seconds":8}`,
+			"reconnect",
+		},
+		{
+			Event{Time: eventTime, Type: EventRestart, Operation: "PC/SC Context", DelaySeconds: 10},
+			`{"time":"2024-05-01T12:30:00Z","type":"restart","delay_seconds":10,"operation":"PC/SC Context"}`,
+			"restart",
+		},
+	}
+
+	config := DefaultConfig()
+	config.EventLog.Enabled = true
+	config.EventLog.Path = filepath.Join(t.TempDir(), "events.jsonl")
+	eventLogger, err := NewEventLogger(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, test := range tests {
+		eventLogger.Log(test.event)
+	}
+	if err := eventLogger.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(config.EventLog.Path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != len(tests) {
+		t.Fatalf("Expected %d lines, got %d: %q", len(tests), len(lines), data)
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if lines[i] != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, lines[i])
+			}
+		})
+	}
+}
+
+func TestEventLoggerDisabled(t *testing.T) {
+	eventLogger, err := NewEventLogger(DefaultConfig())
+	if err != nil || eventLogger != nil {
+		t.Fatalf("Expected no event logger when disabled, got %v, %v", eventLogger, err)
+	}
+
+	// A disabled event logger discards events
+	eventLogger.Log(Event{Type: EventScan})
+	if err := eventLogger.Close(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestProcessCardLogsScanEvent(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states: map[string][]scard.StateFlag{
+			"Reader 0": {scard.StatePresent, scard.StateEmpty},
+		},
+		card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+	}
+	config := DefaultConfig()
+	config.NFC.Reverse = true
+	config.EventLog.Enabled = true
+	config.EventLog.Path = filepath.Join(t.TempDir(), "events.jsonl")
+	eventLogger, err := NewEventLogger(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s := newTestService(config)
+	s.eventLogger = eventLogger
+
+	if err := s.readNextCard(ctx, ctx.readers, &mockKeyboard{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	eventLogger.Close()
+
+	data, err := os.ReadFile(config.EventLog.Path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Expected one JSON event, got %q: %v", data, err)
+	}
+	if event.Type != EventScan || event.UID != "04a22b91" || event.Reader != "Reader 0" {
+		t.Errorf("Expected a scan event with the unformatted UID, got %+v", event)
+	}
+}
+
+func TestEventLoggerRecoversFromWriteFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	config := DefaultConfig()
+	config.EventLog.Enabled = true
+	config.EventLog.Path = filepath.Join(dir, "events.jsonl")
+	eventLogger, err := NewEventLogger(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer eventLogger.Close()
+
+	// The logs directory is removed while running and writes start failing
+	eventLogger.Log(Event{Type: EventScan, UID: "01"})
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	eventLogger.file.Close()
+
+	eventLogger.Log(Event{Type: EventScan, UID: "02"})
+	if eventLogger.file != nil {
+		t.Fatal("Expected the event log to be disabled after a write failure")
+	}
+
+	// Reopening fails as long as the directory is missing
+	eventLogger.reopenInterval = 0
+	eventLogger.Log(Event{Type: EventScan, UID: "03"})
+	if eventLogger.file != nil {
+		t.Fatal("Expected the event log to stay disabled while the directory is missing")
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	eventLogger.Log(Event{Type: EventScan, UID: "04"})
+
+	data, err := os.ReadFile(config.EventLog.Path)
+	if err != nil {
+		t.Fatalf("Expected the event log to be reopened: %v", err)
+	}
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil || event.UID != "04" {
+		t.Errorf("Expected only the event after reopening, got %q", data)
+	}
+}
+
+func TestEventLoggerReopensDeletedFile(t *testing.T) {
+	config := DefaultConfig()
+	config.EventLog.Enabled = true
+	config.EventLog.Path = filepath.Join(t.TempDir(), "events.jsonl")
+	eventLogger, err := NewEventLogger(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer eventLogger.Close()
+	eventLogger.reopenInterval = 0
+
+	if err := os.Remove(config.EventLog.Path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	eventLogger.Log(Event{Type: EventScan, UID: "01"})
+
+	data, err := os.ReadFile(config.EventLog.Path)
+	if err != nil || !strings.Contains(string(data), `"uid":"01"`) {
+		t.Errorf("Expected the deleted event log to be recreated, got %q, %v", data, err)
+	}
+}
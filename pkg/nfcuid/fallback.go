@@ -0,0 +1,104 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ebfe/scard"
+)
+
+// pnpNotification is the PC/SC pseudo reader whose state changes when a reader is
+// attached or removed
+const pnpNotification = `\\?PnP?\Notification`
+
+// errReadersChanged ends the card wait with nfc.fallback_device when the watched reader
+// disappeared, or a reader was attached while the fallback reader is in use
+var errReadersChanged = errors.New("readers changed")
+
+// hasFallback reports whether a fallback reader is configured
+func (s *service) hasFallback() bool {
+	return s.config.NFC.FallbackDevice != ""
+}
+
+// watchesAttach reports whether the card wait also watches for attached readers, to
+// switch back to the primary reader once it returns
+func (s *service) watchesAttach() bool {
+	return s.onFallback && s.config.NFC.SwitchBack && !s.noAttachNotify
+}
+
+// resolvePrimary finds the primary reader, nfc.device or the single nfc.devices entry.
+// Once found it's tracked by name, device numbers shift when a reader is removed.
+func (s *service) resolvePrimary(readers []string) (string, error) {
+	if s.primaryReader != "" {
+		if containsString(readers, s.primaryReader) {
+			return s.primaryReader, nil
+		}
+		return "", fmt.Errorf("primary reader %s not connected", s.primaryReader)
+	}
+	if len(s.flags.Devices) > 0 {
+		return resolveReader(readers, s.flags.Devices[0])
+	}
+	return resolveReader(readers, strconv.Itoa(s.flags.Device))
+}
+
+// selectPrimaryOrFallback picks the primary reader, or the fallback reader while the
+// primary isn't connected. Switching to the fallback and back is reported.
+func (s *service) selectPrimaryOrFallback(readers []string) ([]string, error) {
+	primary, primaryErr := s.resolvePrimary(readers)
+	fallback, fallbackErr := resolveReader(readers, s.config.NFC.FallbackDevice)
+	if primaryErr == nil && fallbackErr == nil && primary == fallback {
+		// A device number points at the fallback reader when the primary is missing
+		primaryErr = fmt.Errorf("primary device resolves to the fallback reader %s", fallback)
+	}
+	if primaryErr == nil {
+		s.primaryReader = primary
+	}
+
+	// Without switch_back the fallback stays in use until the next restart of the loop finds it gone
+	useFallback := primaryErr != nil || (s.onFallback && !s.config.NFC.SwitchBack && fallbackErr == nil)
+	if useFallback && fallbackErr != nil {
+		return nil, fmt.Errorf("%v, fallback device: %v", primaryErr, fallbackErr)
+	}
+
+	switch {
+	case useFallback && !s.onFallback:
+		fmt.Printf("Primary reader not available (%v), switching to fallback reader %s\n", primaryErr, fallback)
+		s.notificationManager.NotifyErrorThrottled("reader-fallback", fmt.Sprintf("Hauptlesegerät nicht verfügbar, Ersatzlesegerät %s wird verwendet.", fallback))
+		s.recordError("", fmt.Sprintf("primary reader not available, using fallback reader %s", fallback))
+	case !useFallback && s.onFallback:
+		fmt.Printf("Primary reader %s is back, switching back from the fallback reader\n", primary)
+		s.notificationManager.NotifyInfo("NFC Lesegerät", fmt.Sprintf("Hauptlesegerät %s wieder verbunden", primary))
+	}
+	s.onFallback = useFallback
+
+	selected := primary
+	if useFallback {
+		selected = fallback
+	}
+	fmt.Printf("Selected device: %s\n", selected)
+	s.applyReaderProfiles(readers)
+	return []string{selected}, nil
+}
+
+// readersChanged reports whether the states after a wait show a removed reader or,
+// past the first wait, a change of the attached readers
+func readersChanged(rs []scard.ReaderState) bool {
+	for _, state := range rs {
+		if state.Reader == pnpNotification {
+			if state.CurrentState != scard.StateUnaware && state.EventState&scard.StateChanged != 0 {
+				return true
+			}
+			continue
+		}
+		if state.EventState&(scard.StateUnknown|scard.StateUnavailable) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isReaderGone reports whether a card wait error means a watched reader is gone
+func isReaderGone(err error) bool {
+	return errors.Is(err, scard.ErrUnknownReader) || errors.Is(err, scard.ErrReaderUnavailable) || errors.Is(err, scard.ErrNoReadersAvailable)
+}
@@ -0,0 +1,131 @@
+package nfcuid
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+// fallbackTestService returns a service with "Primary" as primary and "Backup" as fallback reader
+func fallbackTestService(t *testing.T, switchBack bool) *service {
+	config := DefaultConfig()
+	config.NFC.Devices = []string{"Primary"}
+	config.NFC.FallbackDevice = "Backup"
+	config.NFC.SwitchBack = switchBack
+	config.NFC.EndChar = "enter"
+	config.Advanced.AutoReconnect = false
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	return newTestService(config)
+}
+
+func TestFallbackTakesOverRemovedPrimary(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Primary Reader", "Backup Reader"},
+		card:    &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+	}
+	s := fallbackTestService(t, true)
+
+	selectedReaders, err := s.selectReaders(ctx.readers)
+	if err != nil {
+		t.Fatalf("Unexpected error selecting readers: %v", err)
+	}
+	if len(selectedReaders) != 1 || selectedReaders[0] != "Primary Reader" {
+		t.Fatalf("Expected only the primary reader to be watched, got %v", selectedReaders)
+	}
+
+	// The primary is unplugged while waiting, the card is then presented to the fallback
+	ctx.readers = []string{"Backup Reader"}
+	ctx.states = map[string][]scard.StateFlag{
+		"Primary Reader": {scard.StateUnknown},
+		"Backup Reader":  {scard.StatePresent, scard.StateEmpty},
+	}
+	kb := &mockKeyboard{}
+	if err := s.cardReadingLoop(ctx, selectedReaders, kb); !errors.Is(err, errMockExhausted) {
+		t.Fatalf("Expected the loop to end with the exhausted mock, got %v", err)
+	}
+	if result := kb.text(); result != "04a22b91\n" {
+		t.Errorf("Expected the card on the fallback reader to be typed, got %q", result)
+	}
+	if !s.onFallback {
+		t.Errorf("Expected the fallback reader to be in use")
+	}
+}
+
+func TestFallbackSwitchBack(t *testing.T) {
+	tests := []struct {
+		switchBack bool
+		expected   string
+		name       string
+	}{
+		{true, "Primary Reader", "switch back"},
+		{false, "Backup Reader", "stay on fallback"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := fallbackTestService(t, test.switchBack)
+
+			// The primary is missing at startup
+			selectedReaders, err := s.selectReaders([]string{"Backup Reader"})
+			if err != nil {
+				t.Fatalf("Unexpected error selecting readers: %v", err)
+			}
+			if selectedReaders[0] != "Backup Reader" || !s.onFallback {
+				t.Fatalf("Expected the fallback reader to be used, got %v", selectedReaders)
+			}
+
+			// The primary is plugged in, the PnP reader reports the change after its first state
+			ctx := &mockContext{
+				readers: []string{"Backup Reader", "Primary Reader"},
+				states: map[string][]scard.StateFlag{
+					"Backup Reader":  {scard.StateEmpty, scard.StateEmpty},
+					pnpNotification:  {0x10000, 0x20000 | scard.StateChanged},
+					"Primary Reader": {scard.StatePresent, scard.StateEmpty},
+				},
+				card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+			}
+			kb := &mockKeyboard{}
+			err = s.cardReadingLoop(ctx, selectedReaders, kb)
+			if !errors.Is(err, errMockExhausted) {
+				t.Fatalf("Expected the loop to end with the exhausted mock, got %v", err)
+			}
+
+			typed := kb.text() == "04a22b91\n"
+			if typed != test.switchBack {
+				t.Errorf("Expected the card on the primary typed: %v, got %q", test.switchBack, kb.text())
+			}
+			if s.onFallback != !test.switchBack {
+				t.Errorf("Expected fallback in use: %v, got %v", !test.switchBack, s.onFallback)
+			}
+		})
+	}
+}
+
+func TestFallbackValidation(t *testing.T) {
+	tests := []struct {
+		device  int
+		devices []string
+		valid   bool
+		name    string
+	}{
+		{1, nil, true, "device number"},
+		{0, []string{"ACR1252"}, true, "single devices entry"},
+		{0, nil, false, "interactive selection"},
+		{0, []string{"1", "2"}, false, "several devices"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.Device = test.device
+			config.NFC.Devices = test.devices
+			config.NFC.FallbackDevice = "ACR122"
+			if err := validateConfig(config); (err == nil) != test.valid {
+				t.Errorf("Expected valid %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package nfcuid
+
+import "fmt"
+
+// layoutKey is the key typing a character on a keyboard layout, given as the character
+// the same physical key types on the US layout, and whether Shift is held
+type layoutKey struct {
+	usKey string
+	shift bool
+}
+
+// keyboardLayouts holds, per layout, the characters typed differently than on the US
+// layout. Characters that need AltGr or a dead key on the layout have an empty usKey,
+// they can't be typed with key codes (use windows_unicode_input on Windows).
+var keyboardLayouts = map[string]map[string]layoutKey{
+	"us": {},
+	"de": {
+		"y": {"z", false}, "Y": {"z", true}, "z": {"y", false}, "Z": {"y", true},
+
+		"!": {"1", true}, "\"": {"2", true}, "§": {"3", true}, "$": {"4", true}, "%": {"5", true},
+		"&": {"6", true}, "/": {"7", true}, "(": {"8", true}, ")": {"9", true}, "=": {"0", true},
+
+		"ß": {"-", false}, "?": {"-", true},
+		"ü": {"[", false}, "Ü": {"[", true}, "+": {"]", false}, "*": {"]", true},
+		"ö": {";", false}, "Ö": {";", true}, "ä": {"'", false}, "Ä": {"'", true},
+		"#": {"\\", false}, "'": {"\\", true},
+		",": {",", false}, ";": {",", true}, ".": {".", false}, ":": {".", true},
+		"-": {"/", false}, "_": {"/", true}, "°": {"`", true},
+
+		"@": {}, "[": {}, "]": {}, "{": {}, "}": {}, "\\": {}, "|": {}, "~": {},
+		"<": {}, ">": {}, "^": {}, "`": {},
+	},
+	"fr": {
+		"a": {"q", false}, "A": {"q", true}, "q": {"a", false}, "Q": {"a", true},
+		"z": {"w", false}, "Z": {"w", true}, "w": {"z", false}, "W": {"z", true},
+		"m": {";", false}, "M": {";", true},
+
+		"1": {"1", true}, "2": {"2", true}, "3": {"3", true}, "4": {"4", true}, "5": {"5", true},
+		"6": {"6", true}, "7": {"7", true}, "8": {"8", true}, "9": {"9", true}, "0": {"0", true},
+		"&": {"1", false}, "é": {"2", false}, "\"": {"3", false}, "'": {"4", false}, "(": {"5", false},
+		"-": {"6", false}, "è": {"7", false}, "_": {"8", false}, "ç": {"9", false}, "à": {"0", false},
+
+		")": {"-", false}, "°": {"-", true}, "=": {"=", false}, "+": {"=", true},
+		"$": {"]", false}, "£": {"]", true}, "ù": {"'", false}, "%": {"'", true},
+		"*": {"\\", false}, "µ": {"\\", true},
+		",": {"m", false}, "?": {"m", true}, ";": {",", false}, ".": {",", true},
+		":": {".", false}, "/": {".", true}, "!": {"/", false}, "§": {"/", true}, "²": {"`", false},
+
+		"@": {}, "#": {}, "[": {}, "]": {}, "{": {}, "}": {}, "\\": {}, "|": {}, "~": {},
+		"`": {}, "^": {}, "<": {}, ">": {},
+	},
+}
+
+// typedKeys maps the characters typed by KeyboardWrite to keys on the active layout
+var typedKeys = names
+
+// KeyboardLayoutOptions lists the keyboard layout names for help and error messages
+func KeyboardLayoutOptions() string {
+	return "us, de, fr"
+}
+
+// keysForLayout builds the character to key table for the layout from the US table
+func keysForLayout(layout string) (map[string]keySet, error) {
+	overrides, ok := keyboardLayouts[layout]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyboard layout: %s (options: %s)", layout, KeyboardLayoutOptions())
+	}
+
+	keys := make(map[string]keySet, len(names))
+	for char, key := range names {
+		keys[char] = key
+	}
+	for char, key := range overrides {
+		if key.usKey == "" {
+			delete(keys, char)
+			continue
+		}
+		keys[char] = keySet{names[key.usKey].code, key.shift}
+	}
+	return keys, nil
+}
+
+// SetKeyboardLayout sets the layout used to type characters, keeping the US layout if it is unknown
+func SetKeyboardLayout(layout string) {
+	if keys, err := keysForLayout(layout); err == nil {
+		typedKeys = keys
+	}
+}
@@ -0,0 +1,128 @@
+package nfcuid
+
+import "testing"
+
+func TestKeysForLayout(t *testing.T) {
+	tests := []struct {
+		layout  string
+		char    string
+		usKey   string
+		shift   bool
+		isValid bool
+		name    string
+	}{
+		{"us", ":", ";", true, true, "us colon is shift+semicolon"},
+		{"de", ":", ".", true, true, "de colon is shift+period"},
+		{"fr", ":", ".", false, true, "fr colon is the period key"},
+		{"us", "-", "-", false, true, "us hyphen is the minus key"},
+		{"de", "-", "/", false, true, "de hyphen is the slash key"},
+		{"fr", "-", "6", false, true, "fr hyphen is the 6 key"},
+		{"de", "/", "7", true, true, "de slash is shift+7"},
+		{"fr", "/", ".", true, true, "fr slash is shift+period"},
+		{"de", "z", "y", false, true, "de swaps y and z"},
+		{"fr", "1", "1", true, true, "fr digits need shift"},
+		{"fr", "a", "q", false, true, "fr swaps a and q"},
+		{"uk", ":", "", false, false, "unknown layout"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys, err := keysForLayout(test.layout)
+			if !test.isValid {
+				if err == nil {
+					t.Errorf("Expected error for layout %s", test.layout)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expected := keySet{names[test.usKey].code, test.shift}
+			if key := keys[test.char]; key != expected {
+				t.Errorf("Expected %q to be typed as %v, got %v", test.char, expected, key)
+			}
+		})
+	}
+}
+
+func TestKeysForLayoutAltGr(t *testing.T) {
+	for _, layout := range []string{"de", "fr"} {
+		keys, err := keysForLayout(layout)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, ok := keys["@"]; ok {
+			t.Errorf("Expected @ to be untypeable on layout %s, it needs AltGr", layout)
+		}
+		if _, ok := keys["ENTER"]; !ok {
+			t.Errorf("Expected ENTER to be kept on layout %s", layout)
+		}
+	}
+}
+
+func TestKeyboardWriteLayout(t *testing.T) {
+	SetKeyboardLayout("de")
+	defer SetKeyboardLayout("us")
+
+	kb := &mockKeyboard{}
+	if err := KeyboardWrite("04:a2", kb, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	colon := kb.typed[2]
+	if expected := (keySet{names["."].code, true}); colon != expected {
+		t.Errorf("Expected colon typed as %v on the de layout, got %v", expected, colon)
+	}
+}
+
+func TestKeyboardWriteLayoutSkipsAltGr(t *testing.T) {
+	SetKeyboardLayout("de")
+	defer SetKeyboardLayout("us")
+
+	kb := &mockKeyboard{}
+	if err := KeyboardWrite("a@b", kb, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The @ needs AltGr on the de layout and is skipped instead of typed as key code 0
+	if len(kb.typed) != 2 || kb.typed[1] != names["b"] {
+		t.Errorf("Expected only a and b typed, got %v", kb.typed)
+	}
+}
+
+func TestValidateTypable(t *testing.T) {
+	tests := []struct {
+		prefix       string
+		prefixFrom   string
+		outputFormat string
+		layout       string
+		valid        bool
+		name         string
+	}{
+		{"", "static", "{uid}", "de", true, "default"},
+		{"S1-", "static", "{reader}: {uid}\\n", "de", true, "typable text and escapes"},
+		{"@", "static", "{uid}", "us", true, "at sign on us"},
+		{"@", "static", "{uid}", "de", false, "prefix needs altgr"},
+		{"@", "hostname", "{uid}", "de", true, "prefix not typed"},
+		{"", "static", "[{uid}]", "fr", false, "output format needs altgr"},
+		{"", "static", "{uid}\\\\", "de", false, "escaped backslash needs altgr"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.Prefix = test.prefix
+			config.NFC.PrefixFrom = test.prefixFrom
+			config.NFC.OutputFormat = test.outputFormat
+			config.NFC.KeyboardLayout = test.layout
+			err := validateTypable(config)
+			if test.valid && err != nil {
+				t.Errorf("Unexpected validation error: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("Expected the text to be rejected")
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package nfcuid
 
 import "github.com/micmonay/keybd_event"
 
@@ -108,5 +108,6 @@ var (
 		"ENTER":     keySet{keybd_event.VK_ENTER, false},
 		"TAB":       keySet{keybd_event.VK_TAB, false},
 		"BACKSPACE": keySet{keybd_event.VK_DELETE, false},
+		"ESCAPE":    keySet{keybd_event.VK_ESC, false},
 	}
 )
@@ -0,0 +1,55 @@
+package nfcuid
+
+import (
+	"sort"
+	"strings"
+)
+
+// legacyFormat is a named UID format of an existing access or time tracking system,
+// expressed as the output flags that reproduce it
+type legacyFormat struct {
+	Reverse        bool
+	Decimal        bool
+	DecimalPadding int
+	CapsLock       bool
+	InChar         CharFlag // Separator between the UID bytes
+	NoEndKeys      bool     // The receiving system takes the UID without end_char or end_sequence
+}
+
+// legacyFormats holds the nfc.legacy_format presets. Decimal output reads the (possibly
+// reversed) UID bytes little-endian, see UIDToUint32.
+var legacyFormats = map[string]legacyFormat{
+	// Big-endian decimal of the reversed 4-byte UID, 10 digits: 04 a2 2b 91 -> 2435555844
+	"reversed_decimal10": {Decimal: true, DecimalPadding: 10},
+	// Big-endian decimal of the UID as read, 10 digits: 04 a2 2b 91 -> 0077736849
+	"decimal10": {Reverse: true, Decimal: true, DecimalPadding: 10},
+	// Reversed UID as uppercase hex without separators: 04 a2 2b 91 -> 912BA204
+	"reversed_hex": {Reverse: true, CapsLock: true},
+	// UID as uppercase hex bytes separated like a MAC address: 04 a2 2b 91 -> 04:A2:2B:91
+	"mac": {CapsLock: true, InChar: CharFlagColon, NoEndKeys: true},
+}
+
+// LegacyFormatOptions lists the legacy format preset names for help and error messages
+func LegacyFormatOptions() string {
+	var names []string
+	for name := range legacyFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// apply sets the output flags of the preset, replacing the individual format settings
+// and, for systems that take the UID without them, the end keys
+func (lf legacyFormat) apply(flags *Flags) {
+	flags.Reverse = lf.Reverse
+	flags.SwapNibbles = false
+	flags.Decimal = lf.Decimal
+	flags.DecimalPadding = lf.DecimalPadding
+	flags.CapsLock = lf.CapsLock
+	flags.InChar = lf.InChar
+	if lf.NoEndKeys {
+		flags.EndChar = CharFlagNone
+		flags.EndSequence = nil
+	}
+}
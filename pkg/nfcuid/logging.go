@@ -0,0 +1,243 @@
+package nfcuid
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// LogLevel controls how much diagnostic output is written to the log
+type LogLevel int
+
+const (
+	LogLevelInfo LogLevel = iota
+	LogLevelDebug
+)
+
+var logLevelStrings = map[LogLevel]string{
+	LogLevelInfo:  "info",
+	LogLevelDebug: "debug",
+}
+
+// currentLogLevel is the active log level, set once from the configuration at startup
+var currentLogLevel = LogLevelInfo
+
+// StringToLogLevel converts a configured log level name to a LogLevel
+func StringToLogLevel(s string) (LogLevel, bool) {
+	for k, v := range logLevelStrings {
+		if v == s {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// SetLogLevel sets the active log level
+func SetLogLevel(level LogLevel) {
+	currentLogLevel = level
+}
+
+// logTimestampPresets maps named timestamp formats to Go time layouts
+var logTimestampPresets = map[string]string{
+	"iso8601": time.RFC3339,
+	"rfc3339": time.RFC3339,
+}
+
+// SetLogTimestampFormat switches the log timestamp to the given format: a preset
+// name (iso8601, rfc3339) or a Go time layout. Empty keeps Go's default format.
+func SetLogTimestampFormat(format string) {
+	if format == "" {
+		return
+	}
+	if layout, ok := logTimestampPresets[strings.ToLower(format)]; ok {
+		format = layout
+	}
+
+	log.SetFlags(0)
+	log.SetOutput(&timestampWriter{out: os.Stderr, layout: format, now: time.Now})
+}
+
+// timestampWriter prefixes every log entry with a timestamp in a custom layout.
+// The log package writes each entry with a single Write call.
+type timestampWriter struct {
+	mu     sync.Mutex
+	out    io.Writer
+	layout string
+	now    func() time.Time // Replaceable in tests
+}
+
+func (w *timestampWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := io.WriteString(w.out, w.now().Format(w.layout)+" "); err != nil {
+		return 0, err
+	}
+	return w.out.Write(p)
+}
+
+// stdLogHeaderLayout is the timestamp the log package writes with its default flags
+const stdLogHeaderLayout = "2006/01/02 15:04:05 "
+
+// SetLogRateLimit collapses runs of identical log messages: beyond perSecond in one
+// second they are dropped and summarized as "repeated N times" once the second or the
+// run ends. It wraps the current log output, so call it after SetLogTimestampFormat.
+// 0 disables it.
+func SetLogRateLimit(perSecond int) {
+	if perSecond <= 0 {
+		return
+	}
+
+	// The log package would stamp every line, making repeats differ, so the limiter
+	// takes over the default timestamp
+	var header string
+	if log.Flags()&(log.Ldate|log.Ltime) != 0 {
+		header = stdLogHeaderLayout
+		log.SetFlags(0)
+	}
+	limiter := &repeatLimiter{out: log.Writer(), header: header, perSecond: perSecond, now: time.Now}
+	log.SetOutput(limiter)
+	RegisterShutdown("log rate limit", ShutdownLogs, limiter.flush)
+}
+
+// repeatLimiter is a log output that drops identical consecutive messages beyond
+// perSecond per second and reports how many were dropped
+type repeatLimiter struct {
+	mu          sync.Mutex
+	out         io.Writer
+	header      string // Timestamp layout written before each entry, empty for none
+	perSecond   int
+	now         func() time.Time // Replaceable in tests
+	last        string           // Message of the current run
+	windowStart time.Time        // Start of the one second window of the current run
+	inWindow    int              // Messages of the run written in the current window
+	dropped     int              // Messages of the run dropped and not yet reported
+	flushTimer  *time.Timer      // Reports the dropped messages when the window ends, nil while none are dropped
+}
+
+func (w *repeatLimiter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	message := string(p)
+	if message == w.last {
+		if now.Sub(w.windowStart) < time.Second {
+			if w.inWindow >= w.perSecond {
+				w.dropped++
+				if w.flushTimer == nil {
+					// The run may end without another message, e.g. when the reader hangs
+					w.flushTimer = time.AfterFunc(time.Second-now.Sub(w.windowStart), func() { w.flush() })
+				}
+				return len(p), nil
+			}
+			w.inWindow++
+			return len(p), w.write(now, message)
+		}
+	}
+
+	// A new run or a new window of the same run
+	if err := w.reportDropped(now); err != nil {
+		return 0, err
+	}
+	w.last = message
+	w.windowStart = now
+	w.inWindow = 1
+	return len(p), w.write(now, message)
+}
+
+// flush writes the summary of the dropped repeats without waiting for another message
+func (w *repeatLimiter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.reportDropped(w.now())
+}
+
+// reportDropped writes the summary of the dropped repeats, if any
+func (w *repeatLimiter) reportDropped(now time.Time) error {
+	if w.flushTimer != nil {
+		w.flushTimer.Stop()
+		w.flushTimer = nil
+	}
+	if w.dropped == 0 {
+		return nil
+	}
+	summary := fmt.Sprintf("Last message repeated %d times: %s", w.dropped, w.last)
+	w.dropped = 0
+	return w.write(now, summary)
+}
+
+// write writes one entry with the timestamp header
+func (w *repeatLimiter) write(now time.Time, message string) error {
+	if w.header != "" {
+		message = now.Format(w.header) + message
+	}
+	_, err := io.WriteString(w.out, message)
+	return err
+}
+
+// logDebugf writes a log entry only when debug logging is enabled
+func logDebugf(format string, args ...interface{}) {
+	if currentLogLevel < LogLevelDebug {
+		return
+	}
+	log.Printf("[DEBUG] "+format, args...)
+}
+
+// logWarnf writes a warning log entry, at every log level
+func logWarnf(format string, args ...interface{}) {
+	log.Printf("[WARN] "+format, args...)
+}
+
+// stateFlagNames lists the PC/SC reader state flags in display order
+var stateFlagNames = []struct {
+	flag scard.StateFlag
+	name string
+}{
+	{scard.StateIgnore, "Ignore"},
+	{scard.StateChanged, "Changed"},
+	{scard.StateUnknown, "Unknown"},
+	{scard.StateUnavailable, "Unavailable"},
+	{scard.StateEmpty, "Empty"},
+	{scard.StatePresent, "Present"},
+	{scard.StateAtrmatch, "AtrMatch"},
+	{scard.StateExclusive, "Exclusive"},
+	{scard.StateInuse, "InUse"},
+	{scard.StateMute, "Mute"},
+	{scard.StateUnpowered, "Unpowered"},
+}
+
+// FormatStateFlags renders PC/SC reader state flags as a readable list, e.g. "Present|InUse"
+func FormatStateFlags(state scard.StateFlag) string {
+	var names []string
+	for _, f := range stateFlagNames {
+		if state&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	if len(names) == 0 {
+		return "Unaware"
+	}
+	return strings.Join(names, "|")
+}
+
+// logReaderStateTransitions writes a debug entry for every reader whose state changed
+func logReaderStateTransitions(rs []scard.ReaderState) {
+	if currentLogLevel < LogLevelDebug {
+		return
+	}
+	for i := range rs {
+		// Ignore the changed bit and the event counter pcsc-lite keeps in the upper bits
+		previous := rs[i].CurrentState & 0xFFFF &^ scard.StateChanged
+		current := rs[i].EventState & 0xFFFF &^ scard.StateChanged
+		if previous != current {
+			logDebugf("Reader %q state: %s -> %s (0x%x)", rs[i].Reader, FormatStateFlags(previous), FormatStateFlags(current), uint32(rs[i].EventState))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package nfcuid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimestampWriter(t *testing.T) {
+	now := time.Date(2026, 3, 14, 9, 26, 53, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		layout   string
+		expected string
+		name     string
+	}{
+		{logTimestampPresets["iso8601"], "2026-03-14T09:26:53+01:00 Card read\n", "iso8601 preset"},
+		{"2006-01-02 15:04:05.000", "2026-03-14 09:26:53.000 Card read\n", "custom Go layout"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := log.New(&timestampWriter{out: &out, layout: test.layout, now: func() time.Time { return now }}, "", 0)
+			logger.Println("Card read")
+
+			if result := out.String(); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestRetryManagerLogsRetries(t *testing.T) {
+	tests := []struct {
+		logf     func(format string, args ...interface{})
+		level    LogLevel
+		expected []string
+		name     string
+	}{
+		{logWarnf, LogLevelInfo, []string{"[WARN] Attempt 1 failed: no reader. Retrying in 0s...", "[WARN] Attempt 2 failed: no reader. Retrying in 0s..."}, "warnings at info level"},
+		{logDebugf, LogLevelDebug, []string{"[DEBUG] Attempt 1 failed: no reader. Retrying in 0s...", "[DEBUG] Attempt 2 failed: no reader. Retrying in 0s..."}, "debug at debug level"},
+		{logDebugf, LogLevelInfo, nil, "debug silenced at info level"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			log.SetOutput(&out)
+			log.SetFlags(0)
+			SetLogLevel(test.level)
+			defer func() {
+				log.SetOutput(os.Stderr)
+				log.SetFlags(log.LstdFlags)
+				SetLogLevel(LogLevelInfo)
+			}()
+
+			rm := NewRetryManagerWithDelay(3, 0)
+			rm.SetLogger(test.logf)
+			if err := rm.Retry(func() error { return errors.New("no reader") }); err == nil {
+				t.Fatal("Expected the retries to fail")
+			}
+
+			var lines []string
+			if logged := strings.TrimSpace(out.String()); logged != "" {
+				lines = strings.Split(logged, "\n")
+			}
+			if strings.Join(lines, "|") != strings.Join(test.expected, "|") {
+				t.Errorf("Expected %q, got %q", test.expected, lines)
+			}
+		})
+	}
+}
+
+func TestRetryManagerPermanentError(t *testing.T) {
+	errWrongKey := errors.New("wrong key")
+	rm := NewRetryManagerWithDelay(3, 0)
+	rm.SetLogger(func(format string, args ...interface{}) {})
+
+	attempts := 0
+	err := rm.Retry(func() error {
+		attempts++
+		return permanent(fmt.Errorf("authentication failed: %w", errWrongKey))
+	})
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d", attempts)
+	}
+	if !errors.Is(err, errWrongKey) || err.Error() != "authentication failed: wrong key" {
+		t.Errorf("Expected the unwrapped error, got %v", err)
+	}
+}
+
+func TestRepeatLimiterCollapsesRepeats(t *testing.T) {
+	var out bytes.Buffer
+	now := time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)
+	limiter := &repeatLimiter{out: &out, perSecond: 10, now: func() time.Time { return now }}
+	logger := log.New(limiter, "", 0)
+
+	for i := 0; i < 100; i++ {
+		logger.Println("Card read failed")
+	}
+	logger.Println("Card read")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 12 {
+		t.Fatalf("Expected 10 messages, the summary and the next message, got %d lines: %q", len(lines), lines)
+	}
+	if lines[9] != "Card read failed" {
+		t.Errorf("Expected the first 10 messages to be written, got %q", lines[9])
+	}
+	if lines[10] != "Last message repeated 90 times: Card read failed" {
+		t.Errorf("Expected a summary of the dropped messages, got %q", lines[10])
+	}
+	if lines[11] != "Card read" {
+		t.Errorf("Expected the next message to be written, got %q", lines[11])
+	}
+
+	// The run continues in a new window after a second
+	out.Reset()
+	now = now.Add(time.Second)
+	for i := 0; i < 12; i++ {
+		logger.Println("Card read")
+	}
+	logger.Println("Card removed")
+	expected := strings.Repeat("Card read\n", 10) + "Last message repeated 2 times: Card read\nCard removed\n"
+	if result := out.String(); result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestRepeatLimiterFlushesAfterWindow(t *testing.T) {
+	var out bytes.Buffer
+	start := time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC)
+	now := start
+	limiter := &repeatLimiter{out: &out, perSecond: 2, now: func() time.Time { return now }}
+	logger := log.New(limiter, "", 0)
+
+	logger.Println("Reader not responding")
+	logger.Println("Reader not responding")
+	// Dropped near the end of the window, no further message follows
+	now = start.Add(990 * time.Millisecond)
+	logger.Println("Reader not responding")
+
+	expected := "Reader not responding\nReader not responding\nLast message repeated 1 times: Reader not responding\n"
+	deadline := time.Now().Add(time.Second)
+	for {
+		limiter.mu.Lock()
+		result := out.String()
+		limiter.mu.Unlock()
+		if result == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the summary once the window ends, got %q", result)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,122 @@
+package nfcuid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// onceExitTimeout is the exit code of -once when no card was presented in time, apart
+// from 1 for errors and 2 for invalid flags
+const onceExitTimeout = 3
+
+// onceWaitChunk is how long one wait for a card lasts with -once-timeout 0, the wait
+// is repeated until a card is presented. Replaceable in tests.
+var onceWaitChunk = time.Minute
+
+// errNoCardPresented is returned when no card was presented before the timeout
+var errNoCardPresented = errors.New("no card presented")
+
+// OnceResult is the line -once prints to stdout for the card read
+type OnceResult struct {
+	UID    string `json:"uid"`    // Raw UID as hex, before output formatting
+	Reader string `json:"reader"` // Name of the reader the card was read on
+	Output string `json:"output"` // Formatted output, with Enter and Tab as line breaks and tabs
+}
+
+// RunOnce runs the -once command for scripts: it reads a single card, types it unless
+// -once-type=false, prints the result as JSON to stdout and exits with 0, or with 1 on
+// errors and onceExitTimeout when no card was presented within -once-timeout. All
+// other console output goes to stderr, so the last line of stdout is the result.
+func RunOnce(config *Config, notificationManager *NotificationManager, statusManager *StatusManager) {
+	// A failed read is reported through the exit code, not hidden behind a relaunch
+	config.Advanced.SelfRestart = false
+
+	result := os.Stdout
+	os.Stdout = os.Stderr
+
+	service := NewService(config.ToFlags(), config, notificationManager, NewRestartManager(config, notificationManager, nil), NewAudioManager(config), statusManager, nil).(*service)
+
+	scardCtx, err := scard.EstablishContext()
+	if err != nil {
+		SafeExit(1, fmt.Sprintf("Reading failed: PC/SC context: %v", err), nil)
+	}
+	ctx := scardContext{scardCtx}
+	defer ctx.Release()
+
+	var kb keyboard
+	if config.OnceType {
+		if kb, err = service.initKeyboard(); err != nil {
+			SafeExit(1, fmt.Sprintf("Reading failed: %v", err), nil)
+		}
+	}
+
+	err = service.readOnce(ctx, kb, time.Duration(config.OnceTimeout)*time.Second, result)
+	if errors.Is(err, errNoCardPresented) {
+		SafeExit(onceExitTimeout, fmt.Sprintf("Reading failed: %v", err), nil)
+	}
+	if err != nil {
+		SafeExit(1, fmt.Sprintf("Reading failed: %v", err), nil)
+	}
+	SafeExit(0, "", nil)
+}
+
+// readOnce waits for a card on the selected readers, reads and formats it, types the
+// output with kb (nil to only print it) and writes the result to out. A timeout of 0
+// waits until a card is presented.
+func (s *service) readOnce(ctx cardContext, kb keyboard, timeout time.Duration, out io.Writer) error {
+	readers, err := ctx.ListReaders()
+	if err != nil {
+		return fmt.Errorf("failed to list readers: %v", err)
+	}
+	if len(readers) == 0 {
+		return fmt.Errorf("no reader found")
+	}
+	selectedReaders, err := s.selectReaders(readers)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Waiting for a Card...")
+	index, mute, err := s.waitForCardTimeout(ctx, selectedReaders, timeout)
+	for timeout <= 0 && errors.Is(err, errNoCardPresented) {
+		index, mute, err = s.waitForCardTimeout(ctx, selectedReaders, onceWaitChunk)
+	}
+	if err != nil {
+		return err
+	}
+	reader := selectedReaders[index]
+	if mute {
+		return fmt.Errorf("card on %s is not responding (mute)", reader)
+	}
+
+	uid, err := s.connectAndRead(ctx, reader)
+	if err != nil {
+		return err
+	}
+	result := OnceResult{UID: fmt.Sprintf("%x", uid), Reader: reader}
+	output := s.formatOutput(uid, reader)
+	result.Output = strings.TrimSuffix(pipeLine(output), "\n")
+
+	if kb != nil {
+		if _, err := s.writeOutput(output, kb); err != nil {
+			return fmt.Errorf("failed to write keyboard output: %v", err)
+		}
+	}
+	if err := s.sequence.commit(); err != nil {
+		logWarnf("%v", err)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %v", err)
+	}
+	_, err = fmt.Fprintf(out, "%s\n", data)
+	return err
+}
@@ -0,0 +1,124 @@
+package nfcuid
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+func TestReadOnce(t *testing.T) {
+	uidResponse := []byte{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}
+
+	tests := []struct {
+		name     string
+		states   []scard.StateFlag
+		card     *mockCard
+		timeouts int
+		timeout  time.Duration
+		typed    bool
+		result   *OnceResult
+		err      error
+	}{
+		{
+			name:    "read and typed",
+			states:  []scard.StateFlag{scard.StateEmpty, scard.StatePresent},
+			card:    &mockCard{responses: [][]byte{uidResponse}},
+			timeout: time.Second,
+			typed:   true,
+			result:  &OnceResult{UID: "04a22b91", Reader: "Reader 0", Output: "04A22B91"},
+		},
+		{
+			name:    "only printed",
+			states:  []scard.StateFlag{scard.StatePresent},
+			card:    &mockCard{responses: [][]byte{uidResponse}},
+			timeout: time.Second,
+			result:  &OnceResult{UID: "04a22b91", Reader: "Reader 0", Output: "04A22B91"},
+		},
+		{
+			name:     "waits without a timeout",
+			states:   []scard.StateFlag{scard.StatePresent},
+			card:     &mockCard{responses: [][]byte{uidResponse}},
+			timeouts: 2,
+			result:   &OnceResult{UID: "04a22b91", Reader: "Reader 0", Output: "04A22B91"},
+		},
+		{
+			name:     "timeout",
+			timeouts: 1,
+			timeout:  10 * time.Millisecond,
+			err:      errNoCardPresented,
+		},
+		{
+			name:    "mute card",
+			states:  []scard.StateFlag{scard.StatePresent | scard.StateMute},
+			timeout: time.Second,
+		},
+		{
+			name:    "read fails",
+			states:  []scard.StateFlag{scard.StatePresent},
+			card:    &mockCard{responses: [][]byte{{0x63, 0x00}}},
+			timeout: time.Second,
+		},
+	}
+
+	defer func(chunk time.Duration) { onceWaitChunk = chunk }(onceWaitChunk)
+	onceWaitChunk = 10 * time.Millisecond
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.CapsLock = true
+			config.NFC.EndChar = "enter"
+			config.Advanced.CardReadAttempts = 1
+			s := newTestService(config)
+			ctx := &presentContext{
+				mockContext: &mockContext{
+					readers: []string{"Reader 0"},
+					states:  map[string][]scard.StateFlag{"Reader 0": test.states},
+					card:    test.card,
+				},
+				timeouts: test.timeouts,
+			}
+			kb := &mockKeyboard{}
+			var typing keyboard
+			if test.typed {
+				typing = kb
+			}
+
+			var out bytes.Buffer
+			err := s.readOnce(ctx, typing, test.timeout, &out)
+			if test.result == nil {
+				if err == nil {
+					t.Fatalf("Expected an error, got result %q", out.String())
+				}
+				if test.err != nil && !errors.Is(err, test.err) {
+					t.Errorf("Expected %v, got %v", test.err, err)
+				}
+				if out.Len() != 0 {
+					t.Errorf("Expected no result on error, got %q", out.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var result OnceResult
+			if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+				t.Fatalf("Expected a JSON result, got %q: %v", out.String(), err)
+			}
+			if result != *test.result {
+				t.Errorf("Expected %+v, got %+v", *test.result, result)
+			}
+			if expected := ""; !test.typed && kb.text() != expected {
+				t.Errorf("Expected nothing typed, got %q", kb.text())
+			}
+			if expected := "04A22B91\n"; test.typed && kb.text() != expected {
+				t.Errorf("Expected %q typed, got %q", expected, kb.text())
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package nfcuid
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// pcscdRestartTimeout is how long the pcscd restart command may take
+const pcscdRestartTimeout = 30 * time.Second
+
+// escalationStep is what the RestartManager does once max_context_failures is reached
+type escalationStep int
+
+const (
+	escalateNone        escalationStep = iota // Keep retrying, self-restart is disabled
+	escalatePcscd                             // Restart the PC/SC service with restart_pcscd
+	escalateSelfRestart                       // Restart the application
+)
+
+// escalation decides the next step after max_context_failures failures in a row. A
+// restart of pcscd comes first, once per failure streak and only on Linux, since a
+// self-restart can't bring back a dead pcscd.
+func (rm *RestartManager) escalation() escalationStep {
+	if rm.config.Advanced.RestartPcscd && rm.goos == "linux" && !rm.pcscdRestarted {
+		return escalatePcscd
+	}
+	if rm.config.Advanced.SelfRestart {
+		return escalateSelfRestart
+	}
+	return escalateNone
+}
+
+// restartPcscd runs the pcscd restart command and starts a new round of failures, so
+// the self-restart only follows if the PC/SC service keeps failing after its restart
+func (rm *RestartManager) restartPcscd(operation string) {
+	command := rm.config.Advanced.PcscdCommand
+	log.Printf("PC/SC %s keeps failing, restarting the PC/SC service: %s", operation, command)
+
+	rm.pcscdRestarted = true
+	rm.contextFailureCount = 0
+
+	event := Event{Type: EventPcscd, Operation: operation}
+	if output, err := rm.runCommand(command); err != nil {
+		logWarnf("PC/SC service restart failed: %v %s", err, strings.TrimSpace(string(output)))
+		event.Message = err.Error()
+	} else {
+		log.Printf("PC/SC service restarted")
+	}
+	rm.eventLogger.Log(event)
+}
+
+// runPcscdRestartCommand runs the command, split at spaces, without a shell
+func runPcscdRestartCommand(command string) ([]byte, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no pcscd restart command configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pcscdRestartTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, fields[0], fields[1:]...).CombinedOutput()
+}
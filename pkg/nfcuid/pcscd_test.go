@@ -0,0 +1,80 @@
+package nfcuid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRestartManagerEscalation(t *testing.T) {
+	tests := []struct {
+		restartPcscd   bool
+		selfRestart    bool
+		goos           string
+		pcscdRestarted bool
+		expected       escalationStep
+		name           string
+	}{
+		{false, true, "linux", false, escalateSelfRestart, "pcscd restart off"},
+		{false, false, "linux", false, escalateNone, "everything off"},
+		{true, true, "linux", false, escalatePcscd, "pcscd first"},
+		{true, true, "linux", true, escalateSelfRestart, "self-restart after pcscd"},
+		{true, false, "linux", true, escalateNone, "pcscd tried, self-restart off"},
+		{true, true, "windows", false, escalateSelfRestart, "pcscd ignored on windows"},
+		{true, false, "darwin", false, escalateNone, "pcscd ignored on macos"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Advanced.RestartPcscd = test.restartPcscd
+			config.Advanced.SelfRestart = test.selfRestart
+			rm := NewRestartManager(config, nil, nil)
+			rm.goos = test.goos
+			rm.pcscdRestarted = test.pcscdRestarted
+
+			if result := rm.escalation(); result != test.expected {
+				t.Errorf("Expected escalation %d, got %d", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestRestartManagerRestartsPcscdOncePerStreak(t *testing.T) {
+	config := DefaultConfig()
+	config.Advanced.RestartPcscd = true
+	config.Advanced.SelfRestart = false
+	config.Advanced.MaxContextFailures = 2
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	rm := NewRestartManager(config, nil, nil)
+	rm.goos = "linux"
+	var commands []string
+	rm.runCommand = func(command string) ([]byte, error) {
+		commands = append(commands, command)
+		return []byte("Job for pcscd.service failed"), errors.New("exit status 1")
+	}
+
+	// The restart starts a new round of failures, the next limit falls through to the disabled self-restart
+	for i := 0; i < 6; i++ {
+		if rm.TrackContextFailure(errors.New("context failure")) {
+			t.Fatalf("Expected no self-restart, failure %d triggered one", i+1)
+		}
+	}
+	if len(commands) != 1 || commands[0] != "systemctl restart pcscd" {
+		t.Fatalf("Expected pcscd to be restarted once, got %v", commands)
+	}
+
+	// A working context ends the streak, so the next one may restart pcscd again
+	rm.ResetFailureCount()
+	rm.TrackContextFailure(errors.New("context failure"))
+	rm.TrackContextFailure(errors.New("context failure"))
+	if len(commands) != 2 {
+		t.Errorf("Expected pcscd to be restarted again in a new streak, got %v", commands)
+	}
+
+	config.Advanced.PcscdCommand = " "
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected restart_pcscd without a command to be rejected")
+	}
+}
@@ -0,0 +1,49 @@
+package nfcuid
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	kiocsound        = 0x4B2F  // KIOCSOUND ioctl, starts or stops the PC speaker tone
+	pcSpeakerClockHz = 1193180 // Programmable interval timer frequency
+)
+
+// pcSpeakerDevices lists the console devices tried for driving the PC speaker
+var pcSpeakerDevices = []string{"/dev/console", "/dev/tty0"}
+
+// pcSpeakerBeep sounds the PC speaker directly through the kernel console driver.
+// This needs write access to the console device (root, or membership in the tty group).
+func pcSpeakerBeep(frequency int, duration time.Duration) error {
+	var lastErr error
+	for _, device := range pcSpeakerDevices {
+		console, err := os.OpenFile(device, os.O_WRONLY, 0)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if err := pcSpeakerIoctl(console, pcSpeakerClockHz/frequency); err != nil {
+			console.Close()
+			lastErr = fmt.Errorf("%s: %v", device, err)
+			continue
+		}
+		time.Sleep(duration)
+		err = pcSpeakerIoctl(console, 0)
+		console.Close()
+		return err
+	}
+	return lastErr
+}
+
+// pcSpeakerIoctl sets the PC speaker timer divisor, 0 stops the tone
+func pcSpeakerIoctl(console *os.File, divisor int) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, console.Fd(), kiocsound, uintptr(divisor))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
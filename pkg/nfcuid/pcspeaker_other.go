@@ -0,0 +1,13 @@
+//go:build !linux
+
+package nfcuid
+
+import (
+	"errors"
+	"time"
+)
+
+// pcSpeakerBeep is only implemented on Linux, other systems have their own system sounds
+func pcSpeakerBeep(frequency int, duration time.Duration) error {
+	return errors.New("PC speaker is only supported on Linux")
+}
@@ -0,0 +1,112 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pipeWriteTimeout is how long a write may wait for a reader that doesn't empty the pipe
+const pipeWriteTimeout = 2 * time.Second
+
+// errPipeNoReader is returned when no program has the named pipe open for reading
+var errPipeNoReader = errors.New("no reader connected")
+
+// PipeOutput writes the output of every scan as a line to a named pipe, a FIFO on
+// Linux and macOS or a named pipe on Windows, for programs on the same machine. A nil
+// PipeOutput discards all scans.
+//
+// Scans are only written while a reader has the pipe open, the service never waits for
+// one. A reader that goes away is dropped and the next one picks up with the next scan.
+type PipeOutput struct {
+	mu      sync.Mutex
+	pipe    *namedPipe
+	conn    io.WriteCloser // nil while no reader is connected
+	failing bool           // A write failed, warned until a write succeeds again
+}
+
+// NewPipeOutput creates the named pipe, or returns nil if the pipe output is disabled
+func NewPipeOutput(config *Config) (*PipeOutput, error) {
+	if !config.Pipe.Enabled {
+		return nil, nil
+	}
+
+	pipe, err := createNamedPipe(config.Pipe.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &PipeOutput{pipe: pipe}, nil
+}
+
+// HandleScan writes the output of the scan to the pipe, register it with RegisterScanHandler
+func (p *PipeOutput) HandleScan(event ScanEvent) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.write(pipeLine(event.Output))
+	switch {
+	case err == nil:
+		if p.failing {
+			fmt.Fprintf(os.Stderr, "Pipe %s is writable again, resuming\n", p.pipe.path)
+			p.failing = false
+		}
+	case errors.Is(err, errPipeNoReader):
+		logDebugf("No reader on pipe %s, scan not written", p.pipe.path)
+	case !p.failing:
+		logWarnf("Failed to write to pipe %s: %v", p.pipe.path, err)
+		p.failing = true
+	}
+}
+
+// write writes the line to the connected reader, connecting first if there is none. A
+// reader that went away since the last scan is dropped, a new one may be waiting.
+func (p *PipeOutput) write(line string) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if p.conn == nil {
+			conn, connectErr := p.pipe.connect()
+			if connectErr != nil {
+				return connectErr
+			}
+			p.conn = conn
+		}
+
+		if deadline, ok := p.conn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+			deadline.SetWriteDeadline(time.Now().Add(pipeWriteTimeout))
+		}
+		if _, err = io.WriteString(p.conn, line); err == nil {
+			return nil
+		}
+		p.conn.Close()
+		p.conn = nil
+	}
+	return err
+}
+
+// pipeLine turns the output as typed into one line of text: Enter and Tab become line
+// breaks and tabs, keys without a character (select all, Backspace, Escape) are dropped
+func pipeLine(output string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\\`, `\`, `\"`, `"`, `\a`, "", `\b`, "", `\e`, "")
+	return strings.TrimRight(replacer.Replace(output), "\r\n") + "\n"
+}
+
+// Close disconnects the reader and closes the pipe
+func (p *PipeOutput) Close() error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	return p.pipe.Close()
+}
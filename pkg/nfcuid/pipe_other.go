@@ -0,0 +1,70 @@
+//go:build !windows
+
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// defaultPipePath returns the default pipe.path, in the per-user runtime directory so
+// other users can't put a FIFO there first. Without XDG_RUNTIME_DIR (macOS) the temp
+// directory is per user as well.
+func defaultPipePath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "nfcuid.pipe")
+}
+
+// namedPipe is a FIFO in the file system
+type namedPipe struct {
+	path string
+}
+
+// createNamedPipe creates the FIFO unless it exists already, e.g. from the last run. An
+// existing FIFO is only used if it belongs to this user and nobody else can open it,
+// otherwise another user could have created it to read the scans.
+func createNamedPipe(path string) (*namedPipe, error) {
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := syscall.Mkfifo(path, 0600); err != nil {
+			return nil, fmt.Errorf("failed to create pipe %s: %v", path, err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to check pipe %s: %v", path, err)
+	case info.Mode()&os.ModeNamedPipe == 0:
+		return nil, fmt.Errorf("pipe path %s exists and is not a FIFO", path)
+	case info.Mode().Perm()&0077 != 0:
+		return nil, fmt.Errorf("pipe %s is accessible by other users (mode %v), remove it to have it created again", path, info.Mode().Perm())
+	default:
+		if stat, ok := info.Sys().(*syscall.Stat_t); !ok || int(stat.Uid) != os.Getuid() {
+			return nil, fmt.Errorf("pipe %s belongs to another user, remove it to have it created again", path)
+		}
+	}
+	return &namedPipe{path: path}, nil
+}
+
+// connect opens the FIFO for writing without waiting for a reader, returning
+// errPipeNoReader if no program has it open for reading
+func (p *namedPipe) connect() (io.WriteCloser, error) {
+	file, err := os.OpenFile(p.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if errors.Is(err, syscall.ENXIO) {
+		return nil, errPipeNoReader
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pipe: %v", err)
+	}
+	return file, nil
+}
+
+// Close keeps the FIFO, a reader may have it open across restarts of the service
+func (p *namedPipe) Close() error {
+	return nil
+}
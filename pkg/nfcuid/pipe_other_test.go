@@ -0,0 +1,66 @@
+//go:build !windows
+
+package nfcuid
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestPipeOutputUnsafeFifo(t *testing.T) {
+	tests := []struct {
+		setup func(path string) error
+		name  string
+	}{
+		{func(path string) error {
+			if err := syscall.Mkfifo(path, 0600); err != nil {
+				return err
+			}
+			return os.Chmod(path, 0666)
+		}, "readable by others"},
+		{func(path string) error {
+			if err := syscall.Mkfifo(path, 0600); err != nil {
+				return err
+			}
+			return os.Chmod(path, 0640)
+		}, "readable by the group"},
+		{func(path string) error {
+			target := path + ".target"
+			if err := syscall.Mkfifo(target, 0600); err != nil {
+				return err
+			}
+			return os.Symlink(target, path)
+		}, "symlink to a FIFO"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "nfcuid.pipe")
+			if err := test.setup(path); err != nil {
+				t.Fatalf("Failed to prepare the pipe path: %v", err)
+			}
+			config := DefaultConfig()
+			config.Pipe.Enabled = true
+			config.Pipe.Path = path
+			if p, err := NewPipeOutput(config); err == nil {
+				p.Close()
+				t.Errorf("Expected the FIFO to be rejected")
+			}
+		})
+	}
+}
+
+func TestDefaultPipePath(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if path := defaultPipePath(); path != "/run/user/1000/nfcuid.pipe" {
+		t.Errorf("Expected the pipe in the runtime directory, got %s", path)
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	if path := defaultPipePath(); !strings.HasPrefix(path, os.TempDir()) {
+		t.Errorf("Expected the pipe in the temp directory without a runtime directory, got %s", path)
+	}
+}
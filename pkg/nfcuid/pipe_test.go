@@ -0,0 +1,172 @@
+package nfcuid
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPipePath returns a pipe path unique to the test
+func testPipePath(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		return `\\.\pipe\nfcuid-test-` + strings.ReplaceAll(t.Name(), "/", "-")
+	}
+	return filepath.Join(t.TempDir(), "nfcuid.pipe")
+}
+
+// pipeReader is a program reading the pipe, like the companion app
+type pipeReader struct {
+	lines chan string
+	file  chan *os.File
+}
+
+// openPipeReader opens the pipe for reading on a goroutine, as opening a FIFO waits
+// for the writer to connect
+func openPipeReader(t *testing.T, path string) *pipeReader {
+	r := &pipeReader{lines: make(chan string, 10), file: make(chan *os.File, 1)}
+	go func() {
+		defer close(r.lines)
+		file, err := os.Open(path)
+		if err != nil {
+			t.Errorf("Failed to open pipe for reading: %v", err)
+			return
+		}
+		r.file <- file
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			r.lines <- scanner.Text()
+		}
+	}()
+	return r
+}
+
+// readLine returns the next line read from the pipe
+func (r *pipeReader) readLine(t *testing.T) string {
+	select {
+	case line, ok := <-r.lines:
+		if !ok {
+			t.Fatalf("Pipe closed before a line was read")
+		}
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out reading from pipe")
+	}
+	return ""
+}
+
+// close closes the pipe, it must have been opened
+func (r *pipeReader) close() {
+	file := <-r.file
+	file.Close()
+	for range r.lines {
+	}
+}
+
+// writeUntilConnected writes the line until the reader opening the pipe is connected
+func writeUntilConnected(t *testing.T, p *PipeOutput, line string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		err := p.write(line)
+		p.mu.Unlock()
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, errPipeNoReader) || time.Now().After(deadline) {
+			t.Fatalf("Failed to write to pipe: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newTestPipeOutput returns a pipe output on a pipe unique to the test
+func newTestPipeOutput(t *testing.T) (*PipeOutput, string) {
+	config := DefaultConfig()
+	config.Pipe.Enabled = true
+	config.Pipe.Path = testPipePath(t)
+	p, err := NewPipeOutput(config)
+	if err != nil {
+		t.Fatalf("Failed to create pipe output: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p, config.Pipe.Path
+}
+
+func TestPipeOutput(t *testing.T) {
+	p, path := newTestPipeOutput(t)
+
+	// Without a reader the scan is dropped instead of waiting for one
+	p.HandleScan(ScanEvent{Output: "dropped\\n"})
+
+	reader := openPipeReader(t, path)
+	defer reader.close()
+	writeUntilConnected(t, p, "connected\n")
+	p.HandleScan(ScanEvent{Output: "04a22b91\\n"})
+	p.HandleScan(ScanEvent{Output: "04a22b91\\t1234"})
+	p.HandleScan(ScanEvent{Output: "\\a\\b04\\\\91\\n\\n"})
+	for _, expected := range []string{"connected", "04a22b91", "04a22b91\t1234", "04\\91"} {
+		if line := reader.readLine(t); line != expected {
+			t.Errorf("Expected %q, got %q", expected, line)
+		}
+	}
+
+	// The next run finds the FIFO of the last one
+	if runtime.GOOS != "windows" {
+		config := DefaultConfig()
+		config.Pipe.Enabled = true
+		config.Pipe.Path = path
+		again, err := NewPipeOutput(config)
+		if err != nil {
+			t.Errorf("Expected the existing FIFO to be reused, got %v", err)
+		}
+		again.Close()
+	}
+}
+
+func TestPipeOutputReaderDisconnect(t *testing.T) {
+	p, path := newTestPipeOutput(t)
+
+	reader := openPipeReader(t, path)
+	writeUntilConnected(t, p, "first\n")
+	if line := reader.readLine(t); line != "first" {
+		t.Fatalf("Expected %q, got %q", "first", line)
+	}
+
+	// The reader goes away, the next scan finds no reader instead of failing
+	reader.close()
+	p.mu.Lock()
+	err := p.write("lost\n")
+	p.mu.Unlock()
+	if !errors.Is(err, errPipeNoReader) {
+		t.Errorf("Expected no reader after the reader closed, got %v", err)
+	}
+
+	// A new reader gets the scans from then on
+	reader = openPipeReader(t, path)
+	defer reader.close()
+	writeUntilConnected(t, p, "second\n")
+	if line := reader.readLine(t); line != "second" {
+		t.Errorf("Expected %q, got %q", "second", line)
+	}
+}
+
+func TestPipeOutputNotAFifo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("FIFOs are Linux and macOS only")
+	}
+	path := filepath.Join(t.TempDir(), "nfcuid.pipe")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	config := DefaultConfig()
+	config.Pipe.Enabled = true
+	config.Pipe.Path = path
+	if _, err := NewPipeOutput(config); err == nil {
+		t.Errorf("Expected a regular file at the pipe path to be rejected")
+	}
+}
@@ -0,0 +1,119 @@
+package nfcuid
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32            = syscall.NewLazyDLL("kernel32.dll")
+	createNamedPipeW    = kernel32.NewProc("CreateNamedPipeW")
+	connectNamedPipe    = kernel32.NewProc("ConnectNamedPipe")
+	disconnectNamedPipe = kernel32.NewProc("DisconnectNamedPipe")
+)
+
+// defaultPipePath returns the default pipe.path
+func defaultPipePath() string {
+	return `\\.\pipe\nfcuid`
+}
+
+const (
+	pipeAccessOutbound      = 0x00000002 // PIPE_ACCESS_OUTBOUND
+	pipeNoWait              = 0x00000001 // PIPE_NOWAIT, ConnectNamedPipe and WriteFile return at once
+	pipeRejectRemoteClients = 0x00000008 // PIPE_REJECT_REMOTE_CLIENTS
+	pipeBufferSize          = 4096
+
+	errorNoData        syscall.Errno = 232 // ERROR_NO_DATA, the reader closed the pipe
+	errorPipeConnected syscall.Errno = 535 // ERROR_PIPE_CONNECTED
+	errorPipeListening syscall.Errno = 536 // ERROR_PIPE_LISTENING
+)
+
+// namedPipe is the server end of a Windows named pipe with a single instance, so one
+// reader is connected at a time
+type namedPipe struct {
+	path   string
+	handle syscall.Handle
+}
+
+// createNamedPipe creates the pipe and waits for a reader without blocking
+func createNamedPipe(path string) (*namedPipe, error) {
+	if !strings.HasPrefix(path, `\\.\pipe\`) {
+		return nil, fmt.Errorf("pipe path %s must start with \\\\.\\pipe\\", path)
+	}
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipe path %s: %v", path, err)
+	}
+
+	handle, _, err := createNamedPipeW.Call(uintptr(unsafe.Pointer(name)), pipeAccessOutbound,
+		pipeNoWait|pipeRejectRemoteClients, 1, pipeBufferSize, 0, 0, 0)
+	if syscall.Handle(handle) == syscall.InvalidHandle {
+		return nil, fmt.Errorf("failed to create pipe %s: %v", path, err)
+	}
+	p := &namedPipe{path: path, handle: syscall.Handle(handle)}
+	p.listen()
+	return p, nil
+}
+
+// listen makes the pipe available to the next reader
+func (p *namedPipe) listen() {
+	connectNamedPipe.Call(uintptr(p.handle), 0)
+}
+
+// connect returns the connected reader, or errPipeNoReader if no reader has connected
+func (p *namedPipe) connect() (io.WriteCloser, error) {
+	ret, _, err := connectNamedPipe.Call(uintptr(p.handle), 0)
+	if ret != 0 {
+		// The pipe just became available, no reader had a chance to connect yet
+		return nil, errPipeNoReader
+	}
+	switch err {
+	case errorPipeConnected:
+		return &pipeConn{pipe: p}, nil
+	case errorPipeListening:
+		return nil, errPipeNoReader
+	case errorNoData:
+		// A reader connected and closed the pipe again before the scan
+		p.disconnect()
+		return nil, errPipeNoReader
+	default:
+		return nil, fmt.Errorf("failed to connect pipe: %v", err)
+	}
+}
+
+// disconnect drops the reader and waits for the next one
+func (p *namedPipe) disconnect() {
+	disconnectNamedPipe.Call(uintptr(p.handle))
+	p.listen()
+}
+
+// Close closes the pipe, a connected reader sees the end of the pipe
+func (p *namedPipe) Close() error {
+	return syscall.CloseHandle(p.handle)
+}
+
+// pipeConn is the reader connected to the named pipe
+type pipeConn struct {
+	pipe *namedPipe
+}
+
+// Write writes to the reader, failing instead of waiting if the pipe buffer is full
+func (c *pipeConn) Write(data []byte) (int, error) {
+	var written uint32
+	if err := syscall.WriteFile(c.pipe.handle, data, &written, nil); err != nil {
+		return int(written), err
+	}
+	if int(written) < len(data) {
+		return int(written), io.ErrShortWrite
+	}
+	return int(written), nil
+}
+
+// Close disconnects the reader, the pipe stays open for the next one
+func (c *pipeConn) Close() error {
+	c.pipe.disconnect()
+	return nil
+}
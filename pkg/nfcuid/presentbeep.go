@@ -0,0 +1,73 @@
+package nfcuid
+
+import (
+	"fmt"
+)
+
+// presentBeepCommand pulses the buzzer of ACS readers (ACR122U and relatives) once
+// without changing the LEDs: LED and buzzer control with T1 = 100 ms, T2 = 0, one
+// repetition and the buzzer on during T1
+var presentBeepCommand = []byte{0xFF, 0x00, 0x40, 0x00, 0x04, 0x01, 0x00, 0x01, 0x01}
+
+// presentBeepAttempts is how many cards in a row may fail to beep before the reader is
+// taken to have no buzzer control, a single failure may be transient
+const presentBeepAttempts = 3
+
+// readerBuzzer tracks the present beep of one reader
+type readerBuzzer struct {
+	failures int  // Cards in a row the beep failed for
+	beeped   bool // The card on the reader was beeped for, cleared once the reader is seen empty
+}
+
+// presentBeep pulses the reader buzzer with nfc.present_beep over the card connection
+// right after connecting, so the operator knows the card registered before it is read
+// and typed. Each card beeps once, also when it is read again while resting on the
+// reader. Readers failing presentBeepAttempts cards in a row are skipped from then on.
+func (s *service) presentBeep(card cardHandle, reader string) {
+	if !s.config.NFC.PresentBeep {
+		return
+	}
+	if s.buzzers == nil {
+		s.buzzers = make(map[string]*readerBuzzer)
+	}
+	buzzer := s.buzzers[reader]
+	if buzzer == nil {
+		buzzer = &readerBuzzer{}
+		s.buzzers[reader] = buzzer
+	}
+	if buzzer.beeped || buzzer.failures >= presentBeepAttempts {
+		return
+	}
+
+	buzzer.beeped = true
+	if err := pulseBuzzer(card); err != nil {
+		buzzer.failures++
+		if buzzer.failures == presentBeepAttempts {
+			logDebugf("No buzzer control on %s, skipping the present beep: %v", reader, err)
+		} else {
+			logDebugf("Present beep on %s failed (%d of %d attempts): %v", reader, buzzer.failures, presentBeepAttempts, err)
+		}
+		return
+	}
+	buzzer.failures = 0
+}
+
+// presentBeepRemoved lets the next card on the reader beep, once it was seen empty
+func (s *service) presentBeepRemoved(reader string) {
+	if buzzer := s.buzzers[reader]; buzzer != nil {
+		buzzer.beeped = false
+	}
+}
+
+// pulseBuzzer sends presentBeepCommand to the reader of the connected card
+func pulseBuzzer(card cardHandle) error {
+	rsp, err := card.Control(escapeIoctl, presentBeepCommand)
+	if err != nil {
+		return fmt.Errorf("escape command not supported: %v", err)
+	}
+	// The reader answers 90 and its LED state
+	if len(rsp) < 1 || rsp[0] != 0x90 {
+		return fmt.Errorf("buzzer command rejected: % x", rsp)
+	}
+	return nil
+}
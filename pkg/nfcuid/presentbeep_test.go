@@ -0,0 +1,91 @@
+package nfcuid
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestPresentBeep(t *testing.T) {
+	uidResponse := []byte{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}
+
+	tests := []struct {
+		enabled bool
+		escape  []byte
+		beeps   int
+		name    string
+	}{
+		{true, []byte{0x90, 0x00}, 4, "beep on every card"},
+		{true, nil, presentBeepAttempts, "no buzzer control, given up after the attempts"},
+		{true, []byte{0x63, 0x00}, presentBeepAttempts, "command rejected, given up after the attempts"},
+		{false, []byte{0x90, 0x00}, 0, "disabled"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.PresentBeep = test.enabled
+			config.NFC.EndChar = "enter"
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			beeps := 0
+			for i := 0; i < 4; i++ {
+				card := &mockCard{responses: [][]byte{uidResponse}, escape: test.escape}
+				ctx := &mockContext{
+					readers: []string{"ACS ACR122U"},
+					states:  map[string][]scard.StateFlag{"ACS ACR122U": {scard.StatePresent, scard.StateEmpty}},
+					card:    card,
+				}
+				s.readNextCard(ctx, ctx.readers, kb)
+
+				for j, command := range card.commands {
+					if !bytes.Equal(command, presentBeepCommand) {
+						continue
+					}
+					beeps++
+					// Over the card connection, before the card is read
+					if j != 0 || ctx.connects != 1 {
+						t.Errorf("Expected the beep as the first command of the only connection")
+					}
+				}
+			}
+
+			if beeps != test.beeps {
+				t.Errorf("Expected %d beeps, got %d", test.beeps, beeps)
+			}
+			// Typing goes ahead whether or not the reader beeps
+			if expected := strings.Repeat("04a22b91\n", 4); kb.text() != expected {
+				t.Errorf("Expected %q, got %q", expected, kb.text())
+			}
+		})
+	}
+}
+
+func TestPresentBeepOncePerCard(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.PresentBeep = true
+	config.NFC.WaitForRelease = false
+	s := newTestService(config)
+	card := &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}, escape: []byte{0x90, 0x00}}
+
+	// A transient failure doesn't stop the beep for the next card
+	s.presentBeep(&mockCard{}, "Reader 0")
+	s.presentBeepRemoved("Reader 0")
+
+	// The card resting on the reader is read again without the release wait
+	for i := 0; i < 3; i++ {
+		s.presentBeep(card, "Reader 0")
+	}
+	if len(card.commands) != 1 {
+		t.Errorf("Expected one beep while the card rests on the reader, got %d", len(card.commands))
+	}
+
+	s.presentBeepRemoved("Reader 0")
+	s.presentBeep(card, "Reader 0")
+	if len(card.commands) != 2 {
+		t.Errorf("Expected the next card to beep, got %d beeps", len(card.commands))
+	}
+}
@@ -0,0 +1,112 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ebfe/scard"
+)
+
+// pn532MaxTargets is the number of ISO14443A targets the PN532 can list at once
+const pn532MaxTargets = 2
+
+// errReadAllUnsupported is returned when the reader doesn't pass PN532 commands through
+var errReadAllUnsupported = errors.New("reader does not support listing all targets")
+
+// readAllTargets lists every ISO14443A card in the field with the PN532
+// InListPassiveTarget command, passed through the ACR122U direct transmit APDU
+func (s *service) readAllTargets(ctx cardContext, reader string) ([][]byte, error) {
+	card, err := s.connectShared(ctx, reader, scard.ProtocolAny)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to card: %w", err)
+	}
+	defer card.Disconnect(scard.ResetCard)
+	s.presentBeep(card, reader)
+
+	// InListPassiveTarget, 106 kbps type A
+	rsp, err := transmitPN532(card, []byte{0xD4, 0x4A, pn532MaxTargets, 0x00})
+	if err != nil {
+		return nil, err
+	}
+	return parseInListPassiveTarget(rsp)
+}
+
+// transmitPN532 sends a PN532 command wrapped in the ACR122U direct transmit pseudo APDU
+// and returns the PN532 response
+func transmitPN532(card cardHandle, command []byte) ([]byte, error) {
+	apdu := append([]byte{0xFF, 0x00, 0x00, 0x00, byte(len(command))}, command...)
+	rsp, err := card.Transmit(apdu)
+	if err != nil {
+		return nil, fmt.Errorf("card transmission failed: %v", err)
+	}
+
+	// Older ACR122U firmware announces the response length with 61 xx, fetch it with GET RESPONSE
+	if len(rsp) == 2 && rsp[0] == 0x61 {
+		return transmitAPDU(card, []byte{0xFF, 0xC0, 0x00, 0x00, rsp[1]})
+	}
+	if len(rsp) == 2 && rsp[0] == 0x6A && rsp[1] == 0x81 {
+		return nil, errReadAllUnsupported
+	}
+	if len(rsp) < 2 || rsp[len(rsp)-2] != 0x90 || rsp[len(rsp)-1] != 0x00 {
+		return nil, fmt.Errorf("%w, response: % x", errReadAllUnsupported, rsp)
+	}
+	return rsp[:len(rsp)-2], nil
+}
+
+// parseInListPassiveTarget extracts the UIDs from an InListPassiveTarget response for
+// type A targets: D5 4B NbTg, then per target Tg, SENS_RES (2), SEL_RES, NFCID length,
+// NFCID and, for ISO14443-4 cards, the ATS
+func parseInListPassiveTarget(rsp []byte) ([][]byte, error) {
+	if len(rsp) < 3 || rsp[0] != 0xD5 || rsp[1] != 0x4B {
+		return nil, fmt.Errorf("%w, response: % x", errReadAllUnsupported, rsp)
+	}
+
+	count := int(rsp[2])
+	if count == 0 {
+		return nil, errors.New("no card in the field")
+	}
+
+	var uids [][]byte
+	pos := 3
+	for i := 0; i < count; i++ {
+		if pos+5 > len(rsp) {
+			return nil, fmt.Errorf("truncated target list: % x", rsp)
+		}
+		selRes := rsp[pos+3]
+		uidLength := int(rsp[pos+4])
+		pos += 5
+		if pos+uidLength > len(rsp) {
+			return nil, fmt.Errorf("truncated target list: % x", rsp)
+		}
+		uids = append(uids, rsp[pos:pos+uidLength])
+		pos += uidLength
+
+		// The ATS follows for ISO14443-4 compliant cards, its first byte is its length
+		if selRes&0x20 != 0 && pos < len(rsp) {
+			pos += int(rsp[pos])
+		}
+	}
+	return uids, nil
+}
+
+// readUIDs reads the UIDs of the cards on the reader: all cards in the field with
+// read_all, falling back to the single card read if the reader can't list them
+func (s *service) readUIDs(ctx cardContext, reader string) ([][]byte, error) {
+	if s.flags.ReadAll {
+		uids, err := s.readAllTargets(ctx, reader)
+		if err == nil {
+			return uids, nil
+		}
+		// The single card read would only wait for the other application again
+		if errors.Is(err, errReaderShared) || stopsReadingLoop(err) {
+			return nil, err
+		}
+		fmt.Printf("Reading all cards failed (%v), reading a single card\n", err)
+	}
+
+	uidBytes, err := s.connectAndRead(ctx, reader)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{uidBytes}, nil
+}
@@ -0,0 +1,110 @@
+package nfcuid
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestParseInListPassiveTarget(t *testing.T) {
+	tests := []struct {
+		rsp      []byte
+		expected [][]byte
+		isValid  bool
+		name     string
+	}{
+		{
+			[]byte{0xD5, 0x4B, 0x01, 0x01, 0x00, 0x04, 0x08, 0x04, 0x04, 0xa2, 0x2b, 0x91},
+			[][]byte{{0x04, 0xa2, 0x2b, 0x91}},
+			true, "single Mifare Classic",
+		},
+		{
+			[]byte{0xD5, 0x4B, 0x02,
+				0x01, 0x00, 0x44, 0x00, 0x07, 0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66,
+				0x02, 0x00, 0x04, 0x08, 0x04, 0x04, 0xa2, 0x2b, 0x91},
+			[][]byte{{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}, {0x04, 0xa2, 0x2b, 0x91}},
+			true, "Ultralight and Classic",
+		},
+		{
+			[]byte{0xD5, 0x4B, 0x02,
+				0x01, 0x03, 0x44, 0x20, 0x07, 0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x06, 0x75, 0x77, 0x81, 0x02, 0x80,
+				0x02, 0x00, 0x04, 0x08, 0x04, 0x04, 0xa2, 0x2b, 0x91},
+			[][]byte{{0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}, {0x04, 0xa2, 0x2b, 0x91}},
+			true, "DESFire with ATS first",
+		},
+		{[]byte{0xD5, 0x4B, 0x00}, nil, false, "no target"},
+		{[]byte{0xD5, 0x4B, 0x01, 0x01, 0x00, 0x04, 0x08, 0x04, 0x04}, nil, false, "truncated"},
+		{[]byte{0x04, 0xa2, 0x2b, 0x91}, nil, false, "not a PN532 response"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			uids, err := parseInListPassiveTarget(test.rsp)
+			if test.isValid != (err == nil) {
+				t.Fatalf("Expected valid=%v, got error: %v", test.isValid, err)
+			}
+			if len(uids) != len(test.expected) {
+				t.Fatalf("Expected %d UIDs, got %d", len(test.expected), len(uids))
+			}
+			for i := range uids {
+				if !bytes.Equal(uids[i], test.expected[i]) {
+					t.Errorf("Expected UID %d to be % x, got % x", i, test.expected[i], uids[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadNextCardReadAll(t *testing.T) {
+	tests := []struct {
+		responses [][]byte
+		expected  string
+		name      string
+	}{
+		{
+			[][]byte{{0xD5, 0x4B, 0x02,
+				0x01, 0x00, 0x44, 0x00, 0x07, 0x04, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66,
+				0x02, 0x00, 0x04, 0x08, 0x04, 0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}},
+			"04112233445566\t04a22b91\n",
+			"two cards in the field",
+		},
+		{
+			[][]byte{{0x6A, 0x81}, {0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}},
+			"04a22b91\n",
+			"unsupported reader falls back to a single card",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			card := &mockCard{responses: test.responses}
+			ctx := &mockContext{
+				readers: []string{"ACS ACR122U"},
+				states: map[string][]scard.StateFlag{
+					"ACS ACR122U": {scard.StatePresent, scard.StateEmpty},
+				},
+				card: card,
+			}
+			config := DefaultConfig()
+			config.NFC.ReadAll.Enabled = true
+			config.NFC.ReadAll.Separator = "tab"
+			config.NFC.EndChar = "enter"
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result := kb.text(); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+			if !bytes.Equal(card.commands[0], []byte{0xFF, 0x00, 0x00, 0x00, 0x04, 0xD4, 0x4A, 0x02, 0x00}) {
+				t.Errorf("Expected InListPassiveTarget in a direct transmit APDU, got % x", card.commands[0])
+			}
+		})
+	}
+}
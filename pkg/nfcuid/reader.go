@@ -0,0 +1,85 @@
+package nfcuid
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Reader embeds the card reading service in another program. It types the output as
+// configured and reports every read card to the scan handlers.
+type Reader struct {
+	service *service
+	status  *StatusManager
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// NewReader creates a reader for the configuration, onScan (may be nil) is called on a
+// background goroutine after each card is typed. Self-restart is disabled since the
+// host program owns the process. An invalid configuration is returned as an error, as is
+// a site prefix source (nfc.prefix_from) that yields no prefix.
+func NewReader(config *Config, onScan func(ScanEvent)) (*Reader, error) {
+	readerConfig := *config
+	readerConfig.Advanced.SelfRestart = false
+
+	if err := validateConfig(&readerConfig); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	sitePrefix, err := ResolveSitePrefix(&readerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve site prefix: %v", err)
+	}
+	readerConfig.SitePrefix = sitePrefix
+
+	notificationManager := NewNotificationManager(&readerConfig)
+	statusManager := NewStatusManager()
+	statusManager.SetScanHistory(readerConfig.Advanced.ScanHistorySize, readerConfig.Advanced.ScanHistoryMaskUIDs)
+	statusManager.SetStatusFile(readerConfig.Advanced.StatusFile)
+
+	s := NewService(readerConfig.ToFlags(), &readerConfig, notificationManager, NewRestartManager(&readerConfig, notificationManager, nil), NewAudioManager(&readerConfig), statusManager, nil).(*service)
+	if onScan != nil {
+		s.RegisterScanHandler(onScan)
+	}
+	return &Reader{service: s, status: statusManager}, nil
+}
+
+// RegisterScanHandler adds a handler called after each typed card, see NewReader
+func (r *Reader) RegisterScanHandler(handler func(ScanEvent)) {
+	r.service.RegisterScanHandler(handler)
+}
+
+// Start reads cards until the context is cancelled or Stop is called, then returns nil.
+// It returns the error that stopped reading otherwise, e.g. with auto_reconnect disabled.
+func (r *Reader) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+
+	r.service.shutdown = ctx
+	return r.service.run()
+}
+
+// Stop makes Start return once the current card is processed
+func (r *Reader) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// Trigger reads the card currently on the reader once, with nfc.trigger manual. Call it
+// from the host program's hotkey or button handler.
+func (r *Reader) Trigger() error {
+	return r.service.Trigger()
+}
+
+// Status returns the reader status and scan counters
+func (r *Reader) Status() Status {
+	return r.status.GetStatus()
+}
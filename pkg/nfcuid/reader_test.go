@@ -0,0 +1,55 @@
+package nfcuid
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestReadNextCardShutdown(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states:  map[string][]scard.StateFlag{"Reader 0": {scard.StateEmpty}},
+	}
+	config := DefaultConfig()
+	config.Advanced.RetryAttempts = 3
+	s := newTestService(config)
+
+	shutdown, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.shutdown = shutdown
+
+	if err := s.readNextCard(ctx, ctx.readers, &mockKeyboard{}); !errors.Is(err, errShutdownRequested) {
+		t.Errorf("Expected errShutdownRequested, got %v", err)
+	}
+}
+
+func TestNewReaderSitePrefix(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.PrefixFrom = "env"
+	config.NFC.PrefixEnv = "NFCUID_TEST_SITE"
+
+	if _, err := NewReader(config, nil); err == nil {
+		t.Error("Expected an error while the environment variable is not set")
+	}
+
+	t.Setenv("NFCUID_TEST_SITE", "BER")
+	r, err := NewReader(config, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.service.flags.SitePrefix != "BER" {
+		t.Errorf("Expected the site prefix BER from the environment, got %q", r.service.flags.SitePrefix)
+	}
+}
+
+func TestNewReaderInvalidConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.KeyboardLayout = "xx"
+
+	if _, err := NewReader(config, nil); err == nil {
+		t.Error("Expected an unknown keyboard layout to be rejected")
+	}
+}
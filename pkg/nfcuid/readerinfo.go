@@ -0,0 +1,61 @@
+package nfcuid
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ebfe/scard"
+)
+
+// firmwareCommand asks ACS readers (ACR122U and relatives) for their firmware version,
+// sent as escape command so no card needs to be present
+var firmwareCommand = []byte{0xFF, 0x00, 0x48, 0x00, 0x00}
+
+// queryReaderInfo reads the firmware of every reader once per process and records it
+// in the status. Readers without direct mode or escape support are skipped.
+func (s *service) queryReaderInfo(ctx cardContext, readers []string) {
+	if s.readerInfoQueried {
+		return
+	}
+	s.readerInfoQueried = true
+
+	for i, reader := range readers {
+		firmware, err := readReaderFirmware(ctx, reader)
+		if err != nil {
+			logDebugf("No firmware info for [%d] %s: %v", i+1, reader, err)
+			continue
+		}
+		fmt.Printf("Reader [%d] %s: firmware %s\n", i+1, reader, firmware)
+		s.statusManager.SetReaderFirmware(reader, firmware)
+	}
+}
+
+// readReaderFirmware connects to the reader in direct mode and queries its firmware version
+func readReaderFirmware(ctx cardContext, reader string) (string, error) {
+	card, err := ctx.Connect(reader, scard.ShareDirect, scard.ProtocolUndefined)
+	if err != nil {
+		return "", fmt.Errorf("direct connection not supported: %v", err)
+	}
+	defer card.Disconnect(scard.LeaveCard)
+
+	rsp, err := card.Control(escapeIoctl, firmwareCommand)
+	if err != nil {
+		return "", fmt.Errorf("escape command not supported: %v", err)
+	}
+
+	// Some firmware revisions append the status word 90 00 to the version string
+	if len(rsp) >= 2 && rsp[len(rsp)-2] == 0x90 && rsp[len(rsp)-1] == 0x00 {
+		rsp = rsp[:len(rsp)-2]
+	}
+
+	firmware := strings.TrimSpace(strings.TrimRight(string(rsp), "\x00"))
+	if firmware == "" {
+		return "", fmt.Errorf("empty firmware response")
+	}
+	for _, r := range firmware {
+		if r < 0x20 || r > 0x7e {
+			return "", fmt.Errorf("unexpected firmware response: % x", rsp)
+		}
+	}
+	return firmware, nil
+}
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package nfcuid
+
+// escapeIoctl is IOCTL_CCID_ESCAPE, SCARD_CTL_CODE(3500) as defined by pcsc-lite
+const escapeIoctl = 0x42000000 + 3500
@@ -0,0 +1,4 @@
+package nfcuid
+
+// escapeIoctl is IOCTL_CCID_ESCAPE, SCARD_CTL_CODE(3500) as defined by WinSCard
+const escapeIoctl = 0x00310000 | 3500<<2
@@ -0,0 +1,65 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// readerPollInterval is the pause between reader lookups with nfc.wait_for_reader.
+// Replaceable in tests.
+var readerPollInterval = 2 * time.Second
+
+// configuredReaderConnected reports whether the readers include the configured device,
+// so selecting it can't fail for a missing reader
+func (s *service) configuredReaderConnected(readers []string) bool {
+	if len(readers) == 0 {
+		return false
+	}
+	if s.hasFallback() {
+		_, primaryErr := s.resolvePrimary(readers)
+		_, fallbackErr := resolveReader(readers, s.config.NFC.FallbackDevice)
+		return primaryErr == nil || fallbackErr == nil
+	}
+	for _, device := range s.flags.Devices {
+		if _, err := resolveReader(readers, device); err != nil {
+			return false
+		}
+	}
+	// Device 0 is chosen at the prompt among the readers found
+	return s.flags.Device <= len(readers)
+}
+
+// waitForReader lists the readers every readerPollInterval until the configured device
+// is connected, keeping the PC/SC context open instead of restarting the service loop.
+// The user is notified once when the reader is missing.
+func (s *service) waitForReader(ctx cardContext) ([]string, error) {
+	waiting := false
+	for {
+		readers, err := ctx.ListReaders()
+		if err != nil && !errors.Is(err, scard.ErrNoReadersAvailable) {
+			return nil, fmt.Errorf("failed to list readers: %v", err)
+		}
+		if s.configuredReaderConnected(readers) {
+			if waiting {
+				log.Printf("Reader connected, found %d device(s)", len(readers))
+			}
+			return readers, nil
+		}
+
+		if !waiting {
+			waiting = true
+			fmt.Println("Waiting for the NFC reader to be connected...")
+			s.notificationManager.NotifyInfo("NFC Lesegerät", "Warte auf NFC-Lesegerät. Bitte Gerät anschließen.")
+		}
+		logDebugf("Configured reader not connected, found %d device(s), checking again in %v", len(readers), readerPollInterval)
+		select {
+		case <-time.After(readerPollInterval):
+		case <-s.shutdown.Done():
+			return nil, errShutdownRequested
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package nfcuid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// appearingContext is a mock context whose readers are listed one poll after another,
+// an empty list standing for no readers connected
+type appearingContext struct {
+	*mockContext
+	polls [][]string
+	count int
+}
+
+func (c *appearingContext) ListReaders() ([]string, error) {
+	readers := c.polls[c.count]
+	if c.count < len(c.polls)-1 {
+		c.count++
+	}
+	if len(readers) == 0 {
+		return nil, scard.ErrNoReadersAvailable
+	}
+	return readers, nil
+}
+
+func TestWaitForReader(t *testing.T) {
+	defer func(interval time.Duration) { readerPollInterval = interval }(readerPollInterval)
+	readerPollInterval = 0
+
+	tests := []struct {
+		device   string
+		polls    [][]string
+		expected int
+		name     string
+	}{
+		{"1", [][]string{nil, nil, {"Reader 0"}}, 3, "reader appears on the third poll"},
+		{"Entrance", [][]string{nil, {"Exit"}, {"Exit", "Entrance"}}, 3, "other reader first"},
+		{"2", [][]string{{"Reader 0"}, {"Reader 0"}, {"Reader 0", "Reader 1"}}, 3, "device number"},
+		{"1", [][]string{{"Reader 0"}}, 1, "already connected"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.Devices = []string{test.device}
+			config.NFC.WaitForReader = true
+			s := newTestService(config)
+
+			ctx := &appearingContext{mockContext: &mockContext{}, polls: test.polls}
+			readers, err := s.waitForReader(ctx)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if polls := ctx.count + 1; polls != test.expected {
+				t.Errorf("Expected %d polls, got %d", test.expected, polls)
+			}
+			if _, err := s.selectReaders(readers); err != nil {
+				t.Errorf("Expected the configured reader to be selectable, got %v", err)
+			}
+		})
+	}
+}
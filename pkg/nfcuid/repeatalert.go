@@ -0,0 +1,111 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// errRepeatBlocked is returned for a card whose output is suppressed after a repeat alert
+var errRepeatBlocked = errors.New("output suppressed after repeated presentations")
+
+// repeatTracker counts the presentations of each UID for nfc.repeat_alert.
+type repeatTracker struct {
+	mu           sync.Mutex
+	count        int           // Presentations within window that raise an alert
+	window       time.Duration // Period the presentations are counted in
+	block        time.Duration // Output is suppressed this long after an alert, 0 to only alert
+	seen         map[string][]time.Time
+	blockedUntil map[string]time.Time
+}
+
+// newRepeatTracker returns the tracker configured by nfc.repeat_alert, nil when disabled
+func newRepeatTracker(config *Config) *repeatTracker {
+	if !config.NFC.RepeatAlert.Enabled {
+		return nil
+	}
+	return &repeatTracker{
+		count:        config.NFC.RepeatAlert.Count,
+		window:       time.Duration(config.NFC.RepeatAlert.WindowSeconds) * time.Second,
+		block:        time.Duration(config.NFC.RepeatAlert.BlockSeconds) * time.Second,
+		seen:         make(map[string][]time.Time),
+		blockedUntil: make(map[string]time.Time),
+	}
+}
+
+// record counts a presentation of the UID at now. It reports whether this presentation
+// reached the alert count, which starts counting anew, and whether output for the UID is
+// suppressed after an alert.
+func (rt *repeatTracker) record(uid string, now time.Time) (alert, blocked bool) {
+	if rt == nil {
+		return false, false
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.prune(now)
+	rt.seen[uid] = append(rt.seen[uid], now)
+	if len(rt.seen[uid]) >= rt.count {
+		alert = true
+		delete(rt.seen, uid)
+		if rt.block > 0 {
+			rt.blockedUntil[uid] = now.Add(rt.block)
+		}
+	}
+	return alert, now.Before(rt.blockedUntil[uid])
+}
+
+// prune drops the presentations older than the window and the expired blocks, so the
+// tracker only holds the UIDs presented recently
+func (rt *repeatTracker) prune(now time.Time) {
+	for uid, times := range rt.seen {
+		for len(times) > 0 && now.Sub(times[0]) >= rt.window {
+			times = times[1:]
+		}
+		if len(times) == 0 {
+			delete(rt.seen, uid)
+		} else {
+			rt.seen[uid] = times
+		}
+	}
+	for uid, until := range rt.blockedUntil {
+		if !now.Before(until) {
+			delete(rt.blockedUntil, uid)
+		}
+	}
+}
+
+// checkRepeats records the presentation of the UIDs read from the reader, alerting when
+// one was presented repeat_alert.count times within the window. A UID blocked after an
+// alert returns errRepeatBlocked so nothing is typed.
+func (s *service) checkRepeats(hexUIDs []string, reader string) error {
+	var blockedUID string
+	for _, uid := range hexUIDs {
+		alert, blocked := s.repeats.record(uid, time.Now())
+		if alert {
+			message := fmt.Sprintf("card %s presented %d times within %d seconds, possible card sharing", uid, s.config.NFC.RepeatAlert.Count, s.config.NFC.RepeatAlert.WindowSeconds)
+			logWarnf("Repeat alert on %s: %s", reader, message)
+			s.notificationManager.NotifyErrorThrottled("card-repeat", fmt.Sprintf("Karte %s wurde %d-mal innerhalb von %d Sekunden vorgelegt.", uid, s.config.NFC.RepeatAlert.Count, s.config.NFC.RepeatAlert.WindowSeconds))
+			s.eventLogger.Log(Event{Type: EventRepeatAlert, UID: uid, Reader: reader, Message: message})
+		}
+		if blocked && blockedUID == "" {
+			blockedUID = uid
+		}
+	}
+	if blockedUID != "" {
+		return fmt.Errorf("%w: card %s for %d seconds", errRepeatBlocked, blockedUID, s.config.NFC.RepeatAlert.BlockSeconds)
+	}
+	return nil
+}
+
+// handleBlockedRepeat reports a card whose output is suppressed and waits for it to be
+// removed. It only returns errors that stop the reading loop.
+func (s *service) handleBlockedRepeat(ctx cardContext, selectedReaders []string, index int, err error) error {
+	log.Printf("Card on %s not typed: %v", selectedReaders[index], err)
+	s.recordError(selectedReaders[index], err.Error())
+	s.audioManager.PlayErrorSound()
+
+	return s.waitForCardRemoval(ctx, selectedReaders, index)
+}
@@ -0,0 +1,77 @@
+package nfcuid
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepeatTracker(t *testing.T) {
+	tests := []struct {
+		block   int
+		offsets []int // Seconds of each presentation
+		alerts  []bool
+		blocked []bool
+		name    string
+	}{
+		{0, []int{0, 10, 20}, []bool{false, false, true}, []bool{false, false, false}, "count within window"},
+		{0, []int{0, 40, 70}, []bool{false, false, false}, []bool{false, false, false}, "first one outside window"},
+		{0, []int{0, 1, 2, 3, 4, 5}, []bool{false, false, true, false, false, true}, []bool{false, false, false, false, false, false}, "counting starts anew"},
+		{30, []int{0, 1, 2, 10, 40}, []bool{false, false, true, false, false}, []bool{false, false, true, true, false}, "blocked for cooldown"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.RepeatAlert.Enabled = true
+			config.NFC.RepeatAlert.Count = 3
+			config.NFC.RepeatAlert.WindowSeconds = 60
+			config.NFC.RepeatAlert.BlockSeconds = test.block
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			tracker := newRepeatTracker(config)
+
+			start := time.Now()
+			for i, offset := range test.offsets {
+				now := start.Add(time.Duration(offset) * time.Second)
+				// Another card in between doesn't count for this one
+				tracker.record("04112233", now)
+				alert, blocked := tracker.record("04a22b91", now)
+				if alert != test.alerts[i] || blocked != test.blocked[i] {
+					t.Errorf("Presentation %d at %ds: expected alert %v blocked %v, got %v %v", i+1, offset, test.alerts[i], test.blocked[i], alert, blocked)
+				}
+			}
+		})
+	}
+
+	// Disabled by default
+	if alert, blocked := newRepeatTracker(DefaultConfig()).record("04a22b91", time.Now()); alert || blocked {
+		t.Errorf("Expected no alert without repeat_alert")
+	}
+}
+
+func TestRepeatAlertBlocksOutput(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.EndChar = "enter"
+	config.NFC.RepeatAlert.Enabled = true
+	config.NFC.RepeatAlert.Count = 2
+	config.NFC.RepeatAlert.BlockSeconds = 30
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	s := newTestService(config)
+
+	kb := &mockKeyboard{}
+	scanCards(s, kb, 3)
+	if expected := "04a22b91\n"; kb.text() != expected {
+		t.Errorf("Expected only the first presentation typed, got %q", kb.text())
+	}
+	if status := s.statusManager.GetStatus(); status.LastErrorMessage == "" {
+		t.Errorf("Expected the suppressed output to be recorded as an error")
+	}
+
+	config.NFC.RepeatAlert.Count = 1
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected a count of 1 to be rejected")
+	}
+}
@@ -0,0 +1,72 @@
+package nfcuid
+
+import (
+	"fmt"
+	"time"
+)
+
+// scanHandlerQueueSize is how many scans may wait for a slow handler, further scans are
+// dropped for it instead of holding up the next read
+const scanHandlerQueueSize = 64
+
+// ScanEvent is a card read and typed by the service, passed to the scan handlers
+type ScanEvent struct {
+	Time   time.Time
+	UIDs   []string // Raw UIDs as hex, one per card (several with read_all)
+	Output string   // Formatted output as typed
+	Reader string   // Name of the reader that was tapped
+
+	// CapsLock tells whether the CAPS Lock protection found CAPS Lock on while typing
+	CapsLock CapsLockReport
+}
+
+// scanHandler passes the scans to one registered handler in the order they were read,
+// on a goroutine of its own so a slow or failing integration doesn't hold up the next read
+type scanHandler struct {
+	handle func(ScanEvent)
+	events chan ScanEvent
+}
+
+// RegisterScanHandler adds a handler called after each successfully typed card. Output
+// integrations (webhook, MQTT, metrics) register here instead of being wired into
+// processCard.
+func (s *service) RegisterScanHandler(handler func(ScanEvent)) {
+	h := &scanHandler{handle: handler, events: make(chan ScanEvent, scanHandlerQueueSize)}
+	go h.run()
+
+	s.scanHandlersMu.Lock()
+	defer s.scanHandlersMu.Unlock()
+	s.scanHandlers = append(s.scanHandlers, h)
+}
+
+// notifyScanHandlers queues the scan for each registered handler
+func (s *service) notifyScanHandlers(event ScanEvent) {
+	s.scanHandlersMu.Lock()
+	handlers := append([]*scanHandler{}, s.scanHandlers...)
+	s.scanHandlersMu.Unlock()
+
+	for _, h := range handlers {
+		select {
+		case h.events <- event:
+		default:
+			logWarnf("Scan handler is %d scans behind, dropping the scan of %s", scanHandlerQueueSize, event.Reader)
+		}
+	}
+}
+
+// run calls the handler for each queued scan, one after the other
+func (h *scanHandler) run() {
+	for event := range h.events {
+		runScanHandler(h.handle, event)
+	}
+}
+
+// runScanHandler calls the handler, recovering from a panic so it can't take down the service
+func runScanHandler(handler func(ScanEvent), event ScanEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Scan handler panicked: %v\n", r)
+		}
+	}()
+	handler(event)
+}
@@ -0,0 +1,100 @@
+package nfcuid
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+func TestScanHandlers(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states: map[string][]scard.StateFlag{
+			"Reader 0": {scard.StatePresent, scard.StateEmpty},
+		},
+		card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+	}
+	config := DefaultConfig()
+	config.NFC.EndChar = "enter"
+	s := newTestService(config)
+
+	scans := make(chan ScanEvent, 2)
+	s.RegisterScanHandler(func(ScanEvent) { panic("broken integration") })
+	s.RegisterScanHandler(func(event ScanEvent) { scans <- event })
+	s.RegisterScanHandler(func(event ScanEvent) { scans <- event })
+
+	kb := &mockKeyboard{}
+	if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result := kb.text(); result != "04a22b91\n" {
+		t.Errorf("Expected the card to be typed, got %q", result)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case scan := <-scans:
+			if len(scan.UIDs) != 1 || scan.UIDs[0] != "04a22b91" || scan.Output != "04a22b91\\n" || scan.Reader != "Reader 0" {
+				t.Errorf("Unexpected scan event %+v", scan)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected 2 handlers to be called, got %d", i)
+		}
+	}
+}
+
+func TestScanEventCapsLock(t *testing.T) {
+	isCapsLockOn = func(*CapsLockManager) bool { return true }
+	defer func() { isCapsLockOn = (*CapsLockManager).IsCapsLockOn }()
+
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states: map[string][]scard.StateFlag{
+			"Reader 0": {scard.StatePresent, scard.StateEmpty},
+		},
+		card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+	}
+	s := newTestService(DefaultConfig())
+	scans := make(chan ScanEvent, 1)
+	s.RegisterScanHandler(func(event ScanEvent) { scans <- event })
+
+	if err := s.readNextCard(ctx, ctx.readers, &mockKeyboard{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case scan := <-scans:
+		if !scan.CapsLock.WasOn || scan.CapsLock.Strategy != "toggle" || scan.CapsLock.Detectable != capsLockDetectable {
+			t.Errorf("Expected the scan to report CAPS Lock toggled off, got %+v", scan.CapsLock)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the scan handler to be called")
+	}
+}
+
+func TestScanHandlersOrdered(t *testing.T) {
+	s := newTestService(DefaultConfig())
+	received := make(chan string, 10)
+	s.RegisterScanHandler(func(event ScanEvent) {
+		// A slow first scan must not let the second overtake it
+		if event.Output == "0" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		received <- event.Output
+	})
+
+	for i := 0; i < 5; i++ {
+		s.notifyScanHandlers(ScanEvent{Output: fmt.Sprint(i)})
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case output := <-received:
+			if output != fmt.Sprint(i) {
+				t.Fatalf("Expected scan %d, got %s", i, output)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected scan %d to be handled", i)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package nfcuid
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// scriptBlockedGlobals are the base library functions removed from the script sandbox,
+// they load code from files or strings or reach outside the script
+var scriptBlockedGlobals = []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "collectgarbage", "getfenv", "setfenv", "newproxy", "print"}
+
+// uidScript is the Lua script of nfc.script, compiled once and run in a fresh sandbox for
+// every card.
+type uidScript struct {
+	path    string
+	proto   *lua.FunctionProto
+	timeout time.Duration
+}
+
+// loadUIDScript reads and compiles the script of nfc.script, nil when not configured
+func loadUIDScript(config *Config) (*uidScript, error) {
+	if config.NFC.Script == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(config.NFC.Script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %v", err)
+	}
+	chunk, err := parse.Parse(strings.NewReader(string(data)), config.NFC.Script)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script %s: %v", config.NFC.Script, err)
+	}
+	proto, err := lua.Compile(chunk, config.NFC.Script)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script %s: %v", config.NFC.Script, err)
+	}
+	return &uidScript{
+		path:    config.NFC.Script,
+		proto:   proto,
+		timeout: time.Duration(config.NFC.ScriptTimeoutMs) * time.Millisecond,
+	}, nil
+}
+
+// newScriptState returns a Lua state with only the base, string, table and math
+// libraries, without file, OS, network or module access
+func newScriptState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	libs := []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.StringLibName, lua.OpenString},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.MathLibName, lua.OpenMath},
+	}
+	for _, lib := range libs {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range scriptBlockedGlobals {
+		L.SetGlobal(name, lua.LNil)
+	}
+	return L
+}
+
+// run runs the script for the UID read from the device and returns the string it
+// returns. output is the output formatted by the other options, without end keys.
+func (us *uidScript) run(uid []byte, device, output string) (string, error) {
+	L := newScriptState()
+	defer L.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), us.timeout)
+	defer cancel()
+	L.SetContext(ctx)
+
+	uidBytes := L.NewTable()
+	for _, b := range uid {
+		uidBytes.Append(lua.LNumber(b))
+	}
+	L.SetGlobal("uid", uidBytes)
+	L.SetGlobal("hex", lua.LString(fmt.Sprintf("%x", uid)))
+	L.SetGlobal("dec", lua.LString(new(big.Int).SetBytes(uid).String()))
+	L.SetGlobal("device", lua.LString(device))
+	L.SetGlobal("output", lua.LString(output))
+
+	L.Push(L.NewFunctionFromProto(us.proto))
+	if err := L.PCall(0, 1, nil); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("script %s timed out after %v", us.path, us.timeout)
+		}
+		return "", fmt.Errorf("script %s failed: %v", us.path, err)
+	}
+	switch result := L.Get(-1).(type) {
+	case lua.LString:
+		return string(result), nil
+	case lua.LNumber:
+		return result.String(), nil
+	default:
+		return "", fmt.Errorf("script %s returned %s instead of a string", us.path, result.Type())
+	}
+}
+
+// applyScript replaces the formatted output with the result of nfc.script. When the
+// script fails the formatted output is typed and the user notified. The script works on
+// the unescaped text and its result is escaped, so a backslash in it is typed literally.
+func (s *service) applyScript(uid []byte, reader, output string) string {
+	if s.script == nil {
+		return output
+	}
+	result, err := s.script.run(uid, reader, unescapeOutput(output))
+	if err != nil {
+		logWarnf("%v, typing the standard output", err)
+		s.notificationManager.NotifyErrorThrottled("script-error", "Ausgabeskript fehlgeschlagen, Standardformat wird verwendet.")
+		return output
+	}
+	return escapeOutput(result)
+}
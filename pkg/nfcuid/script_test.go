@@ -0,0 +1,96 @@
+package nfcuid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScriptOutput(t *testing.T) {
+	tests := []struct {
+		script   string
+		inChar   string
+		expected string
+		name     string
+	}{
+		{`return "ID-" .. string.upper(hex)`, "", "ID-04A22B91\n", "hex"},
+		{`return dec .. "@" .. device`, "", "77736849@Reader 0\n", "decimal and device"},
+		{`return string.format("%02x", uid[#uid]) .. output`, "", "9104a22b91\n", "bytes and output"},
+		{`return #uid * 10`, "", "40\n", "number result"},
+		{`local s = 0 for i = 1, #uid do s = s + uid[i] end return tostring(s % 256)`, "", "98\n", "checksum"},
+		{`error("no format for " .. hex)`, "", "04a22b91\n", "error falls back"},
+		{`return nil`, "", "04a22b91\n", "nil falls back"},
+		{`while true do end`, "", "04a22b91\n", "timeout falls back"},
+		{`return io.open("/etc/passwd"):read("*a")`, "", "04a22b91\n", "no io"},
+		{`return os.getenv("HOME")`, "", "04a22b91\n", "no os"},
+		{`return dofile("/etc/passwd")`, "", "04a22b91\n", "no dofile"},
+		{`return require("socket")`, "", "04a22b91\n", "no require"},
+		{`return "a\\"`, "", "a\\\n", "trailing backslash typed literally"},
+		{`return "x\\ny"`, "", "x\\ny\n", "backslash sequence typed literally"},
+		{`return (output:gsub("\t", ":"))`, "tab", "04:a2:2b:91\n", "output unescaped"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "format.lua")
+			if err := os.WriteFile(path, []byte(test.script), 0o644); err != nil {
+				t.Fatalf("Failed to write script: %v", err)
+			}
+			config := DefaultConfig()
+			config.NFC.EndChar = "enter"
+			config.NFC.Script = path
+			config.NFC.ScriptTimeoutMs = 20
+			if test.inChar != "" {
+				config.NFC.InChar = test.inChar
+			}
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			scanCards(s, kb, 1)
+			if kb.text() != test.expected {
+				t.Errorf("Expected %q typed, got %q", test.expected, kb.text())
+			}
+		})
+	}
+}
+
+func TestScriptValidation(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.lua")
+	invalid := filepath.Join(dir, "invalid.lua")
+	if err := os.WriteFile(valid, []byte(`return hex`), 0o644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	if err := os.WriteFile(invalid, []byte(`return hex ..`), 0o644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	tests := []struct {
+		script  string
+		timeout int
+		readAll bool
+		valid   bool
+		name    string
+	}{
+		{valid, 100, false, true, "valid"},
+		{invalid, 100, false, false, "syntax error"},
+		{filepath.Join(dir, "missing.lua"), 100, false, false, "missing file"},
+		{valid, 0, false, false, "no timeout"},
+		{valid, 100, true, false, "with read all"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.Script = test.script
+			config.NFC.ScriptTimeoutMs = test.timeout
+			config.NFC.ReadAll.Enabled = test.readAll
+			if err := validateConfig(config); (err == nil) != test.valid {
+				t.Errorf("Expected valid %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
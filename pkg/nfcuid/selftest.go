@@ -0,0 +1,128 @@
+package nfcuid
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// selfTestFailure describes the self-test stage that failed, with a hint on how to fix it
+type selfTestFailure struct {
+	stage string
+	err   error
+	hint  string
+}
+
+func (f *selfTestFailure) Error() string {
+	return fmt.Sprintf("%s failed: %v", f.stage, f.err)
+}
+
+// RunSelfTest runs the -selftest command and exits with 0 if the full read path works
+func RunSelfTest(config *Config, notificationManager *NotificationManager, statusManager *StatusManager) {
+	// A failing stage should be reported, not hidden behind a relaunch
+	config.Advanced.SelfRestart = false
+
+	service := NewService(config.ToFlags(), config, notificationManager, NewRestartManager(config, notificationManager, nil), NewAudioManager(config), statusManager, nil).(*service)
+	timeout := time.Duration(config.SelfTestTimeout) * time.Second
+
+	fmt.Println("Running self-test...")
+	if err := service.SelfTest(timeout); err != nil {
+		fmt.Printf("FAIL: %v\n", err)
+		if failure, ok := err.(*selfTestFailure); ok && failure.hint != "" {
+			fmt.Printf("Hint: %s\n", failure.hint)
+		}
+		SafeExit(1, "Self-test failed", nil)
+	}
+
+	fmt.Println("PASS: Self-test completed successfully")
+	SafeExit(0, "", nil)
+}
+
+// SelfTest exercises the full read path once without typing: PC/SC context, reader
+// list, device selection, waiting for a card, reading and formatting it
+func (s *service) SelfTest(timeout time.Duration) error {
+	fmt.Println("[1/5] Establishing PC/SC context...")
+	scardCtx, err := scard.EstablishContext()
+	if err != nil {
+		return &selfTestFailure{"PC/SC context", err, "Make sure the smart card service is running (Linux: pcscd, Windows: Smart Card service)"}
+	}
+	ctx := scardContext{scardCtx}
+	defer ctx.Release()
+
+	return s.selfTest(ctx, timeout)
+}
+
+// selfTest runs the self-test stages after the PC/SC context is established
+func (s *service) selfTest(ctx cardContext, timeout time.Duration) error {
+	fmt.Println("[2/5] Listing readers...")
+	readers, err := ctx.ListReaders()
+	if err != nil {
+		return &selfTestFailure{"Listing readers", err, "Check that the reader is connected and its driver is installed"}
+	}
+	if len(readers) == 0 {
+		return &selfTestFailure{"Listing readers", fmt.Errorf("no reader found"), "Check that the reader is connected and its driver is installed"}
+	}
+	for i, reader := range readers {
+		fmt.Printf("      [%d] %s\n", i+1, reader)
+	}
+
+	fmt.Println("[3/5] Selecting device...")
+	selectedReaders, err := s.selectReaders(readers)
+	if err != nil {
+		return &selfTestFailure{"Device selection", err, "Check nfc.device / nfc.devices in config.yaml or the -device / -devices flags"}
+	}
+
+	fmt.Printf("[4/5] Waiting up to %v for a card...\n", timeout)
+	index, mute, err := s.waitForCardTimeout(ctx, selectedReaders, timeout)
+	if err != nil {
+		return &selfTestFailure{"Waiting for a card", err, "Hold a card on the reader while the self-test runs, or raise -selftest-timeout"}
+	}
+	if mute {
+		return &selfTestFailure{"Waiting for a card", fmt.Errorf("card on %s is not responding (mute)", selectedReaders[index]), "Try another card, this one may be damaged or unsupported"}
+	}
+
+	fmt.Println("[5/5] Reading card...")
+	uidBytes, err := s.connectAndRead(ctx, selectedReaders[index])
+	if err != nil {
+		return &selfTestFailure{"Reading the card", err, "Check read_mode, tag_standard and the mifare_block key against the card type"}
+	}
+
+	fmt.Printf("      Reader: %s\n", selectedReaders[index])
+	fmt.Printf("      UID: % x\n", uidBytes)
+	fmt.Printf("      Output (not typed): %q\n", s.formatOutput(uidBytes, selectedReaders[index]))
+	return nil
+}
+
+// waitForCardTimeout waits until a card is present in one of the readers, giving up after the timeout
+func (s *service) waitForCardTimeout(ctx cardContext, readers []string, timeout time.Duration) (int, bool, error) {
+	rs := make([]scard.ReaderState, len(readers))
+	for i := range rs {
+		rs[i].Reader = readers[i]
+		rs[i].CurrentState = scard.StateUnaware
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		for i := range rs {
+			if rs[i].EventState&scard.StatePresent != 0 {
+				return i, rs[i].EventState&scard.StateMute != 0, nil
+			}
+			rs[i].CurrentState = rs[i].EventState
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return -1, false, fmt.Errorf("%w within %v", errNoCardPresented, timeout)
+		}
+
+		err := ctx.GetStatusChange(rs, remaining)
+		logReaderStateTransitions(rs)
+		if err == scard.ErrTimeout {
+			return -1, false, fmt.Errorf("%w within %v", errNoCardPresented, timeout)
+		}
+		if err != nil {
+			return -1, false, err
+		}
+	}
+}
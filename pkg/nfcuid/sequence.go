@@ -0,0 +1,76 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxSequenceWidth is the largest nfc.sequence.width, the digits of the largest uint64
+const maxSequenceWidth = 20
+
+// sequenceCounter numbers the successful scans for the {seq} output token.
+type sequenceCounter struct {
+	mu    sync.Mutex
+	value uint64 // number of the last typed scan
+	width int
+	path  string // persist file, empty to start from 0 every session
+}
+
+// newSequenceCounter returns the counter configured by nfc.sequence, continuing from the
+// persist file if there is one. A missing persist file starts the count at 0.
+func newSequenceCounter(config *Config) (*sequenceCounter, error) {
+	if !config.NFC.Sequence.Enabled {
+		return nil, nil
+	}
+
+	counter := &sequenceCounter{width: config.NFC.Sequence.Width, path: config.NFC.Sequence.PersistFile}
+	if counter.path == "" {
+		return counter, nil
+	}
+
+	data, err := os.ReadFile(counter.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return counter, nil
+	}
+	if err != nil {
+		return counter, fmt.Errorf("failed to read sequence file: %v", err)
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return counter, fmt.Errorf("sequence file %s doesn't hold a number: %v", counter.path, err)
+	}
+	counter.value = value
+	return counter, nil
+}
+
+// next returns the zero-padded number of the next scan without counting it, so a scan
+// that fails to type reuses its number
+func (c *sequenceCounter) next() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("%0*d", c.width, c.value+1)
+}
+
+// commit counts the scan numbered by next and persists the new count
+func (c *sequenceCounter) commit() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value++
+	if c.path == "" {
+		return nil
+	}
+	if err := writeFileAtomic(c.path, []byte(strconv.FormatUint(c.value, 10)+"\n")); err != nil {
+		return fmt.Errorf("failed to persist sequence number: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package nfcuid
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+// failingKeyboard is a keyboard whose key presses fail
+type failingKeyboard struct {
+	mockKeyboard
+}
+
+func (k *failingKeyboard) Launching() error {
+	return errors.New("keyboard unavailable")
+}
+
+// sequenceTestConfig returns a config typing "{seq}:{uid}" with the sequence enabled
+func sequenceTestConfig(t *testing.T, width int, persistFile string) *Config {
+	config := DefaultConfig()
+	config.NFC.OutputFormat = "{seq}:{uid}"
+	config.NFC.Sequence.Enabled = true
+	config.NFC.Sequence.Width = width
+	config.NFC.Sequence.PersistFile = persistFile
+	config.NFC.EndChar = "enter"
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	return config
+}
+
+// scanCards presents a card to the service the given number of times, typing into kb
+func scanCards(s *service, kb keyboard, cards int) {
+	uidResponse := []byte{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}
+	for i := 0; i < cards; i++ {
+		ctx := &mockContext{
+			readers: []string{"Reader 0"},
+			states:  map[string][]scard.StateFlag{"Reader 0": {scard.StatePresent, scard.StateEmpty}},
+			card:    &mockCard{responses: [][]byte{uidResponse}},
+		}
+		s.readNextCard(ctx, ctx.readers, kb)
+	}
+}
+
+func TestSequenceIncrement(t *testing.T) {
+	s := newTestService(sequenceTestConfig(t, 4, ""))
+
+	kb := &mockKeyboard{}
+	scanCards(s, kb, 3)
+	if expected := "0001:04a22b91\n0002:04a22b91\n0003:04a22b91\n"; kb.text() != expected {
+		t.Errorf("Expected %q, got %q", expected, kb.text())
+	}
+
+	// A scan that fails to type keeps its number for the next one
+	scanCards(s, &failingKeyboard{}, 1)
+	kb = &mockKeyboard{}
+	scanCards(s, kb, 1)
+	if expected := "0004:04a22b91\n"; kb.text() != expected {
+		t.Errorf("Expected %q after a failed scan, got %q", expected, kb.text())
+	}
+}
+
+func TestSequenceWidth(t *testing.T) {
+	tests := []struct {
+		width    int
+		value    uint64
+		expected string
+		name     string
+	}{
+		{1, 0, "1", "no padding"},
+		{4, 6, "0007", "padded"},
+		{4, 12344, "12345", "wider than width"},
+		{20, 0, "00000000000000000001", "max width"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			counter := &sequenceCounter{width: test.width, value: test.value}
+			if result := counter.next(); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+
+	for _, width := range []int{0, 21} {
+		config := sequenceTestConfig(t, 4, "")
+		config.NFC.Sequence.Width = width
+		if err := validateConfig(config); err == nil {
+			t.Errorf("Expected width %d to be rejected", width)
+		}
+	}
+
+	// The token and the option only work together
+	config := DefaultConfig()
+	config.NFC.OutputFormat = "{seq}:{uid}"
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected {seq} without sequence enabled to be rejected")
+	}
+	config = DefaultConfig()
+	config.NFC.Sequence.Enabled = true
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected sequence enabled without {seq} to be rejected")
+	}
+}
+
+func TestSequencePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence.txt")
+
+	// A missing file starts the count, each typed scan is persisted
+	s := newTestService(sequenceTestConfig(t, 4, path))
+	scanCards(s, &mockKeyboard{}, 2)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read sequence file: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "2" {
+		t.Errorf("Expected the sequence file to hold 2, got %q", data)
+	}
+
+	// A restart continues from the file
+	s = newTestService(sequenceTestConfig(t, 4, path))
+	kb := &mockKeyboard{}
+	scanCards(s, kb, 1)
+	if expected := "0003:04a22b91\n"; kb.text() != expected {
+		t.Errorf("Expected %q after a restart, got %q", expected, kb.text())
+	}
+
+	// Without a persist file every session starts from 1
+	s = newTestService(sequenceTestConfig(t, 4, ""))
+	kb = &mockKeyboard{}
+	scanCards(s, kb, 1)
+	if expected := "0001:04a22b91\n"; kb.text() != expected {
+		t.Errorf("Expected %q without persistence, got %q", expected, kb.text())
+	}
+
+	// A garbled file is reported and counting starts over
+	if err := os.WriteFile(path, []byte("seven"), 0644); err != nil {
+		t.Fatalf("Failed to write sequence file: %v", err)
+	}
+	counter, err := newSequenceCounter(sequenceTestConfig(t, 4, path))
+	if err == nil {
+		t.Errorf("Expected a garbled sequence file to be reported")
+	}
+	if counter.next() != "0001" {
+		t.Errorf("Expected counting to start over, got %q", counter.next())
+	}
+}
@@ -0,0 +1,1627 @@
+package nfcuid
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ebfe/scard"
+	"github.com/micmonay/keybd_event"
+)
+
+type Service interface {
+	Start()
+	Flags() Flags
+	RegisterScanHandler(handler func(ScanEvent))
+	RegisterErrorHandler(handler func(reader, message string))
+	RegisterStateHandler(handler func())
+	SetPaused(paused bool)
+	Paused() bool
+	Repeat() error
+	ChangeDevice(device string)
+	ActiveReaders() []string
+	Trigger() error
+}
+
+func NewService(flags Flags, config *Config, notificationManager *NotificationManager, restartManager *RestartManager, audioManager *AudioManager, statusManager *StatusManager, eventLogger *EventLogger) Service {
+	s := &service{
+		flags:                flags,
+		config:               config,
+		notificationManager:  notificationManager,
+		restartManager:       restartManager,
+		audioManager:         audioManager,
+		statusManager:        statusManager,
+		releasedReaders:      make(map[string]bool),
+		graceUntil:           time.Now().Add(time.Duration(config.NFC.StartupGraceMs) * time.Millisecond),
+		eventLogger:          eventLogger,
+		keyPacer:             newKeyPacer(flags.TypeProfile),
+		retryManager:         NewRetryManager(config.Advanced.RetryAttempts, config.Advanced.ReconnectDelay),
+		cardRetryManager:     NewRetryManagerWithDelay(config.Advanced.CardReadAttempts, time.Duration(config.Advanced.CardReadDelayMs)*time.Millisecond),
+		keyboardRetryManager: NewRetryManagerWithDelay(config.Advanced.KeyboardInitAttempts, time.Duration(config.Advanced.KeyboardInitDelayMs)*time.Millisecond),
+		newKeyboard:          newKeyBonding,
+		shutdown:             shutdownCtx,
+		input:                bufio.NewReader(os.Stdin),
+		triggers:             make(chan struct{}, 1),
+	}
+
+	// Retries end up in the log for post-mortems, card read retries are routine (a
+	// card pulled away too early) and only logged at debug level
+	s.retryManager.SetLogger(logWarnf)
+	s.cardRetryManager.SetLogger(logDebugf)
+	s.keyboardRetryManager.SetLogger(logWarnf)
+
+	sequence, err := newSequenceCounter(config)
+	if err != nil {
+		logWarnf("%v, counting scans from 1", err)
+	}
+	s.sequence = sequence
+	s.repeats = newRepeatTracker(config)
+
+	script, err := loadUIDScript(config)
+	if err != nil {
+		logWarnf("%v, typing without the script", err)
+	}
+	s.script = script
+	return s
+}
+
+// errShutdownRequested is returned by the interactive prompt when a shutdown signal arrives
+var errShutdownRequested = errors.New("shutdown requested")
+
+// errNoInput is returned by the interactive prompt when stdin is closed
+var errNoInput = errors.New("no input available, stdin is closed")
+
+// cardContext is the part of the PC/SC context used by the service, abstracted
+// so the reading loop can be exercised against a mock reader in tests
+type cardContext interface {
+	ListReaders() ([]string, error)
+	GetStatusChange(readerStates []scard.ReaderState, timeout time.Duration) error
+	Connect(reader string, mode scard.ShareMode, proto scard.Protocol) (cardHandle, error)
+	Release() error
+}
+
+// cardHandle is the part of a connected PC/SC card used by the service
+type cardHandle interface {
+	Status() (*scard.CardStatus, error)
+	Transmit(cmd []byte) ([]byte, error)
+	Disconnect(d scard.Disposition) error
+	Control(ioctl uint32, in []byte) ([]byte, error)
+}
+
+// scardContext adapts *scard.Context to cardContext
+type scardContext struct {
+	*scard.Context
+}
+
+// Connect connects to the card in the given reader
+func (c scardContext) Connect(reader string, mode scard.ShareMode, proto scard.Protocol) (cardHandle, error) {
+	card, err := c.Context.Connect(reader, mode, proto)
+	if err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+type Flags struct {
+	CapsLock           bool
+	Reverse            bool
+	SwapNibbles        bool
+	Decimal            bool
+	DecimalPadding     int
+	EndChar            CharFlag
+	EndSequence        []CharFlag // Keys typed after the UID instead of EndChar, empty to use EndChar
+	PostOutputClear    []CharFlag // Keys typed after the end keys to reset the target field
+	OnReleaseKeys      []CharFlag // Keys typed once the card is removed from the reader
+	ReadAll            bool       // List every card in the field instead of reading one
+	ReadAllSeparator   CharFlag   // Separator between the UIDs when several cards were read
+	InChar             CharFlag
+	Delimiter          string // Literal character between UID bytes from -delimiter, replaces InChar when set
+	Device             int
+	Devices            []string       // Readers to watch simultaneously, by number or name
+	OutputFormat       string         // Output template with {uid} and {reader} tokens, empty for just the UID
+	OutputRegex        *regexp.Regexp // Pattern replaced in the output before the end keys, nil for none
+	OutputReplace      string         // Replacement for OutputRegex matches, may reference groups as $1
+	SplitParity        string         // Parity mode for the second output field, empty when split output is off
+	SplitSeparator     CharFlag
+	Duplicate          bool                     // Type the output twice for two-field forms
+	DuplicateSeparator CharFlag                 // Separator between the two copies of the output
+	Prefix             string                   // Text typed before the UID
+	SitePrefix         string                   // Site code typed before the prefix, from nfc.prefix_from
+	ReaderProfiles     map[string]ReaderProfile // Output overrides per configured device
+	MifareBlock        *MifareBlockRead         // Read a Mifare Classic block instead of the UID, nil for the UID
+	Wiegand            *WiegandFormat           // Type the UID as Wiegand facility code and card number, nil for hex/decimal
+	TypeProfile        TypeProfile              // Pauses between typed keys
+	TagStandard        string                   // Tag family for the UID read: 14443, 15693 or auto
+	UIDBytes           string                   // Part of the UID to format: all, first4 or last4
+	UIDByteRange       string                   // Bytes of the UID to format as first:last counted from 1, empty for all
+	UIDPadBytes        int                      // Pad shorter UIDs with leading zero bytes to this length, 0 = off
+	AllowedATRs        [][]byte                 // ATR prefixes of the accepted cards, empty accepts every card
+}
+
+// MifareBlockRead describes the Mifare Classic block to read and how to authenticate it
+type MifareBlockRead struct {
+	Block   byte
+	KeyType byte // 0x60 for key A, 0x61 for key B
+	Key     []byte
+}
+
+// errMifareAuth is returned when the card rejects the configured sector key
+var errMifareAuth = errors.New("mifare authentication failed")
+
+// MifareKeyTypeCode converts the key type name (A or B) to its authentication code
+func MifareKeyTypeCode(keyType string) (byte, bool) {
+	switch strings.ToUpper(keyType) {
+	case "A":
+		return 0x60, true
+	case "B":
+		return 0x61, true
+	}
+	return 0, false
+}
+
+type service struct {
+	flags                Flags
+	config               *Config
+	notificationManager  *NotificationManager
+	restartManager       *RestartManager
+	audioManager         *AudioManager
+	statusManager        *StatusManager
+	eventLogger          *EventLogger
+	keyPacer             *keyPacer     // Paces typed keys, nil types instantly
+	retryManager         *RetryManager // Retries for system operations (context, readers, connections)
+	cardRetryManager     *RetryManager // Fast retries for reading the card itself
+	keyboardRetryManager *RetryManager // Retries for setting up the virtual keyboard
+	newKeyboard          func() (keyboard, error)
+	lastUID              string                     // Last UID seen on the reader, used for debouncing
+	lastUIDSeen          time.Time                  // When lastUID was last seen
+	readerFlags          map[string]Flags           // Output flags for readers with a profile
+	shutdown             context.Context            // Cancelled on shutdown signals, ends the interactive prompt
+	input                *bufio.Reader              // Source for the interactive device prompt
+	reconnectAttempts    int                        // Consecutive failed service loops, drives the reconnect backoff
+	downSince            time.Time                  // When the service last stopped reading cards, zero while it is up
+	outageReported       bool                       // The current outage was already reported
+	idleReportedSince    time.Time                  // Card activity time of the last idle alert, so each idle period alerts once
+	readerInfoQueried    bool                       // Reader firmware was already queried, it is only read once per process
+	buzzers              map[string]*readerBuzzer   // Present beep state per reader
+	sharing              map[string]*sharingEpisode // Readers held by another application, see connectShared
+	primaryReader        string                     // Name of the primary reader with nfc.fallback_device, once seen
+	onFallback           bool                       // The fallback reader is in use instead of the primary
+	noAttachNotify       bool                       // PC/SC rejected the PnP pseudo reader, no switch back to the primary
+	releasedReaders      map[string]bool            // Readers seen empty since startup, or since their last read with strict_release
+	presentStates        map[string]scard.StateFlag // Reader state when waitUntilCardPresent found the card, with the pcsc-lite event counter
+	graceUntil           time.Time                  // End of the startup grace period, cards presented before are ignored
+	scanHandlers         []*scanHandler             // Output integrations notified after each typed card
+	errorHandlers        []func(string, string)     // Notified with the reader and message of each recorded error
+	stateHandlers        []func()                   // Notified when reading is paused or resumed or the watched readers change
+	paused               atomic.Bool                // Presented cards are ignored, set with SetPaused
+	waitingForCard       atomic.Bool                // The loop waits for a card, so ChangeDevice may cancel the wait
+	typingMu             sync.Mutex                 // Serializes typing by the reading loop and Repeat
+	lastOutput           string                     // Output of the last typed card, for Repeat
+	lastKeyboard         keyboard                   // Keyboard lastOutput was typed with, nil before the first card
+	deviceMu             sync.Mutex                 // Guards pendingDevice and activeReaders
+	pendingDevice        string                     // Device requested by ChangeDevice, empty for none
+	activeReaders        []string                   // Readers the reading loop watches
+	sequence             *sequenceCounter           // Numbers the typed scans for {seq}, nil when disabled
+	repeats              *repeatTracker             // Counts presentations per UID for repeat_alert, nil when disabled
+	script               *uidScript                 // Lua script of nfc.script, nil when disabled
+	triggers             chan struct{}              // Pending read request with nfc.trigger manual
+	aliveAt              atomic.Int64               // Last reading loop heartbeat (unix nanoseconds), 0 outside the loop
+	watchdogTripped      atomic.Bool                // The watchdog cancelled the card wait to restart the loop
+	cancelWait           func() error               // Cancels the pending PC/SC wait, nil outside the service loop
+	watchdogMu           sync.Mutex
+	scanHandlersMu       sync.Mutex
+}
+
+func UIDToUint32(uid []byte) (uint32, error) {
+	if len(uid) != 4 {
+		return 0, fmt.Errorf("UID must be 4 bytes, got %d bytes", len(uid))
+	}
+	return binary.LittleEndian.Uint32(uid), nil
+}
+
+// inCharOutput returns the output between UID bytes, the -delimiter character if set or
+// else the in character. A backslash is escaped so KeyboardWrite types it literally.
+func (flags Flags) inCharOutput() string {
+	if flags.Delimiter == "" {
+		return flags.InChar.Output()
+	}
+	return strings.ReplaceAll(flags.Delimiter, "\\", "\\\\")
+}
+
+// transformOutput replaces the OutputRegex matches in the output. The end keys are added
+// afterwards, so a pattern can't remove them by accident. The pattern runs over the
+// unescaped text and the result is escaped again, so a backslash in the replacement is
+// typed literally instead of becoming a key.
+func (flags Flags) transformOutput(output string) string {
+	if flags.OutputRegex == nil {
+		return output
+	}
+	return escapeOutput(flags.OutputRegex.ReplaceAllString(unescapeOutput(output), flags.OutputReplace))
+}
+
+// duplicateOutput types the output a second time after the separator with Duplicate.
+// The form checks the second field against the first, so both get the complete output.
+func (flags Flags) duplicateOutput(output string) string {
+	if !flags.Duplicate {
+		return output
+	}
+	return output + flags.DuplicateSeparator.Output() + output
+}
+
+// endOutput returns the keys typed after the UID, the end sequence if set or else the end character
+func (flags Flags) endOutput() string {
+	if len(flags.EndSequence) == 0 {
+		return flags.EndChar.Output()
+	}
+
+	var output string
+	for _, key := range flags.EndSequence {
+		output += key.Output()
+	}
+	return output
+}
+
+// clearOutput returns the keys typed after the end keys to reset the target field
+func (flags Flags) clearOutput() string {
+	var output string
+	for _, key := range flags.PostOutputClear {
+		output += key.Output()
+	}
+	return output
+}
+
+// releaseOutput returns the keys typed after the card was removed
+func (flags Flags) releaseOutput() string {
+	var output string
+	for _, key := range flags.OnReleaseKeys {
+		output += key.Output()
+	}
+	return output
+}
+
+// IsValidParityMode checks if the parity mode is supported by UIDParity
+func IsValidParityMode(mode string) bool {
+	switch mode {
+	case "even", "odd", "xor":
+		return true
+	}
+	return false
+}
+
+// UIDParity computes the check value typed into the second field of a split output.
+// "even" and "odd" return a single parity digit over all UID bits, "xor" returns
+// the XOR of all UID bytes as two hex digits.
+func UIDParity(uid []byte, mode string, upper bool) (string, error) {
+	switch mode {
+	case "even", "odd":
+		ones := 0
+		for _, b := range uid {
+			ones += bits.OnesCount8(b)
+		}
+		parity := ones % 2
+		if mode == "odd" {
+			parity = 1 - parity
+		}
+		return strconv.Itoa(parity), nil
+	case "xor":
+		var x byte
+		for _, b := range uid {
+			x ^= b
+		}
+		if upper {
+			return fmt.Sprintf("%02X", x), nil
+		}
+		return fmt.Sprintf("%02x", x), nil
+	default:
+		return "", fmt.Errorf("unknown parity mode: %s", mode)
+	}
+}
+
+// Start reads cards until a shutdown is requested and exits the process, also when the
+// service stops due to an error
+func (s *service) Start() {
+	scanning.Add(1)
+	err := s.run()
+	scanning.Done()
+	if err != nil {
+		if errors.Is(err, errNoInput) {
+			SafeExit(1, fmt.Sprintf("Device selection failed: %v. Set nfc.device in config.yaml or use -device.", err), s.notificationManager)
+		}
+		SafeExit(1, "Service stopped due to error", s.notificationManager)
+	}
+	SafeExit(0, "", nil)
+}
+
+// run reads cards, reconnecting after errors if enabled, until the shutdown context is
+// cancelled. It returns nil on shutdown and the error that stopped the service otherwise.
+func (s *service) run() error {
+	if s.config.NFC.IdleAlertMinutes > 0 {
+		go s.monitorIdle()
+	}
+	if s.watchdogThreshold() > 0 {
+		go s.monitorWatchdog()
+	}
+	if s.config.NFC.Trigger == "manual" {
+		defer s.watchTriggerSignal()()
+	}
+	if s.config.NFC.Trigger == "hotkey" {
+		stop, err := s.watchTriggerHotkey()
+		if err != nil {
+			return fmt.Errorf("failed to watch the trigger hotkey %s: %v", s.config.NFC.TriggerHotkey, err)
+		}
+		defer stop()
+	}
+
+	for {
+		if err := s.runServiceLoop(); err != nil {
+			s.statusManager.SetScanning(false)
+
+			// Retrying can't help when the device prompt was interrupted or has no input
+			if errors.Is(err, errShutdownRequested) || s.shutdown.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, errNoInput) {
+				return err
+			}
+
+			s.notificationManager.NotifyErrorThrottled("service-error", "Verbindung zum NFC-Lesegerät verloren. Bitte Gerät überprüfen.")
+			fmt.Printf("Service encountered an error: %v\n", err)
+			s.recordError("", err.Error())
+			fmt.Println(s.statusManager.GetStatus().Summary())
+
+			if s.config.Advanced.AutoReconnect {
+				s.markServiceDown()
+				s.reconnectAttempts++
+				delay := reconnectDelay(s.config.Advanced.ReconnectDelay, s.config.Advanced.MaxReconnectDelay, s.reconnectAttempts)
+				fmt.Printf("Attempting to restart service in %v (attempt %d)...\n", delay, s.reconnectAttempts)
+				s.eventLogger.Log(Event{Type: EventReconnect, Attempt: s.reconnectAttempts, DelaySeconds: int(delay.Seconds())})
+				select {
+				case <-time.After(delay):
+				case <-s.shutdown.Done():
+					return nil
+				}
+				continue
+			} else {
+				return err
+			}
+		}
+	}
+}
+
+// reconnectDelay returns the delay before a reconnect attempt, doubling the base
+// delay with each consecutive attempt up to maxDelay (both in seconds). A cap below
+// the base delay keeps the base delay, as in configs from before the cap existed.
+func reconnectDelay(baseDelay, maxDelay, attempt int) time.Duration {
+	if maxDelay < baseDelay {
+		maxDelay = baseDelay
+	}
+
+	delay := baseDelay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return time.Duration(delay) * time.Second
+}
+
+// markServiceDown records the start of an outage and reports it once it lasts
+// longer than the configured threshold
+func (s *service) markServiceDown() {
+	if s.downSince.IsZero() {
+		s.downSince = time.Now()
+	}
+
+	threshold := time.Duration(s.config.Advanced.OutageAlertAfter) * time.Second
+	downFor := time.Since(s.downSince)
+	if threshold > 0 && !s.outageReported && downFor >= threshold {
+		fmt.Printf("NFC reader unavailable for %v, still trying to reconnect\n", downFor.Round(time.Second))
+		s.notificationManager.NotifyErrorThrottled("service-outage", fmt.Sprintf("NFC-Lesegerät seit %d Minuten nicht verfügbar. Bitte Gerät und Verbindung prüfen.", int(downFor.Minutes())))
+		s.outageReported = true
+	}
+}
+
+// markServiceUp resets the reconnect backoff once the service is reading cards again
+func (s *service) markServiceUp() {
+	if !s.downSince.IsZero() {
+		fmt.Printf("Service recovered after %v\n", time.Since(s.downSince).Round(time.Second))
+	}
+	s.reconnectAttempts = 0
+	s.downSince = time.Time{}
+	s.outageReported = false
+	s.statusManager.SetScanning(true)
+}
+
+// idleCheckInterval is how often the idle monitor checks for card activity
+const idleCheckInterval = 30 * time.Second
+
+// monitorIdle periodically checks for readers without card activity
+func (s *service) monitorIdle() {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.checkIdle(now)
+		case <-s.shutdown.Done():
+			return
+		}
+	}
+}
+
+// checkIdle notifies once per idle period when no card was read within the idle alert window
+func (s *service) checkIdle(now time.Time) {
+	since, scanning := s.statusManager.IdleSince()
+	if !scanning || since.Equal(s.idleReportedSince) {
+		return
+	}
+
+	window := time.Duration(s.config.NFC.IdleAlertMinutes) * time.Minute
+	if now.Sub(since) < window {
+		return
+	}
+
+	fmt.Printf("No card activity for %d minutes\n", s.config.NFC.IdleAlertMinutes)
+	s.notificationManager.NotifyInfo("NFC Lesegerät", fmt.Sprintf("Keine Kartenaktivität seit %d Minuten", s.config.NFC.IdleAlertMinutes))
+	s.idleReportedSince = since
+}
+
+func (s *service) runServiceLoop() error {
+	// Establish PC/SC context with retry logic
+	var scardCtx *scard.Context
+	err := s.retryManager.Retry(func() error {
+		var err error
+		scardCtx, err = scard.EstablishContext()
+		if err != nil {
+			// Track context establishment failure
+			if s.restartManager.TrackContextFailure(err) {
+				// Restart was triggered, this will never return
+				return nil
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to establish PC/SC context: %v", err)
+	}
+
+	// Context established successfully, reset failure counter
+	s.restartManager.ResetFailureCount()
+	ctx := scardContext{scardCtx}
+	defer ctx.Release()
+
+	// Let the watchdog unblock a wedged card wait
+	s.setCancelWait(scardCtx.Cancel)
+	defer s.setCancelWait(nil)
+
+	// Unblock a pending card wait on shutdown
+	loopDone := make(chan struct{})
+	defer close(loopDone)
+	go func() {
+		select {
+		case <-s.shutdown.Done():
+			scardCtx.Cancel()
+		case <-loopDone:
+		}
+	}()
+
+	// List available readers, waiting for the configured one with wait_for_reader
+	var readers []string
+	if s.config.NFC.WaitForReader {
+		readers, err = s.waitForReader(ctx)
+	} else {
+		readers, err = ctx.ListReaders()
+	}
+	if errors.Is(err, errShutdownRequested) {
+		return err
+	}
+	if err != nil {
+		// Track reader enumeration failure
+		if s.restartManager.TrackSystemFailure("Reader Enumeration", err) {
+			// Restart was triggered, this will never return
+			return nil
+		}
+		return fmt.Errorf("failed to list readers: %v", err)
+	}
+
+	if len(readers) < 1 {
+		return errors.New("Kein NFC-Lesegerät gefunden. Bitte Gerät anschließen und Anwendung neu starten.")
+	}
+
+	fmt.Printf("Found %d device(s):\n", len(readers))
+	for i, reader := range readers {
+		fmt.Printf("[%d] %s\n", i+1, reader)
+	}
+	s.queryReaderInfo(ctx, readers)
+
+	// Select device(s)
+	selectedReaders, err := s.selectReaders(readers)
+	if err != nil {
+		return err
+	}
+	s.notificationManager.SetDevice(strings.Join(selectedReaders, ", "))
+	s.setActiveReaders(selectedReaders)
+	defer s.setActiveReaders(nil)
+
+	// Initialize keyboard
+	kb, err := s.initKeyboard()
+	if err != nil {
+		return err
+	}
+
+	// Linux requires a delay for keyboard initialization
+	if runtime.GOOS == "linux" {
+		time.Sleep(2 * time.Second)
+	}
+
+	// Main card reading loop
+	s.markServiceUp()
+	return s.cardReadingLoop(ctx, selectedReaders, kb)
+}
+
+// newKeyBonding creates the virtual keyboard used to type the output
+func newKeyBonding() (keyboard, error) {
+	kb, err := keybd_event.NewKeyBonding()
+	if err != nil {
+		return nil, err
+	}
+	return &kb, nil
+}
+
+// initKeyboard sets up the virtual keyboard, retrying since it may not be usable yet
+// right after login while the display server is still starting
+func (s *service) initKeyboard() (keyboard, error) {
+	var kb keyboard
+	err := s.keyboardRetryManager.Retry(func() error {
+		var err error
+		kb, err = s.newKeyboard()
+		return err
+	})
+	if err != nil {
+		if runtime.GOOS == "linux" {
+			return nil, fmt.Errorf("failed to initialize keyboard: %v. The virtual keyboard needs write access to /dev/uinput: load the uinput module (modprobe uinput) and run as root or add the user to the input group", err)
+		}
+		return nil, fmt.Errorf("failed to initialize keyboard: %v", err)
+	}
+
+	if s.config.NFC.UnicodeInput {
+		if !unicodeInputAvail {
+			fmt.Println("Warning: windows_unicode_input is only supported on Windows, typing with key codes")
+		}
+		kb = newUnicodeKeyboard(kb)
+	}
+	if s.config.NFC.RequireTextFocus && !textFocusAvail {
+		fmt.Println("Warning: require_text_focus is only supported on Windows, typing without checking the focus")
+	}
+	return kb, nil
+}
+
+func (s *service) Flags() Flags {
+	return s.flags
+}
+
+func (s *service) formatOutput(rx []byte, reader string) string {
+	flags := s.flagsForReader(reader)
+	output := flags.transformOutput(flags.SitePrefix + flags.Prefix + s.formatUID(rx, reader, flags))
+	return flags.duplicateOutput(s.applyScript(rx, reader, output)) + flags.endOutput() + flags.clearOutput()
+}
+
+// formatOutputs formats the UIDs of several cards read at once, separated by the read
+// all separator, with the prefix and end keys typed once
+func (s *service) formatOutputs(uids [][]byte, reader string) string {
+	if len(uids) == 1 {
+		return s.formatOutput(uids[0], reader)
+	}
+
+	flags := s.flagsForReader(reader)
+	var output string
+	for i, uid := range uids {
+		if i > 0 {
+			output += flags.ReadAllSeparator.Output()
+		}
+		output += s.formatUID(uid, reader, flags)
+	}
+	return flags.duplicateOutput(flags.transformOutput(flags.SitePrefix+flags.Prefix+output)) + flags.endOutput() + flags.clearOutput()
+}
+
+// formatUID formats a single UID with the output template, without prefix and end keys
+func (s *service) formatUID(rx []byte, reader string, flags Flags) string {
+	var output string
+	var errorHexFallback bool = false
+	if flags.MifareBlock == nil {
+		rx = flags.normalizeUID(rx)
+	}
+	//Reverse UID in flag set
+	if flags.Reverse {
+		for i, j := 0, len(rx)-1; i < j; i, j = i+1, j-1 {
+			rx[i], rx[j] = rx[j], rx[i]
+		}
+	}
+
+	//Swap nibbles within each byte if flag set
+	if flags.SwapNibbles {
+		for i, rxByte := range rx {
+			rx[i] = rxByte<<4 | rxByte>>4
+		}
+	}
+
+	if flags.Wiegand != nil {
+		output = flags.Wiegand.Format(rx)
+	} else if flags.Decimal {
+		number, err := UIDToUint32(rx)
+		if err != nil {
+			s.notificationManager.NotifyError("Fehler beim Umwandeln der Karten-ID. Verwende Standard-Format.")
+			// Fallback to hex format
+			errorHexFallback = true
+		} else {
+			if flags.DecimalPadding > 0 {
+				output = fmt.Sprintf("%0*d", flags.DecimalPadding, number)
+			} else {
+				output = fmt.Sprintf("%d", number)
+			}
+		}
+	}
+
+	if flags.Wiegand == nil && (!flags.Decimal || errorHexFallback) {
+		for i, rxByte := range rx {
+			var byteStr string
+			if flags.CapsLock {
+				byteStr = fmt.Sprintf("%02X", rxByte)
+			} else {
+				byteStr = fmt.Sprintf("%02x", rxByte)
+			}
+
+			output = output + byteStr
+			if i < len(rx)-1 {
+				output = output + flags.inCharOutput()
+			}
+		}
+	}
+
+	if flags.SplitParity != "" {
+		parity, err := UIDParity(rx, flags.SplitParity, flags.CapsLock)
+		if err != nil {
+			s.notificationManager.NotifyError("Fehler beim Berechnen der Prüfziffer.")
+		} else {
+			output = output + flags.SplitSeparator.Output() + parity
+		}
+	}
+
+	if flags.OutputFormat != "" {
+		output = strings.NewReplacer("{uid}", output, "{reader}", reader, "{seq}", s.sequence.next()).Replace(flags.OutputFormat)
+	}
+
+	return output
+}
+
+// ignoreCardReason returns why a card that just became present on the reader must not be
+// read, or "" if it can be read. Ignored cards need to be removed and presented again.
+func (s *service) ignoreCardReason(reader string) string {
+	switch {
+	case s.paused.Load():
+		return "while reading is paused"
+	case s.config.NFC.StrictRelease && !s.releasedReaders[reader]:
+		return "until the reader was empty (strict release)"
+	case s.config.NFC.IgnoreFirstScan && !s.releasedReaders[reader]:
+		return "until it is removed, it was already present at startup"
+	case time.Now().Before(s.graceUntil):
+		return "during the startup grace period"
+	}
+	return ""
+}
+
+// waitUntilCardPresent blocks until a card is present in one of the readers and returns
+// the reader index and whether the card is mute (present but not responding)
+func (s *service) waitUntilCardPresent(ctx cardContext, readers []string) (int, bool, error) {
+	rs := make([]scard.ReaderState, len(readers))
+	for i := range rs {
+		rs[i].Reader = readers[i]
+		rs[i].CurrentState = scard.StateUnaware
+	}
+	if s.watchesAttach() {
+		// Appended after the readers, so the returned index still points into readers
+		rs = append(rs, scard.ReaderState{Reader: pnpNotification, CurrentState: scard.StateUnaware})
+	}
+
+	for {
+		for i := range rs {
+			if rs[i].Reader == pnpNotification {
+				rs[i].CurrentState = rs[i].EventState
+				continue
+			}
+			if rs[i].EventState&scard.StateEmpty != 0 {
+				s.releasedReaders[rs[i].Reader] = true
+				s.presentBeepRemoved(rs[i].Reader)
+			}
+			if rs[i].EventState&scard.StatePresent != 0 {
+				if reason := s.ignoreCardReason(rs[i].Reader); reason != "" {
+					if rs[i].CurrentState&scard.StatePresent == 0 {
+						s.printScanProgress("Card on %s ignored %s\n", rs[i].Reader, reason)
+					}
+					rs[i].CurrentState = rs[i].EventState
+					continue
+				}
+				if s.config.NFC.StrictRelease {
+					s.releasedReaders[rs[i].Reader] = false
+				}
+				if s.presentStates == nil {
+					s.presentStates = make(map[string]scard.StateFlag)
+				}
+				s.presentStates[rs[i].Reader] = rs[i].EventState
+				return i, rs[i].EventState&scard.StateMute != 0, nil
+			}
+			rs[i].CurrentState = rs[i].EventState
+		}
+		s.waitingForCard.Store(true)
+		if s.deviceChangePending() {
+			s.waitingForCard.Store(false)
+			return -1, false, errReadersChanged
+		}
+		err := s.waitForStatusChange(ctx, rs)
+		s.waitingForCard.Store(false)
+		logReaderStateTransitions(rs)
+		if err != nil && s.shutdown.Err() != nil {
+			return -1, false, errShutdownRequested
+		}
+		if err != nil && s.watchdogTripped.Load() {
+			return -1, false, errWatchdogRestart
+		}
+		if err != nil && s.deviceChangePending() {
+			// Cancelled by ChangeDevice
+			return -1, false, errReadersChanged
+		}
+		if s.hasFallback() && err != nil && isReaderGone(err) {
+			return -1, false, fmt.Errorf("%w: %v", errReadersChanged, err)
+		}
+		if s.hasFallback() && err == nil && readersChanged(rs) {
+			return -1, false, errReadersChanged
+		}
+		if err != nil {
+			// Track reader status monitoring failure
+			if s.restartManager.TrackSystemFailure("Reader Status Monitoring", err) {
+				// Restart was triggered, this will never return
+				return -1, false, nil
+			}
+			return -1, false, err
+		}
+	}
+}
+
+func (s *service) waitUntilCardRelease(ctx cardContext, readers []string, index int) error {
+	return s.waitUntilCardReleaseEvery(ctx, readers, index, 0, nil)
+}
+
+// waitUntilCardReleaseEvery waits until the card is removed, calling tick every
+// interval while it stays on the reader (nil for no tick)
+func (s *service) waitUntilCardReleaseEvery(ctx cardContext, readers []string, index int, interval time.Duration, tick func()) error {
+	rs := make([]scard.ReaderState, 1)
+
+	rs[0].Reader = readers[index]
+	rs[0].CurrentState = scard.StatePresent
+
+	for {
+
+		if rs[0].EventState&scard.StateEmpty != 0 {
+			s.presentBeepRemoved(readers[index])
+			return nil
+		}
+		rs[0].CurrentState = rs[0].EventState
+
+		err := s.waitForStatusChangeEvery(ctx, rs, interval, tick)
+		logReaderStateTransitions(rs)
+		if err != nil && s.shutdown.Err() != nil {
+			return errShutdownRequested
+		}
+		if err != nil && s.watchdogTripped.Load() {
+			return errWatchdogRestart
+		}
+		if err != nil {
+			// Track reader status monitoring failure
+			if s.restartManager.TrackSystemFailure("Reader Status Monitoring", err) {
+				// Restart was triggered, this will never return
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// selectReaders picks the readers to watch: every reader from the configured device
+// list, the single reader chosen via the device number or interactive prompt, or the
+// primary or fallback reader with nfc.fallback_device
+func (s *service) selectReaders(readers []string) ([]string, error) {
+	if s.hasFallback() {
+		return s.selectPrimaryOrFallback(readers)
+	}
+	if len(s.flags.Devices) == 0 {
+		if err := s.selectDevice(readers); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Selected device: [%d] %s\n", s.flags.Device, readers[s.flags.Device-1])
+		s.applyReaderProfiles(readers)
+		return []string{readers[s.flags.Device-1]}, nil
+	}
+
+	var selectedReaders []string
+	for _, device := range s.flags.Devices {
+		reader, err := resolveReader(readers, device)
+		if err != nil {
+			return nil, err
+		}
+		if containsString(selectedReaders, reader) {
+			continue
+		}
+		fmt.Printf("Selected device: %s\n", reader)
+		selectedReaders = append(selectedReaders, reader)
+	}
+
+	s.applyReaderProfiles(readers)
+	return selectedReaders, nil
+}
+
+// reselectReader lists the readers again after errReadersChanged and selects among
+// them, switching to the device requested by ChangeDevice if there is one
+func (s *service) reselectReader(ctx cardContext, current []string, cause error) ([]string, error) {
+	readers, err := ctx.ListReaders()
+	if err != nil && !errors.Is(err, scard.ErrNoReadersAvailable) {
+		return nil, fmt.Errorf("failed to list readers: %v", err)
+	}
+	if device := s.takePendingDevice(); device != "" {
+		if _, err := resolveReader(readers, device); err != nil {
+			fmt.Printf("Device change to %q failed: %v\n", device, err)
+			s.recordError("", fmt.Sprintf("device change to %q failed: %v", device, err))
+		} else {
+			fmt.Printf("Switching to device %q\n", device)
+			s.flags.Devices = []string{device}
+			s.primaryReader = ""
+		}
+	}
+
+	attachWatched := s.watchesAttach()
+	selected, err := s.selectReaders(readers)
+	if err != nil {
+		return nil, err
+	}
+
+	// An error with the same reader still connected means PC/SC rejected the PnP
+	// pseudo reader, watching it again would only fail again
+	if attachWatched && cause != errReadersChanged && selected[0] == current[0] {
+		logWarnf("Reader attach notifications not supported (%v), not switching back to the primary reader", cause)
+		s.noAttachNotify = true
+	}
+	s.notificationManager.SetDevice(strings.Join(selected, ", "))
+	s.setActiveReaders(selected)
+	return selected, nil
+}
+
+// applyReaderProfiles resolves the configured reader profiles against the connected
+// readers and merges each profile over the global flags
+func (s *service) applyReaderProfiles(readers []string) {
+	s.readerFlags = make(map[string]Flags)
+	for device, profile := range s.flags.ReaderProfiles {
+		reader, err := resolveReader(readers, device)
+		if err != nil {
+			// The reader may simply not be plugged in on this machine
+			fmt.Printf("Warning: Reader profile %q not applied: %v\n", device, err)
+			continue
+		}
+
+		flags := s.flags
+		if profile.EndChar != "" {
+			flags.EndChar, _ = StringToCharFlag(profile.EndChar)
+			flags.EndSequence = nil
+		}
+		if profile.InChar != "" {
+			flags.InChar, _ = StringToCharFlag(profile.InChar)
+		}
+		if profile.Prefix != "" {
+			flags.Prefix = profile.Prefix
+		}
+		if profile.Decimal != nil {
+			flags.Decimal = *profile.Decimal
+		}
+		if len(profile.OnReleaseKeys) > 0 {
+			flags.OnReleaseKeys = nil
+			for _, key := range profile.OnReleaseKeys {
+				charFlag, _ := StringToCharFlag(key)
+				flags.OnReleaseKeys = append(flags.OnReleaseKeys, charFlag)
+			}
+		}
+		s.readerFlags[reader] = flags
+		fmt.Printf("Using reader profile %q for %s\n", device, reader)
+	}
+}
+
+// flagsForReader returns the output flags for the reader, falling back to the global flags
+func (s *service) flagsForReader(reader string) Flags {
+	if flags, ok := s.readerFlags[reader]; ok {
+		return flags
+	}
+	return s.flags
+}
+
+// resolveReader finds the reader for a configured device, given either as a
+// 1-based device number or as (part of) the reader name
+func resolveReader(readers []string, device string) (string, error) {
+	if number, err := strconv.Atoi(device); err == nil {
+		if number < 1 || number > len(readers) {
+			return "", fmt.Errorf("device number should be between 1 and %d, got: %d", len(readers), number)
+		}
+		return readers[number-1], nil
+	}
+
+	for _, reader := range readers {
+		if reader == device {
+			return reader, nil
+		}
+	}
+
+	var matches []string
+	for _, reader := range readers {
+		if strings.Contains(strings.ToLower(reader), strings.ToLower(device)) {
+			matches = append(matches, reader)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no reader found matching device %q", device)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("device %q matches several readers: %s", device, strings.Join(matches, ", "))
+	}
+}
+
+// containsString checks if the slice contains the string
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *service) selectDevice(readers []string) error {
+	if s.flags.Device == 0 {
+		// Interactive device selection
+		for {
+			fmt.Print("Enter device number to start: ")
+			deviceStr, err := s.readLine()
+			if err != nil {
+				fmt.Println()
+				// Without a terminal (service, nohup, pipe) a lone reader is the only sensible choice
+				if errors.Is(err, errNoInput) && len(readers) == 1 {
+					fmt.Println("No input available, selecting the only reader")
+					s.flags.Device = 1
+					break
+				}
+				return err
+			}
+
+			if runtime.GOOS == "windows" {
+				deviceStr = strings.Replace(deviceStr, "\r\n", "", -1)
+			} else {
+				deviceStr = strings.Replace(deviceStr, "\n", "", -1)
+			}
+
+			deviceInt, err := strconv.Atoi(deviceStr)
+			if err != nil {
+				fmt.Println("Please input integer value")
+				continue
+			}
+			if deviceInt < 1 || deviceInt > len(readers) {
+				fmt.Printf("Value should be between 1 and %d\n", len(readers))
+				continue
+			}
+			s.flags.Device = deviceInt
+			break
+		}
+	} else if s.flags.Device < 1 || s.flags.Device > len(readers) {
+		return fmt.Errorf("device number should be between 1 and %d, got: %d", len(readers), s.flags.Device)
+	}
+
+	return nil
+}
+
+// readLine reads a line for the interactive prompt. It returns errShutdownRequested
+// if a shutdown signal arrives while waiting, and errNoInput once stdin is closed.
+func (s *service) readLine() (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+
+	lines := make(chan result, 1)
+	go func() {
+		line, err := s.input.ReadString('\n')
+		lines <- result{line, err}
+	}()
+
+	select {
+	case <-s.shutdown.Done():
+		return "", errShutdownRequested
+	case r := <-lines:
+		// A last line without newline is still used, the next read reports EOF
+		if r.err == io.EOF && r.line == "" {
+			return "", errNoInput
+		}
+		if r.err != nil && r.err != io.EOF {
+			return "", fmt.Errorf("failed to read input: %v", r.err)
+		}
+		return r.line, nil
+	}
+}
+
+func (s *service) cardReadingLoop(ctx cardContext, selectedReaders []string, kb keyboard) error {
+	s.watchdogTripped.Store(false)
+	s.aliveAt.Store(time.Now().UnixNano())
+	defer s.aliveAt.Store(0)
+
+	for {
+		err := s.readNextCard(ctx, selectedReaders, kb)
+		if errors.Is(err, errReadersChanged) {
+			if selectedReaders, err = s.reselectReader(ctx, selectedReaders, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readNextCard waits for the next card and processes it. Errors for a single card are
+// reported and swallowed; only errors that should end the reading loop are returned.
+func (s *service) readNextCard(ctx cardContext, selectedReaders []string, kb keyboard) error {
+	if s.deviceChangePending() {
+		return errReadersChanged
+	}
+	if isTriggeredMode(s.config.NFC.Trigger) {
+		return s.readOnTrigger(ctx, selectedReaders, kb)
+	}
+	s.printScanProgress("Waiting for a Card...\n")
+
+	// Wait for card present with error handling
+	index, mute, err := s.waitForCardWithRetry(ctx, selectedReaders)
+	if stopsReadingLoop(err) || errors.Is(err, errReadersChanged) {
+		return err
+	}
+	if err != nil {
+		s.notificationManager.NotifyErrorThrottled("card-error", "Karte konnte nicht erkannt werden. Bitte NFC-Lesegerät überprüfen.")
+		s.recordError("", err.Error())
+		if s.config.Advanced.AutoReconnect {
+			return nil
+		}
+		return err
+	}
+
+	// A mute card is present but doesn't answer, connecting to it would only fail repeatedly
+	if mute {
+		return s.handleMuteCard(ctx, selectedReaders, index)
+	}
+
+	return s.handleProcessCard(ctx, selectedReaders, index, kb)
+}
+
+// handleProcessCard processes the card on the reader, reporting failed reads. It only
+// returns errors that stop the reading loop.
+func (s *service) handleProcessCard(ctx cardContext, selectedReaders []string, index int, kb keyboard) error {
+	if err := s.processCard(ctx, selectedReaders, index, kb); err != nil {
+		if stopsReadingLoop(err) {
+			return err
+		}
+		if errors.Is(err, errCardNotAllowed) {
+			return s.handleRejectedCard(ctx, selectedReaders, index, err)
+		}
+		if errors.Is(err, errNoTextFocus) {
+			return s.handleUnfocusedCard(ctx, selectedReaders, index)
+		}
+		if errors.Is(err, errReaderShared) {
+			s.handleSharedReader(selectedReaders[index], err)
+			return nil
+		}
+		if errors.Is(err, errRepeatBlocked) {
+			return s.handleBlockedRepeat(ctx, selectedReaders, index, err)
+		}
+		if errors.Is(err, errMifareAuth) {
+			s.notificationManager.NotifyErrorThrottled("card-auth", "Karte konnte nicht authentifiziert werden. Falsche Karte oder falscher Schlüssel?")
+			s.audioManager.PlayErrorSound()
+		} else {
+			s.notificationManager.NotifyErrorThrottled("card-error", "Karte konnte nicht gelesen werden. Bitte erneut versuchen.")
+		}
+		fmt.Printf("Card processing failed: %v\n", err)
+		s.recordError(selectedReaders[index], err.Error())
+		// Continue to next card instead of exiting
+	}
+
+	return nil
+}
+
+// printScanProgress prints a routine per-scan progress message, unless
+// console_verbose_scan is disabled
+func (s *service) printScanProgress(format string, args ...interface{}) {
+	if s.config.Advanced.ConsoleVerboseScan {
+		fmt.Printf(format, args...)
+	}
+}
+
+// recordError records an error in the status and the event log, reader is empty
+// if the error isn't tied to a reader. Errors for a reader are failed scans and
+// also go to the scan history.
+func (s *service) recordError(reader, message string) {
+	s.statusManager.RecordError(message)
+	if reader != "" {
+		s.statusManager.RecordScanResult(ScanRecord{Reader: reader, Result: "error", Error: message})
+	}
+	s.eventLogger.Log(Event{Type: EventError, Reader: reader, Message: message})
+	s.notifyErrorHandlers(reader, message)
+}
+
+// handleMuteCard reports a damaged or unsupported card and waits for it to be removed. It
+// only returns errors that stop the reading loop.
+func (s *service) handleMuteCard(ctx cardContext, selectedReaders []string, index int) error {
+	fmt.Println("Card is present but not responding (mute), skipping")
+	s.notificationManager.NotifyErrorThrottled("card-mute", "Karte nicht lesbar/beschädigt. Bitte andere Karte verwenden.")
+	s.recordError(selectedReaders[index], "card is mute")
+	s.audioManager.PlayErrorSound()
+
+	return s.waitForCardRemoval(ctx, selectedReaders, index)
+}
+
+// waitForCardRemoval waits for a card that wasn't typed to be removed, so it isn't
+// handled over and over while it rests on the reader. It only returns errors that stop
+// the reading loop, others are reported and reading goes on.
+func (s *service) waitForCardRemoval(ctx cardContext, selectedReaders []string, index int) error {
+	s.printScanProgress("Waiting for card release...")
+	if err := s.waitUntilCardRelease(ctx, selectedReaders, index); err != nil {
+		if stopsReadingLoop(err) {
+			return err
+		}
+		fmt.Printf("Failed to wait for card release: %v\n", err)
+		return nil
+	}
+	s.printScanProgress("Card released\n")
+	return nil
+}
+
+func (s *service) waitForCardWithRetry(ctx cardContext, readers []string) (int, bool, error) {
+	var index int
+	var mute bool
+	var shutdownErr error
+	err := s.retryManager.Retry(func() error {
+		var err error
+		index, mute, err = s.waitUntilCardPresent(ctx, readers)
+		if stopsReadingLoop(err) || errors.Is(err, errReadersChanged) {
+			// Not worth a retry
+			shutdownErr = err
+			return nil
+		}
+		return err
+	})
+	if shutdownErr != nil {
+		return -1, false, shutdownErr
+	}
+	return index, mute, err
+}
+
+func (s *service) processCard(ctx cardContext, selectedReaders []string, index int, kb keyboard) error {
+	s.printScanProgress("Connecting to card...\n")
+
+	// Read UID (or the configured block) with retry, or all cards in the field with read_all
+	uids, err := s.readUIDs(ctx, selectedReaders[index])
+	if err != nil {
+		return err
+	}
+
+	for _, uidBytes := range uids {
+		s.printScanProgress("UID is: % x (reader: %s)\n", uidBytes, selectedReaders[index])
+	}
+
+	// Without the release wait the same card is read again while it stays on
+	// the reader, so repeated reads of the same UID are debounced
+	if !s.config.NFC.WaitForRelease {
+		if s.isDebounced(bytes.Join(uids, nil)) {
+			s.waitForReaderChange(ctx, selectedReaders, index)
+			return nil
+		}
+	}
+
+	// Format and send keyboard output, formatting may reorder the UID bytes
+	var hexUIDs []string
+	for _, uidBytes := range uids {
+		hexUIDs = append(hexUIDs, fmt.Sprintf("%x", uidBytes))
+	}
+	if err := s.checkRepeats(hexUIDs, selectedReaders[index]); err != nil {
+		return err
+	}
+	output := s.formatOutputs(uids, selectedReaders[index])
+	if err := s.checkTextFocus(); err != nil {
+		return err
+	}
+	s.printScanProgress("Writing as keyboard input...")
+
+	var capsLock CapsLockReport
+	s.whileAlive(func() { capsLock, err = s.writeOutput(output, kb) })
+	if capsLock.Detectable {
+		logDebugf("CAPS Lock protection: %s", capsLock)
+	} else {
+		logDebugf("CAPS Lock protection: detection is unavailable on %s, CAPS Lock is assumed off", runtime.GOOS)
+	}
+	if err != nil {
+		s.notificationManager.NotifyErrorThrottled("keyboard-error", "Karten-ID konnte nicht eingegeben werden. Cursor im richtigen Feld?")
+		s.audioManager.PlayErrorSound()
+		return fmt.Errorf("failed to write keyboard output: %v", err)
+	}
+	if err := s.sequence.commit(); err != nil {
+		logWarnf("%v", err)
+	}
+
+	if s.config.Advanced.ConsoleVerboseScan {
+		fmt.Println("Success!")
+	} else {
+		fmt.Printf("Card read: %s (reader: %s)\n", strings.Join(hexUIDs, ", "), selectedReaders[index])
+	}
+	s.statusManager.RecordScan()
+	s.statusManager.RecordScanResult(ScanRecord{UID: strings.Join(hexUIDs, ","), Output: output, Reader: selectedReaders[index], Result: "success"})
+	for _, uid := range hexUIDs {
+		s.eventLogger.Log(Event{Type: EventScan, UID: uid, Reader: selectedReaders[index], CapsLock: capsLock.String()})
+	}
+	s.rememberOutput(output, kb)
+	s.notifyScanHandlers(ScanEvent{Time: time.Now(), UIDs: hexUIDs, Output: output, Reader: selectedReaders[index], CapsLock: capsLock})
+	s.notificationManager.NotifySuccess(strings.Join(hexUIDs, ", "), selectedReaders[index])
+	s.audioManager.PlaySuccessSound()
+
+	// A triggered read is done, the next trigger reads the card again even if it stayed
+	if isTriggeredMode(s.config.NFC.Trigger) {
+		return nil
+	}
+	if !s.config.NFC.WaitForRelease {
+		s.waitForReaderChange(ctx, selectedReaders, index)
+		return nil
+	}
+
+	// Wait for card removal, typing the output again meanwhile with reemit_interval_ms
+	s.printScanProgress("Waiting for card release...")
+	interval := time.Duration(s.config.NFC.ReemitIntervalMs) * time.Millisecond
+	err = s.waitUntilCardReleaseEvery(ctx, selectedReaders, index, interval, s.reemitter(output, kb))
+	if stopsReadingLoop(err) {
+		return err
+	}
+	if err != nil {
+		s.notificationManager.NotifyError("Fehler beim Warten auf Karten-Entfernung. Karte wurde trotzdem gelesen.")
+		return nil
+	}
+	s.printScanProgress("Card released\n")
+
+	// Reset the target application for the next person
+	if releaseKeys := s.flagsForReader(selectedReaders[index]).releaseOutput(); releaseKeys != "" {
+		s.whileAlive(func() { err = s.writeKeys(releaseKeys, kb) })
+		if err != nil {
+			s.notificationManager.NotifyErrorThrottled("keyboard-error", "Tasten nach Karten-Entfernung konnten nicht eingegeben werden.")
+			return fmt.Errorf("failed to write release keys: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// minReemitIntervalMs is the shortest nfc.reemit_interval_ms, so re-emitting can't
+// flood the focused window
+const minReemitIntervalMs = 100
+
+// reemitter returns the tick that types the output again while the card stays on the
+// reader, or nil when re-emitting is off. After a typing error it stops re-emitting.
+func (s *service) reemitter(output string, kb keyboard) func() {
+	if s.config.NFC.ReemitIntervalMs <= 0 {
+		return nil
+	}
+
+	failed := false
+	return func() {
+		if failed {
+			return
+		}
+		s.printScanProgress("Card still present, typing the output again\n")
+		var err error
+		s.whileAlive(func() { _, err = s.writeOutput(output, kb) })
+		if err != nil {
+			fmt.Printf("Failed to type the output again, stopping until the card is removed: %v\n", err)
+			failed = true
+		}
+	}
+}
+
+// fallbackProtocols are tried in order when the protocol negotiated by ProtocolAny doesn't work
+var fallbackProtocols = []scard.Protocol{scard.ProtocolT1, scard.ProtocolT0}
+
+// connectAndRead connects to the card and reads it. Some cards only answer under one
+// protocol and ProtocolAny occasionally negotiates the other, so on failure the read
+// is retried with each protocol explicitly before giving up.
+func (s *service) connectAndRead(ctx cardContext, reader string) ([]byte, error) {
+	// Connect to card with retry
+	var card cardHandle
+	var waitErr error
+	err := s.retryManager.Retry(func() error {
+		var err error
+		card, err = s.connectShared(ctx, reader, scard.ProtocolAny)
+		if errors.Is(err, errReaderShared) || stopsReadingLoop(err) {
+			// Already waited for the other application, or shutting down, not a PC/SC failure
+			waitErr = err
+			return nil
+		}
+		if err != nil {
+			// Track reader connection failure
+			if s.restartManager.TrackSystemFailure("Reader Connection", err) {
+				// Restart was triggered, this will never return
+				return nil
+			}
+		}
+		return err
+	})
+	if waitErr != nil {
+		return nil, fmt.Errorf("failed to connect to card: %w", waitErr)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to connect to card: %v", err)
+	} else {
+		// Confirm the card right away, reading and typing may take a while
+		s.presentBeep(card, reader)
+		var uidBytes []byte
+		uidBytes, err = s.readCardData(card)
+		card.Disconnect(scard.ResetCard)
+		// A wrong Mifare key or a card that isn't allowed fails under every protocol
+		if err == nil || errors.Is(err, errMifareAuth) || errors.Is(err, errCardNotAllowed) {
+			return uidBytes, err
+		}
+	}
+
+	for _, proto := range fallbackProtocols {
+		fmt.Printf("Reading failed (%v), retrying with protocol %s\n", err, protocolName(proto))
+		card, connectErr := s.connectShared(ctx, reader, proto)
+		if stopsReadingLoop(connectErr) {
+			return nil, connectErr
+		}
+		if connectErr != nil {
+			continue
+		}
+		s.presentBeep(card, reader)
+		uidBytes, readErr := s.readCardData(card)
+		card.Disconnect(scard.ResetCard)
+		if readErr == nil {
+			fmt.Printf("Card read with protocol %s\n", protocolName(proto))
+			return uidBytes, nil
+		}
+	}
+
+	return nil, err
+}
+
+// protocolName returns the display name of a card protocol
+func protocolName(proto scard.Protocol) string {
+	switch proto {
+	case scard.ProtocolT0:
+		return "T=0"
+	case scard.ProtocolT1:
+		return "T=1"
+	}
+	return "any"
+}
+
+// isDebounced checks if the UID was already seen within the debounce window and records the sighting
+func (s *service) isDebounced(uid []byte) bool {
+	uidStr := fmt.Sprintf("%x", uid)
+	now := time.Now()
+	window := time.Duration(s.config.NFC.DebounceMs) * time.Millisecond
+
+	debounced := uidStr == s.lastUID && now.Sub(s.lastUIDSeen) < window
+	s.lastUID = uidStr
+	s.lastUIDSeen = now
+
+	if debounced {
+		s.printScanProgress("Same card seen within debounce window, skipping\n")
+	}
+	return debounced
+}
+
+// waitForReaderChange waits until the reader state changes (e.g. the card is removed)
+// or half the debounce window has passed, so a card resting on the reader is re-read
+// often enough to stay debounced without spinning on the reader
+func (s *service) waitForReaderChange(ctx cardContext, readers []string, index int) {
+	// The state of the card wait carries the pcsc-lite event counter, with a bare
+	// StatePresent pcsc-lite reports a change right away
+	state, ok := s.presentStates[readers[index]]
+	if !ok {
+		state = scard.StatePresent
+	}
+	rs := []scard.ReaderState{{
+		Reader:       readers[index],
+		CurrentState: state,
+	}}
+
+	timeout := time.Duration(s.config.NFC.DebounceMs) * time.Millisecond / 2
+	if timeout < 100*time.Millisecond {
+		timeout = 100 * time.Millisecond
+	}
+
+	err := ctx.GetStatusChange(rs, timeout)
+	if err != nil && err != scard.ErrTimeout {
+		fmt.Printf("Failed to wait for reader state change: %v\n", err)
+		return
+	}
+	logReaderStateTransitions(rs)
+	if rs[0].EventState&scard.StateEmpty != 0 {
+		s.presentBeepRemoved(readers[index])
+	}
+}
+
+// readCardData reads the configured card data, either the UID or a Mifare Classic block
+func (s *service) readCardData(card cardHandle) ([]byte, error) {
+	s.heartbeat()
+	s.warmupRead(card)
+	if err := s.checkCardATR(card); err != nil {
+		return nil, err
+	}
+	if s.flags.MifareBlock != nil {
+		return s.readMifareBlock(card, s.flags.MifareBlock)
+	}
+	return s.readCardUID(card)
+}
+
+// getDataAPDU is the PC/SC GET DATA command returning the card UID
+var getDataAPDU = []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}
+
+// warmupRead sends one GET DATA right after connecting with nfc.warmup_read and discards
+// the result, for readers whose first command after connecting fails
+func (s *service) warmupRead(card cardHandle) {
+	if !s.config.NFC.WarmupRead {
+		return
+	}
+	rsp, err := card.Transmit(getDataAPDU)
+	logDebugf("Warm-up read discarded, response: % x, error: %v", rsp, err)
+}
+
+func (s *service) readCardUID(card cardHandle) ([]byte, error) {
+	var uidBytes []byte
+
+	standard := s.flags.TagStandard
+	if standard == "auto" {
+		standard = detectTagStandard(card)
+	}
+
+	err := s.cardRetryManager.Retry(func() error {
+		rsp, err := transmitAPDU(card, getDataAPDU)
+		if err != nil {
+			return err
+		}
+
+		if standard == "15693" {
+			rsp, err = iso15693UID(rsp)
+			if err != nil {
+				return err
+			}
+		}
+		if err := s.checkUIDLength(rsp); err != nil {
+			return err
+		}
+
+		uidBytes = rsp
+		return nil
+	})
+
+	return uidBytes, err
+}
+
+// IsValidTagStandard checks if the tag standard is supported by readCardUID
+func IsValidTagStandard(standard string) bool {
+	switch standard {
+	case "auto", "14443", "15693":
+		return true
+	}
+	return false
+}
+
+// detectTagStandard reads the tag family from the standard byte of the PC/SC part 3
+// contactless ATR (RID A0 00 00 03 06 followed by the standard), defaulting to 14443
+func detectTagStandard(card cardHandle) string {
+	status, err := card.Status()
+	if err != nil {
+		logDebugf("Failed to read card ATR, assuming ISO14443: %v", err)
+		return "14443"
+	}
+
+	rid := []byte{0xA0, 0x00, 0x00, 0x03, 0x06}
+	i := bytes.Index(status.Atr, rid)
+	if i < 0 || i+len(rid) >= len(status.Atr) {
+		return "14443"
+	}
+
+	switch status.Atr[i+len(rid)] {
+	case 0x09, 0x0A, 0x0B, 0x0C: // ISO15693 part 1 to 4
+		return "15693"
+	}
+	return "14443"
+}
+
+// iso15693UID converts the UID returned for an ISO15693 tag, which readers pass on in
+// transmission order (least significant byte first), to the usual printed order starting with E0
+func iso15693UID(rsp []byte) ([]byte, error) {
+	if len(rsp) != 8 {
+		return nil, fmt.Errorf("ISO15693 UID must be 8 bytes, got %d bytes", len(rsp))
+	}
+
+	uid := make([]byte, len(rsp))
+	for i, b := range rsp {
+		uid[len(rsp)-1-i] = b
+	}
+	return uid, nil
+}
+
+// readMifareBlock loads the sector key into the reader, authenticates the block and reads its 16 bytes
+func (s *service) readMifareBlock(card cardHandle, read *MifareBlockRead) ([]byte, error) {
+	var blockBytes []byte
+
+	err := s.cardRetryManager.Retry(func() error {
+		// LOAD AUTHENTICATION KEYS into volatile key slot 0
+		loadKey := append([]byte{0xFF, 0x82, 0x00, 0x00, byte(len(read.Key))}, read.Key...)
+		if _, err := transmitAPDU(card, loadKey); err != nil {
+			return fmt.Errorf("failed to load mifare key: %v", err)
+		}
+
+		// GENERAL AUTHENTICATE with the key in slot 0
+		auth := []byte{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, read.Block, read.KeyType, 0x00}
+		if _, err := transmitAPDU(card, auth); err != nil {
+			// A wrong key or card fails every attempt
+			return permanent(fmt.Errorf("%w for block %d: %v", errMifareAuth, read.Block, err))
+		}
+
+		// READ BINARY BLOCKS, 16 bytes
+		rsp, err := transmitAPDU(card, []byte{0xFF, 0xB0, 0x00, read.Block, 0x10})
+		if err != nil {
+			return fmt.Errorf("failed to read mifare block %d: %v", read.Block, err)
+		}
+
+		blockBytes = rsp
+		return nil
+	})
+
+	return blockBytes, err
+}
+
+// transmitAPDU sends a command to the card and returns the response data without the status word
+func transmitAPDU(card cardHandle, cmd []byte) ([]byte, error) {
+	rsp, err := card.Transmit(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("card transmission failed: %v", err)
+	}
+
+	if len(rsp) < 2 {
+		return nil, errors.New("insufficient response bytes from card")
+	}
+
+	// Check response code - two last bytes of response
+	rspCodeBytes := rsp[len(rsp)-2:]
+	successResponseCode := []byte{0x90, 0x00}
+	if !bytes.Equal(rspCodeBytes, successResponseCode) {
+		return nil, fmt.Errorf("card operation failed, response code: % x", rspCodeBytes)
+	}
+
+	return rsp[0 : len(rsp)-2], nil
+}
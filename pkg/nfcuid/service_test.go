@@ -0,0 +1,1385 @@
+package nfcuid
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// errMockExhausted is returned by the mock reader once all scripted events are consumed
+var errMockExhausted = errors.New("mock reader: no more events")
+
+// mockContext is a scripted PC/SC context. Each GetStatusChange call applies the next
+// scripted state for every queried reader that still has states queued.
+type mockContext struct {
+	readers   []string
+	states    map[string][]scard.StateFlag
+	card      *mockCard
+	cards     map[scard.Protocol]*mockCard // Cards per requested protocol, overrides card when set
+	protocols []scard.Protocol             // Requested protocol of each connect
+	connects  int
+}
+
+func (m *mockContext) ListReaders() ([]string, error) {
+	return m.readers, nil
+}
+
+func (m *mockContext) GetStatusChange(rs []scard.ReaderState, timeout time.Duration) error {
+	applied := false
+	for i := range rs {
+		queue := m.states[rs[i].Reader]
+		if len(queue) == 0 {
+			continue
+		}
+		rs[i].EventState = queue[0]
+		m.states[rs[i].Reader] = queue[1:]
+		applied = true
+	}
+	if !applied {
+		return errMockExhausted
+	}
+	return nil
+}
+
+func (m *mockContext) Connect(reader string, mode scard.ShareMode, proto scard.Protocol) (cardHandle, error) {
+	m.connects++
+	m.protocols = append(m.protocols, proto)
+	if m.cards != nil {
+		if card := m.cards[proto]; card != nil {
+			return card, nil
+		}
+		return nil, scard.ErrProtoMismatch
+	}
+	if m.card == nil {
+		return nil, scard.ErrNoSmartcard
+	}
+	return m.card, nil
+}
+
+func (m *mockContext) Release() error {
+	return nil
+}
+
+// mockCard answers APDUs from a scripted list of responses
+type mockCard struct {
+	atr       []byte
+	responses [][]byte
+	commands  [][]byte
+	escape    []byte // Response to escape commands, nil when the reader doesn't support them
+}
+
+func (c *mockCard) Status() (*scard.CardStatus, error) {
+	return &scard.CardStatus{Atr: c.atr}, nil
+}
+
+func (c *mockCard) Transmit(cmd []byte) ([]byte, error) {
+	c.commands = append(c.commands, cmd)
+	if len(c.responses) == 0 {
+		return nil, errMockExhausted
+	}
+	rsp := c.responses[0]
+	c.responses = c.responses[1:]
+	return rsp, nil
+}
+
+func (c *mockCard) Disconnect(d scard.Disposition) error {
+	return nil
+}
+
+func (c *mockCard) Control(ioctl uint32, in []byte) ([]byte, error) {
+	c.commands = append(c.commands, in)
+	if c.escape == nil {
+		return nil, scard.ErrUnsupportedFeature
+	}
+	return c.escape, nil
+}
+
+// mockKeyboard records every launched key instead of typing it
+type mockKeyboard struct {
+	keys     []int
+	shift    bool
+	shortcut bool // Ctrl (or Cmd) is held
+	typed    []keySet
+	chords   map[int]bool // Indexes of typed keys pressed with Ctrl (or Cmd)
+}
+
+func (k *mockKeyboard) SetKeys(keys ...int) {
+	k.keys = keys
+}
+
+func (k *mockKeyboard) HasSHIFT(shift bool) {
+	k.shift = shift
+}
+
+func (k *mockKeyboard) HasCTRL(ctrl bool) {
+	k.shortcut = ctrl
+}
+
+func (k *mockKeyboard) HasSuper(super bool) {
+	k.shortcut = super
+}
+
+func (k *mockKeyboard) Launching() error {
+	for _, key := range k.keys {
+		if k.shortcut {
+			if k.chords == nil {
+				k.chords = make(map[int]bool)
+			}
+			k.chords[len(k.typed)] = true
+		}
+		k.typed = append(k.typed, keySet{key, k.shift})
+	}
+	return nil
+}
+
+// text reconstructs the typed text from the recorded keys, using \n, \t, \b and \x1b for
+// Enter, Tab, Backspace and Escape and ^ before keys pressed with Ctrl (or Cmd)
+func (k *mockKeyboard) text() string {
+	var text string
+	for i, typed := range k.typed {
+		if k.chords[i] {
+			text += "^"
+		}
+		switch typed.code {
+		case names["ENTER"].code:
+			text += "\n"
+			continue
+		case names["TAB"].code:
+			text += "\t"
+			continue
+		case names["BACKSPACE"].code:
+			text += "\b"
+			continue
+		case names["ESCAPE"].code:
+			text += "\x1b"
+			continue
+		}
+		for name, key := range names {
+			if len(name) == 1 && key == typed {
+				text += name
+				break
+			}
+		}
+	}
+	return text
+}
+
+// newTestService creates a service with notifications, audio and self-restart disabled
+func newTestService(config *Config) *service {
+	config.Notifications.Enabled = false
+	config.Audio.Enabled = false
+	config.Advanced.SelfRestart = false
+	config.Advanced.ReconnectDelay = 0
+
+	notificationManager := NewNotificationManager(config)
+	return NewService(config.ToFlags(), config, notificationManager, NewRestartManager(config, notificationManager, nil), NewAudioManager(config), NewStatusManager(), nil).(*service)
+}
+
+func TestUIDParity(t *testing.T) {
+	tests := []struct {
+		uid      []byte
+		mode     string
+		upper    bool
+		expected string
+		name     string
+	}{
+		{[]byte{0x01, 0x02, 0x03, 0x04}, "even", false, "1", "even parity with odd bit count"},
+		{[]byte{0x01, 0x02, 0x03, 0x04}, "odd", false, "0", "odd parity with odd bit count"},
+		{[]byte{0x03, 0x00, 0x00, 0x00}, "even", false, "0", "even parity with even bit count"},
+		{[]byte{0x03, 0x00, 0x00, 0x00}, "odd", false, "1", "odd parity with even bit count"},
+		{[]byte{0x04, 0xae, 0x65, 0xca}, "xor", false, "05", "xor of bytes"},
+		{[]byte{0xf0, 0x0f, 0x5a, 0x00}, "xor", true, "A5", "xor of bytes uppercase"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := UIDParity(test.uid, test.mode, test.upper)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, result)
+			}
+		})
+	}
+
+	if _, err := UIDParity([]byte{0x01}, "luhn", false); err == nil {
+		t.Errorf("Expected error for unknown parity mode")
+	}
+}
+
+func TestFormatOutputSplit(t *testing.T) {
+	tests := []struct {
+		flags    Flags
+		uid      []byte
+		expected string
+		name     string
+	}{
+		{Flags{}, []byte{0x04, 0xae, 0x65, 0xca}, "04ae65ca", "split output disabled"},
+		{Flags{SplitParity: "even", SplitSeparator: CharFlagTab}, []byte{0x04, 0xae, 0x65, 0xca}, "04ae65ca\\t0", "hex with tab separated parity"},
+		{Flags{Decimal: true, SplitParity: "odd", SplitSeparator: CharFlagTab, EndChar: CharFlagEnter}, []byte{0x01, 0x00, 0x00, 0x00}, "1\\t0\\n", "decimal with parity and end char"},
+		{Flags{CapsLock: true, SplitParity: "xor", SplitSeparator: CharFlagSemiColon}, []byte{0x04, 0xae, 0x65, 0xca}, "04AE65CA;05", "xor parity with custom separator"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &service{flags: test.flags}
+			result := s.formatOutput(test.uid, "Reader 0")
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatOutputDuplicate(t *testing.T) {
+	tests := []struct {
+		separator string
+		format    string
+		expected  string
+		name      string
+	}{
+		{"tab", "{uid}", "04a22b91\\t04a22b91\\n", "tab separated"},
+		{"semicolon", "{uid}", "04a22b91;04a22b91\\n", "custom separator"},
+		{"tab", "ID-{uid}", "ID-04a22b91\\tID-04a22b91\\n", "template in both fields"},
+		{"enter", "{uid}:{reader}", "04a22b91:Reader 0\\n04a22b91:Reader 0\\n", "reader token in both fields"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.EndChar = "enter"
+			config.NFC.OutputFormat = test.format
+			config.NFC.DuplicateOutput.Enabled = true
+			config.NFC.DuplicateOutput.Separator = test.separator
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+			result := s.formatOutput([]byte{0x04, 0xa2, 0x2b, 0x91}, "Reader 0")
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.NFC.DuplicateOutput.Enabled = true
+	config.NFC.SplitOutput.Enabled = true
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected duplicate_output with split_output to be rejected")
+	}
+}
+
+func TestReadNextCardMute(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states: map[string][]scard.StateFlag{
+			"Reader 0": {scard.StatePresent | scard.StateMute, scard.StateEmpty},
+		},
+		card: &mockCard{},
+	}
+	s := newTestService(DefaultConfig())
+
+	if err := s.readNextCard(ctx, ctx.readers, &mockKeyboard{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ctx.connects != 0 {
+		t.Errorf("Expected no connection attempt for a mute card, got %d", ctx.connects)
+	}
+	if len(ctx.states["Reader 0"]) != 0 {
+		t.Errorf("Expected the card release to be awaited after a mute card")
+	}
+}
+
+func TestReadNextCardMultipleReaders(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Entrance", "Exit"},
+		states: map[string][]scard.StateFlag{
+			"Entrance": {scard.StateEmpty},
+			"Exit":     {scard.StatePresent, scard.StateEmpty},
+		},
+		card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+	}
+	config := DefaultConfig()
+	config.NFC.Devices = []string{"1", "exit"}
+	config.NFC.OutputFormat = "{reader}:{uid}"
+	config.NFC.EndChar = "enter"
+	s := newTestService(config)
+
+	selectedReaders, err := s.selectReaders(ctx.readers)
+	if err != nil {
+		t.Fatalf("Unexpected error selecting readers: %v", err)
+	}
+	if len(selectedReaders) != 2 {
+		t.Fatalf("Expected both readers to be selected, got %v", selectedReaders)
+	}
+
+	kb := &mockKeyboard{}
+	if err := s.readNextCard(ctx, selectedReaders, kb); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result := kb.text(); result != "Exit:04a22b91\n" {
+		t.Errorf("Expected output tagged with the exit reader, got %q", result)
+	}
+}
+
+func TestReadNextCardStrictRelease(t *testing.T) {
+	tests := []struct {
+		strict    bool
+		remaining int
+		name      string
+	}{
+		{false, 1, "second card read right away"},
+		{true, 0, "second card ignored until the reader was empty"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Card A is read, card B is slid on before A is lifted, then both are removed
+			// and B is placed again
+			ctx := &mockContext{
+				readers: []string{"Reader 0"},
+				states: map[string][]scard.StateFlag{
+					"Reader 0": {scard.StateEmpty, scard.StatePresent, scard.StatePresent, scard.StatePresent, scard.StateEmpty, scard.StatePresent},
+				},
+				card: &mockCard{responses: [][]byte{
+					{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00},
+					{0x04, 0x11, 0x22, 0x33, 0x90, 0x00},
+				}},
+			}
+			config := DefaultConfig()
+			config.NFC.WaitForRelease = false
+			config.NFC.StrictRelease = test.strict
+			config.NFC.EndChar = "enter"
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			for i := 0; i < 2; i++ {
+				if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			if result := kb.text(); result != "04a22b91\n04112233\n" {
+				t.Errorf("Expected both cards to be typed, got %q", result)
+			}
+			if remaining := len(ctx.states["Reader 0"]); remaining != test.remaining {
+				t.Errorf("Expected %d unread reader states, got %d", test.remaining, remaining)
+			}
+		})
+	}
+}
+
+func TestReadNextCardIgnoreFirstScan(t *testing.T) {
+	tests := []struct {
+		ignoreFirst bool
+		graceMs     int
+		typed       bool
+		remaining   int
+		name        string
+	}{
+		{false, 0, true, 1, "card present at startup is read"},
+		{true, 0, true, 0, "card present at startup is ignored until removed"},
+		{false, 60000, false, 0, "cards ignored during the startup grace period"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// A card is already on the reader when scanning begins, then it is removed
+			// and presented again
+			ctx := &mockContext{
+				readers: []string{"Reader 0"},
+				states: map[string][]scard.StateFlag{
+					"Reader 0": {scard.StatePresent, scard.StateEmpty, scard.StatePresent},
+				},
+				card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+			}
+			config := DefaultConfig()
+			config.NFC.WaitForRelease = false
+			config.NFC.IgnoreFirstScan = test.ignoreFirst
+			config.NFC.StartupGraceMs = test.graceMs
+			config.NFC.EndChar = "enter"
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if typed := kb.text() == "04a22b91\n"; typed != test.typed {
+				t.Errorf("Expected card typed: %v, got %q", test.typed, kb.text())
+			}
+			if remaining := len(ctx.states["Reader 0"]); remaining != test.remaining {
+				t.Errorf("Expected %d unread reader states, got %d", test.remaining, remaining)
+			}
+		})
+	}
+}
+
+func TestInitKeyboardRetries(t *testing.T) {
+	tests := []struct {
+		failures int
+		isValid  bool
+		name     string
+	}{
+		{0, true, "first attempt succeeds"},
+		{1, true, "fails once then succeeds"},
+		{3, false, "fails on every attempt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Advanced.KeyboardInitAttempts = 3
+			config.Advanced.KeyboardInitDelayMs = 0
+			s := newTestService(config)
+
+			calls := 0
+			s.newKeyboard = func() (keyboard, error) {
+				calls++
+				if calls <= test.failures {
+					return nil, errors.New("display not ready")
+				}
+				return &mockKeyboard{}, nil
+			}
+
+			kb, err := s.initKeyboard()
+			if test.isValid != (err == nil) {
+				t.Fatalf("Expected valid=%v, got error: %v", test.isValid, err)
+			}
+			if test.isValid && kb == nil {
+				t.Errorf("Expected a keyboard")
+			}
+			if expected := min(test.failures+1, 3); calls != expected {
+				t.Errorf("Expected %d attempts, got %d", expected, calls)
+			}
+		})
+	}
+}
+
+func TestResolveReader(t *testing.T) {
+	readers := []string{"ACS ACR122U PICC Interface 00 00", "ACS ACR1252 1S CL Reader PICC 01 00", "ACS ACR1252 1S CL Reader SAM 01 01"}
+
+	tests := []struct {
+		device   string
+		expected string
+		isValid  bool
+		name     string
+	}{
+		{"1", readers[0], true, "device number"},
+		{"4", "", false, "device number out of range"},
+		{readers[1], readers[1], true, "exact reader name"},
+		{"acr122u", readers[0], true, "partial reader name"},
+		{"ACR1252", "", false, "ambiguous reader name"},
+		{"Omnikey", "", false, "unknown reader name"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := resolveReader(readers, test.device)
+			if test.isValid && err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !test.isValid && err == nil {
+				t.Fatalf("Expected an error for device %q", test.device)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestReaderProfiles(t *testing.T) {
+	readers := []string{"Entrance", "Exit", "Office"}
+	decimal := true
+
+	config := DefaultConfig()
+	config.NFC.Devices = []string{"entrance", "exit", "office"}
+	config.NFC.EndChar = "enter"
+	config.NFC.ReaderProfiles = map[string]ReaderProfile{
+		"2":       {EndChar: "none", Prefix: "OUT:"},
+		"office":  {InChar: "hyphen", Decimal: &decimal},
+		"Storage": {Prefix: "IGNORED:"},
+	}
+	s := newTestService(config)
+
+	if _, err := s.selectReaders(readers); err != nil {
+		t.Fatalf("Unexpected error selecting readers: %v", err)
+	}
+
+	tests := []struct {
+		reader   string
+		expected string
+		name     string
+	}{
+		{"Entrance", "04a22b91\\n", "reader without profile uses global settings"},
+		{"Exit", "OUT:04a22b91", "profile selected by device number"},
+		{"Office", "2435555844\\n", "profile selected by reader name"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := s.formatOutput([]byte{0x04, 0xa2, 0x2b, 0x91}, test.reader)
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestReadNextCardReaderProfile(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Entrance", "Exit"},
+		states: map[string][]scard.StateFlag{
+			"Entrance": {scard.StateEmpty},
+			"Exit":     {scard.StatePresent, scard.StateEmpty},
+		},
+		card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+	}
+	config := DefaultConfig()
+	config.NFC.Devices = []string{"1", "2"}
+	config.NFC.EndChar = "enter"
+	config.NFC.ReaderProfiles = map[string]ReaderProfile{"exit": {EndChar: "none", Prefix: "OUT:", OnReleaseKeys: []string{"escape"}}}
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	s := newTestService(config)
+
+	selectedReaders, err := s.selectReaders(ctx.readers)
+	if err != nil {
+		t.Fatalf("Unexpected error selecting readers: %v", err)
+	}
+
+	kb := &mockKeyboard{}
+	if err := s.readNextCard(ctx, selectedReaders, kb); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result := kb.text(); result != "OUT:04a22b91\x1b" {
+		t.Errorf("Expected the exit profile to be applied, including its release keys, got %q", result)
+	}
+}
+
+func TestProcessCardOnReleaseKeys(t *testing.T) {
+	uidResponse := []byte{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}
+
+	tests := []struct {
+		states    []scard.StateFlag
+		responses [][]byte
+		expected  string
+		name      string
+	}{
+		{[]scard.StateFlag{scard.StatePresent, scard.StateEmpty}, [][]byte{uidResponse}, "04a22b91\n\x1b", "keys after release"},
+		{[]scard.StateFlag{scard.StatePresent}, [][]byte{uidResponse}, "04a22b91\n", "release wait failed"},
+		{[]scard.StateFlag{scard.StatePresent, scard.StateEmpty}, nil, "", "read failure"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &mockContext{
+				readers: []string{"Reader 0"},
+				states:  map[string][]scard.StateFlag{"Reader 0": test.states},
+				card:    &mockCard{responses: test.responses},
+			}
+			config := DefaultConfig()
+			config.NFC.EndChar = "enter"
+			config.NFC.OnReleaseKeys = []string{"escape"}
+			config.Advanced.CardReadAttempts = 1
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			s.readNextCard(ctx, ctx.readers, kb)
+			if result := kb.text(); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.NFC.OnReleaseKeys = []string{"escape"}
+	config.NFC.WaitForRelease = false
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected on release keys without wait for release to be rejected")
+	}
+}
+
+// presentContext is a reader where the card stays present for a number of timed status
+// waits, each ending in a timeout, before the queued states are replayed
+type presentContext struct {
+	*mockContext
+	timeouts int
+}
+
+func (c *presentContext) GetStatusChange(readerStates []scard.ReaderState, timeout time.Duration) error {
+	if timeout >= 0 && c.timeouts > 0 {
+		c.timeouts--
+		time.Sleep(timeout)
+		return scard.ErrTimeout
+	}
+	return c.mockContext.GetStatusChange(readerStates, timeout)
+}
+
+// seedingContext records the state each status wait is seeded with
+type seedingContext struct {
+	*mockContext
+	seeds []scard.StateFlag
+}
+
+func (c *seedingContext) GetStatusChange(readerStates []scard.ReaderState, timeout time.Duration) error {
+	c.seeds = append(c.seeds, readerStates[0].CurrentState)
+	return c.mockContext.GetStatusChange(readerStates, timeout)
+}
+
+func TestWaitForReaderChangeSeed(t *testing.T) {
+	// pcsc-lite keeps an event counter in the upper 16 bits of the state
+	present := scard.StatePresent | scard.StateChanged | 0x30000
+	ctx := &seedingContext{mockContext: &mockContext{
+		readers: []string{"Reader 0"},
+		states:  map[string][]scard.StateFlag{"Reader 0": {present, scard.StateEmpty}},
+	}}
+	config := DefaultConfig()
+	config.NFC.WaitForRelease = false
+	s := newTestService(config)
+
+	index, _, err := s.waitUntilCardPresent(ctx, ctx.readers)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s.waitForReaderChange(ctx, ctx.readers, index)
+
+	if seed := ctx.seeds[len(ctx.seeds)-1]; seed != present {
+		t.Errorf("Expected the reader change wait seeded with %#x, got %#x", present, seed)
+	}
+}
+
+func TestProcessCardReemit(t *testing.T) {
+	uidResponse := []byte{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}
+
+	tests := []struct {
+		reemitMs int
+		timeouts int
+		expected string
+		name     string
+	}{
+		{100, 2, "04a22b91\n04a22b91\n04a22b91\n", "re-emitted while present"},
+		{100, 0, "04a22b91\n", "released before the interval"},
+		{0, 2, "04a22b91\n", "disabled"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &presentContext{
+				mockContext: &mockContext{
+					readers: []string{"Reader 0"},
+					states:  map[string][]scard.StateFlag{"Reader 0": {scard.StatePresent, scard.StateEmpty}},
+					card:    &mockCard{responses: [][]byte{uidResponse}},
+				},
+				timeouts: test.timeouts,
+			}
+			config := DefaultConfig()
+			config.NFC.EndChar = "enter"
+			config.NFC.ReemitIntervalMs = test.reemitMs
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result := kb.text(); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+
+	for _, reemitMs := range []int{-1, 50} {
+		config := DefaultConfig()
+		config.NFC.ReemitIntervalMs = reemitMs
+		if err := validateConfig(config); err == nil {
+			t.Errorf("Expected reemit interval %d to be rejected", reemitMs)
+		}
+	}
+	config := DefaultConfig()
+	config.NFC.ReemitIntervalMs = 500
+	config.NFC.WaitForRelease = false
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected reemit interval without wait for release to be rejected")
+	}
+}
+
+func TestReadMifareBlock(t *testing.T) {
+	block := []byte{0x00, 0x00, 0x30, 0x39, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	config := DefaultConfig()
+	config.NFC.ReadMode = "mifare_block"
+	config.NFC.MifareBlock.Block = 4
+	config.NFC.MifareBlock.KeyType = "B"
+	config.NFC.MifareBlock.Key = "A0A1A2A3A4A5"
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	s := newTestService(config)
+
+	card := &mockCard{responses: [][]byte{{0x90, 0x00}, {0x90, 0x00}, append(append([]byte{}, block...), 0x90, 0x00)}}
+	result, err := s.readCardData(card)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(result, block) {
+		t.Errorf("Expected block data % x, got % x", block, result)
+	}
+
+	expected := [][]byte{
+		{0xFF, 0x82, 0x00, 0x00, 0x06, 0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5},
+		{0xFF, 0x86, 0x00, 0x00, 0x05, 0x01, 0x00, 0x04, 0x61, 0x00},
+		{0xFF, 0xB0, 0x00, 0x04, 0x10},
+	}
+	if len(card.commands) != len(expected) {
+		t.Fatalf("Expected %d APDUs, got %d: % x", len(expected), len(card.commands), card.commands)
+	}
+	for i, cmd := range expected {
+		if !bytes.Equal(card.commands[i], cmd) {
+			t.Errorf("APDU %d: expected % x, got % x", i, cmd, card.commands[i])
+		}
+	}
+}
+
+func TestReadMifareBlockAuthFailure(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.ReadMode = "mifare_block"
+	config.Advanced.CardReadAttempts = 3
+	s := newTestService(config)
+
+	card := &mockCard{responses: [][]byte{{0x90, 0x00}, {0x63, 0x00}, {0x90, 0x00}, {0x63, 0x00}}}
+	_, err := s.readCardData(card)
+	if !errors.Is(err, errMifareAuth) {
+		t.Fatalf("Expected an authentication error, got: %v", err)
+	}
+	if len(card.commands) != 2 {
+		t.Errorf("Expected no read or retry after failed authentication, got %d APDUs", len(card.commands))
+	}
+}
+
+func TestWarmupRead(t *testing.T) {
+	tests := []struct {
+		warmup   bool
+		warmRsp  []byte // Response to the first GET DATA after connecting
+		expected []byte
+		name     string
+	}{
+		{false, []byte{0x01, 0x02, 0x03, 0x04, 0x90, 0x00}, []byte{0x01, 0x02, 0x03, 0x04}, "first read used without warm-up"},
+		{true, []byte{0x01, 0x02, 0x03, 0x04, 0x90, 0x00}, []byte{0x04, 0xa2, 0x2b, 0x91}, "successful warm-up discarded"},
+		{true, []byte{0x6f, 0x00}, []byte{0x04, 0xa2, 0x2b, 0x91}, "failed warm-up ignored"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.WarmupRead = test.warmup
+			config.Advanced.CardReadAttempts = 1
+			s := newTestService(config)
+
+			card := &mockCard{responses: [][]byte{test.warmRsp, {0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}}
+			uid, err := s.readCardData(card)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(uid, test.expected) {
+				t.Errorf("Expected UID % x, got % x", test.expected, uid)
+			}
+
+			reads := 1
+			if test.warmup {
+				reads = 2
+			}
+			if len(card.commands) != reads {
+				t.Fatalf("Expected %d APDUs, got %d: % x", reads, len(card.commands), card.commands)
+			}
+			for i, cmd := range card.commands {
+				if !bytes.Equal(cmd, getDataAPDU) {
+					t.Errorf("APDU %d: expected GET DATA, got % x", i, cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectDeviceInput(t *testing.T) {
+	readers := []string{"Reader 0", "Reader 1"}
+
+	tests := []struct {
+		input    string
+		expected int
+		err      error
+		name     string
+	}{
+		{"2\n", 2, nil, "valid device number"},
+		{"abc\n5\n1\n", 1, nil, "invalid values are asked again"},
+		{"2", 2, nil, "last line without newline"},
+		{"", 0, errNoInput, "closed stdin"},
+		{"abc\n", 0, errNoInput, "closed stdin after invalid value"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestService(DefaultConfig())
+			s.input = bufio.NewReader(strings.NewReader(test.input))
+
+			err := s.selectDevice(readers)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Expected error %v, got %v", test.err, err)
+			}
+			if s.flags.Device != test.expected {
+				t.Errorf("Expected device %d, got %d", test.expected, s.flags.Device)
+			}
+		})
+	}
+}
+
+func TestSelectDeviceClosedStdinSingleReader(t *testing.T) {
+	s := newTestService(DefaultConfig())
+	s.input = bufio.NewReader(strings.NewReader(""))
+
+	if err := s.selectDevice([]string{"Reader 0"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s.flags.Device != 1 {
+		t.Errorf("Expected the only reader to be selected, got device %d", s.flags.Device)
+	}
+}
+
+func TestSelectDeviceShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A pipe that is never written blocks like an idle terminal
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	s := newTestService(DefaultConfig())
+	s.shutdown = ctx
+	s.input = bufio.NewReader(reader)
+
+	if err := s.selectDevice([]string{"Reader 0"}); !errors.Is(err, errShutdownRequested) {
+		t.Fatalf("Expected shutdown error, got %v", err)
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	tests := []struct {
+		base     int
+		max      int
+		attempt  int
+		expected time.Duration
+		name     string
+	}{
+		{2, 60, 1, 2 * time.Second, "first attempt uses base delay"},
+		{2, 60, 2, 4 * time.Second, "second attempt doubles"},
+		{2, 60, 4, 16 * time.Second, "fourth attempt"},
+		{2, 60, 6, 60 * time.Second, "capped at max delay"},
+		{2, 60, 100, 60 * time.Second, "many attempts stay capped"},
+		{0, 60, 5, 0, "zero base delay"},
+		{5, 5, 3, 5 * time.Second, "max equal to base"},
+		{90, 60, 3, 90 * time.Second, "max below base keeps base delay"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := reconnectDelay(test.base, test.max, test.attempt)
+			if result != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestServiceOutageTracking(t *testing.T) {
+	config := DefaultConfig()
+	config.Advanced.OutageAlertAfter = 60
+	s := newTestService(config)
+
+	s.markServiceDown()
+	if s.outageReported {
+		t.Fatal("Outage should not be reported before the threshold")
+	}
+
+	s.downSince = time.Now().Add(-2 * time.Minute)
+	s.reconnectAttempts = 5
+	s.markServiceDown()
+	if !s.outageReported {
+		t.Fatal("Expected the outage to be reported after the threshold")
+	}
+
+	s.markServiceUp()
+	if s.reconnectAttempts != 0 || !s.downSince.IsZero() || s.outageReported {
+		t.Errorf("Expected backoff and outage state to be reset after recovery")
+	}
+}
+
+func TestReadCardUIDTagStandard(t *testing.T) {
+	atr14443 := []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x6A}
+	atr15693 := []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x0B, 0x00, 0x14, 0x00, 0x00, 0x00, 0x00, 0x71}
+	uid15693 := []byte{0x4D, 0x2F, 0x6A, 0x15, 0x00, 0x01, 0x04, 0xE0, 0x90, 0x00}
+	uid14443 := []byte{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}
+
+	tests := []struct {
+		standard string
+		atr      []byte
+		response []byte
+		expected string
+		isValid  bool
+		name     string
+	}{
+		{"14443", atr14443, uid14443, "04a22b91", true, "ISO14443 default"},
+		{"15693", atr15693, uid15693, "e0040100156a2f4d", true, "ISO15693 8 byte UID in printed order"},
+		{"auto", atr15693, uid15693, "e0040100156a2f4d", true, "auto detects ISO15693 from ATR"},
+		{"auto", atr14443, uid14443, "04a22b91", true, "auto detects ISO14443 from ATR"},
+		{"auto", nil, uid14443, "04a22b91", true, "auto without contactless ATR uses ISO14443"},
+		{"15693", atr15693, uid14443, "", false, "ISO15693 with wrong UID length"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.TagStandard = test.standard
+			config.Advanced.CardReadAttempts = 1
+			s := newTestService(config)
+
+			uid, err := s.readCardData(&mockCard{atr: test.atr, responses: [][]byte{test.response}})
+			if !test.isValid {
+				if err == nil {
+					t.Fatalf("Expected an error, got UID % x", uid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if result := s.formatOutput(uid, "Reader 0"); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatOutputSwapNibbles(t *testing.T) {
+	tests := []struct {
+		flags    Flags
+		expected string
+		name     string
+	}{
+		{Flags{}, "4a1b2c3d", "no transformation"},
+		{Flags{SwapNibbles: true}, "a4b1c2d3", "swap nibbles only"},
+		{Flags{Reverse: true}, "3d2c1b4a", "reverse only"},
+		{Flags{Reverse: true, SwapNibbles: true}, "d3c2b1a4", "reverse and swap nibbles"},
+		{Flags{SwapNibbles: true, CapsLock: true, InChar: CharFlagColon}, "A4:B1:C2:D3", "swap nibbles with formatting"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &service{flags: test.flags}
+			result := s.formatOutput([]byte{0x4a, 0x1b, 0x2c, 0x3d}, "Reader 0")
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatOutputEndSequence(t *testing.T) {
+	tests := []struct {
+		endSequence []string
+		endChar     string
+		expected    string
+		name        string
+	}{
+		{nil, "enter", "04a22b91\n", "end char without sequence"},
+		{[]string{"enter", "enter"}, "none", "04a22b91\n\n", "double enter"},
+		{[]string{"tab", "enter"}, "none", "04a22b91\t\n", "tab then enter"},
+		{[]string{"tab"}, "enter", "04a22b91\t", "sequence replaces end char"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.EndChar = test.endChar
+			config.NFC.EndSequence = test.endSequence
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			if err := KeyboardWrite(s.formatOutput([]byte{0x04, 0xa2, 0x2b, 0x91}, "Reader 0"), kb, nil); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result := kb.text(); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.NFC.EndSequence = []string{"tab", "ctrl_z"}
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected unknown key in end sequence to be rejected")
+	}
+}
+
+func TestFormatOutputPostOutputClear(t *testing.T) {
+	tests := []struct {
+		endSequence     []string
+		postOutputClear []string
+		expected        string
+		name            string
+	}{
+		{nil, nil, "04a22b91\n", "no clear sequence"},
+		{nil, []string{"select_all", "backspace"}, "04a22b91\n^a\b", "select all and delete after end char"},
+		{[]string{"tab", "enter"}, []string{"backspace", "backspace"}, "04a22b91\t\n\b\b", "clear after end sequence"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.EndChar = "enter"
+			config.NFC.EndSequence = test.endSequence
+			config.NFC.PostOutputClear = test.postOutputClear
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			if err := KeyboardWrite(s.formatOutput([]byte{0x04, 0xa2, 0x2b, 0x91}, "Reader 0"), kb, nil); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result := kb.text(); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+			if kb.shortcut {
+				t.Errorf("Expected the shortcut modifier to be released after typing")
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.NFC.PostOutputClear = []string{"ctrl_z"}
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected unknown key in post output clear to be rejected")
+	}
+}
+
+func TestCheckIdle(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.IdleAlertMinutes = 10
+	s := newTestService(config)
+	now := time.Now()
+
+	s.checkIdle(now.Add(time.Hour))
+	if !s.idleReportedSince.IsZero() {
+		t.Fatal("Expected no idle alert while not scanning")
+	}
+
+	s.markServiceUp()
+	s.checkIdle(now.Add(5 * time.Minute))
+	if !s.idleReportedSince.IsZero() {
+		t.Fatal("Expected no idle alert within the window")
+	}
+
+	s.checkIdle(now.Add(11 * time.Minute))
+	reported := s.idleReportedSince
+	if reported.IsZero() {
+		t.Fatal("Expected an idle alert after the window")
+	}
+
+	s.checkIdle(now.Add(30 * time.Minute))
+	if !s.idleReportedSince.Equal(reported) {
+		t.Error("Expected only one idle alert per idle period")
+	}
+
+	// A scan starts a new idle period
+	s.statusManager.RecordScan()
+	s.checkIdle(now.Add(45 * time.Minute))
+	if s.idleReportedSince.Equal(reported) {
+		t.Error("Expected a new idle alert after a scan and another idle window")
+	}
+}
+
+func TestReadNextCardProtocolFallback(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states: map[string][]scard.StateFlag{
+			"Reader 0": {scard.StatePresent, scard.StateEmpty},
+		},
+		cards: map[scard.Protocol]*mockCard{
+			// ProtocolAny negotiates T=0, which this card doesn't answer on
+			scard.ProtocolAny: {responses: [][]byte{{0x6f, 0x00}}},
+			scard.ProtocolT1:  {responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+		},
+	}
+	config := DefaultConfig()
+	config.Advanced.CardReadAttempts = 1
+	s := newTestService(config)
+
+	kb := &mockKeyboard{}
+	if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result := kb.text(); result != "04a22b91" {
+		t.Errorf("Expected the UID read with T=1 to be typed, got %q", result)
+	}
+
+	expected := []scard.Protocol{scard.ProtocolAny, scard.ProtocolT1}
+	if len(ctx.protocols) != len(expected) {
+		t.Fatalf("Expected protocols %v, got %v", expected, ctx.protocols)
+	}
+	for i, proto := range expected {
+		if ctx.protocols[i] != proto {
+			t.Errorf("Connect %d: expected protocol %s, got %s", i, protocolName(proto), protocolName(ctx.protocols[i]))
+		}
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		readers []string
+		states  map[string][]scard.StateFlag
+		card    *mockCard
+		device  int
+		stage   string
+	}{
+		{
+			name:    "card read and formatted",
+			readers: []string{"Reader 0"},
+			states:  map[string][]scard.StateFlag{"Reader 0": {scard.StateEmpty, scard.StatePresent}},
+			card:    &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+			device:  1,
+		},
+		{
+			name:   "no readers",
+			device: 1,
+			stage:  "Listing readers",
+		},
+		{
+			name:    "configured device missing",
+			readers: []string{"Reader 0"},
+			device:  3,
+			stage:   "Device selection",
+		},
+		{
+			name:    "mute card",
+			readers: []string{"Reader 0"},
+			states:  map[string][]scard.StateFlag{"Reader 0": {scard.StatePresent | scard.StateMute}},
+			device:  1,
+			stage:   "Waiting for a card",
+		},
+		{
+			name:    "card read fails",
+			readers: []string{"Reader 0"},
+			states:  map[string][]scard.StateFlag{"Reader 0": {scard.StatePresent}},
+			card:    &mockCard{responses: [][]byte{{0x63, 0x00}}},
+			device:  1,
+			stage:   "Reading the card",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &mockContext{readers: test.readers, states: test.states, card: test.card}
+			config := DefaultConfig()
+			config.NFC.Device = test.device
+			config.Advanced.CardReadAttempts = 1
+			s := newTestService(config)
+
+			err := s.selfTest(ctx, time.Second)
+			if test.stage == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			failure, ok := err.(*selfTestFailure)
+			if !ok {
+				t.Fatalf("Expected a self-test failure, got %v", err)
+			}
+			if failure.stage != test.stage {
+				t.Errorf("Expected failure in stage %q, got %q (%v)", test.stage, failure.stage, failure.err)
+			}
+			if failure.hint == "" {
+				t.Errorf("Expected a hint for stage %q", failure.stage)
+			}
+		})
+	}
+}
+
+func TestSuccessFeedbackIndependent(t *testing.T) {
+	tests := []struct {
+		showSuccess bool
+		sound       bool
+		name        string
+	}{
+		{true, true, "notification and sound"},
+		{true, false, "notification only"},
+		{false, true, "sound only"},
+		{false, false, "neither"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.sound && !audioSupported {
+				t.Skip("audio is compiled out")
+			}
+
+			var notified []string
+			originalNotify := notifyFunc
+			notifyFunc = func(title, message, appIcon string) error {
+				notified = append(notified, title)
+				return nil
+			}
+			defer func() { notifyFunc = originalNotify }()
+
+			ctx := &mockContext{
+				readers: []string{"Reader 0"},
+				states: map[string][]scard.StateFlag{
+					"Reader 0": {scard.StatePresent, scard.StateEmpty},
+				},
+				card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+			}
+			config := DefaultConfig()
+			config.Notifications.ShowSuccess = test.showSuccess
+			config.Audio.Enabled = test.sound
+			config.Audio.SuccessSound = "beep"
+			config.Advanced.SelfRestart = false
+
+			notificationManager := NewNotificationManager(config)
+			audioManager := NewAudioManager(config)
+			played := make(chan string, 1)
+			audioManager.play = func(sound string) { played <- sound }
+			s := NewService(config.ToFlags(), config, notificationManager, NewRestartManager(config, notificationManager, nil), audioManager, NewStatusManager(), nil).(*service)
+
+			// A previous error makes the success notification due
+			notificationManager.errorCounts["card-error"] = 1
+
+			if err := s.readNextCard(ctx, ctx.readers, &mockKeyboard{}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got := len(notified) == 1; got != test.showSuccess {
+				t.Errorf("Expected success notification: %v, got %v", test.showSuccess, notified)
+			}
+			select {
+			case <-played:
+				if !test.sound {
+					t.Error("Expected no success sound")
+				}
+			case <-time.After(200 * time.Millisecond):
+				if test.sound {
+					t.Error("Expected the success sound")
+				}
+			}
+			if notificationManager.hasRecentErrors() {
+				t.Error("Expected error counts to be cleared after a successful read")
+			}
+		})
+	}
+}
+
+func TestFormatOutputRegex(t *testing.T) {
+	tests := []struct {
+		regex    string
+		replace  string
+		prefix   string
+		inChar   string
+		expected string
+		name     string
+	}{
+		{"^(..)(.*)$", "${2}-${1}", "", "none", "a22b91-04\\n", "capture groups"},
+		{"^04", "", "", "none", "a22b91\\n", "strip manufacturer byte"},
+		{"^ID:([0-9a-f]{4})", "$1/", "ID:", "none", "04a2/2b91\\n", "site prefix included"},
+		{"x", "y", "", "none", "04a22b91\\n", "no match"},
+		{"\\\\n", "", "", "none", "04a22b91\\n", "end keys untouched"},
+		{"91$", "\\n\\a", "", "none", "04a22b\\\\n\\\\a\\n", "backslashes in the replacement typed literally"},
+		{"91$", "\\", "", "none", "04a22b\\\\\\n", "trailing backslash typed literally"},
+		{"\\t", ":", "", "tab", "04:a2:2b:91\\n", "in character matched as a key"},
+		{"a2", "", "", "tab", "04\\t\\t2b\\t91\\n", "in character keys kept"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.EndChar = "enter"
+			config.NFC.InChar = test.inChar
+			config.NFC.OutputRegex = test.regex
+			config.NFC.OutputReplace = test.replace
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+			s.flags.SitePrefix = test.prefix
+
+			if result := s.formatOutput([]byte{0x04, 0xa2, 0x2b, 0x91}, "Reader 0"); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.NFC.OutputRegex = "(unclosed"
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected an invalid output regex to be rejected")
+	}
+	config = DefaultConfig()
+	config.NFC.OutputReplace = "$1"
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected output_replace without output_regex to be rejected")
+	}
+}
+
+func TestFormatOutputLegacyFormat(t *testing.T) {
+	tests := []struct {
+		preset   string
+		expected string
+		name     string
+	}{
+		{"reversed_decimal10", "2435555844\\t", "big-endian decimal of the reversed uid"},
+		{"decimal10", "0077736849\\t", "big-endian decimal padded to 10 digits"},
+		{"reversed_hex", "912BA204\\t", "reversed uppercase hex"},
+		{"mac", "04:A2:2B:91", "mac address style without end keys"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.LegacyFormat = test.preset
+			// Individual settings are replaced by the preset
+			config.NFC.InChar = "hyphen"
+			config.NFC.SwapNibbles = true
+			// Only presets for systems without end keys drop them
+			config.NFC.EndChar = "tab"
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			if result := s.formatOutput([]byte{0x04, 0xa2, 0x2b, 0x91}, "Reader 0"); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
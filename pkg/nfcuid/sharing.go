@@ -0,0 +1,79 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// errReaderShared is returned when another application keeps the reader to itself
+var errReaderShared = errors.New("reader in use by another application")
+
+// sharingRetryDelays are the waits between connection attempts while another application
+// holds the reader, growing to give it time to finish. Replaceable in tests.
+var sharingRetryDelays = []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2 * time.Second}
+
+// sharingEpisode tracks another application holding a reader, from the first refused
+// connection until a connection succeeds or fails for another reason
+type sharingEpisode struct {
+	waits    int  // Waits so far, the backoff goes on from there when the card is read again
+	reported bool // The contention was recorded as an error
+}
+
+// connectShared connects to the card on the reader, waiting with sharingRetryDelays while
+// another application holds the reader. The contention is benign, so unlike other
+// connection errors it isn't counted towards max_context_failures. Each call waits at
+// most len(sharingRetryDelays) times, the delays keep growing over the calls of an episode.
+func (s *service) connectShared(ctx cardContext, reader string, proto scard.Protocol) (cardHandle, error) {
+	for waits := 0; ; waits++ {
+		s.heartbeat()
+		card, err := ctx.Connect(reader, scard.ShareShared, proto)
+		if !errors.Is(err, scard.ErrSharingViolation) {
+			delete(s.sharing, reader)
+			return card, err
+		}
+		if waits == len(sharingRetryDelays) {
+			return nil, fmt.Errorf("%w: %v", errReaderShared, err)
+		}
+
+		episode := s.sharingEpisode(reader)
+		delay := sharingRetryDelays[min(episode.waits, len(sharingRetryDelays)-1)]
+		episode.waits++
+		logDebugf("Reader %s in use by another application, connecting again in %v", reader, delay)
+		select {
+		case <-time.After(delay):
+		case <-s.shutdown.Done():
+			return nil, errShutdownRequested
+		}
+	}
+}
+
+// sharingEpisode returns the contention episode of the reader, starting one if needed
+func (s *service) sharingEpisode(reader string) *sharingEpisode {
+	if s.sharing == nil {
+		s.sharing = make(map[string]*sharingEpisode)
+	}
+	episode := s.sharing[reader]
+	if episode == nil {
+		episode = &sharingEpisode{}
+		s.sharing[reader] = episode
+	}
+	return episode
+}
+
+// handleSharedReader reports a card that couldn't be read because another application
+// holds the reader, once per episode. The card is read again once the reader is free.
+func (s *service) handleSharedReader(reader string, err error) {
+	if episode := s.sharing[reader]; episode != nil {
+		if episode.reported {
+			logDebugf("Card on %s still not read: %v", reader, err)
+			return
+		}
+		episode.reported = true
+	}
+	fmt.Printf("Card not read: %v\n", err)
+	s.notificationManager.NotifyErrorThrottled("reader-shared", "Lesegerät von anderer Anwendung belegt")
+	s.recordError(reader, err.Error())
+}
@@ -0,0 +1,139 @@
+package nfcuid
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// sharedContext is a mock context whose reader is held by another application for the
+// first busy connection attempts
+type sharedContext struct {
+	*mockContext
+	busy int
+}
+
+func (c *sharedContext) Connect(reader string, mode scard.ShareMode, proto scard.Protocol) (cardHandle, error) {
+	if c.busy > 0 {
+		c.busy--
+		c.connects++
+		return nil, scard.ErrSharingViolation
+	}
+	return c.mockContext.Connect(reader, mode, proto)
+}
+
+func TestSharingViolation(t *testing.T) {
+	defer func(delays []time.Duration) { sharingRetryDelays = delays }(sharingRetryDelays)
+	sharingRetryDelays = []time.Duration{0, 0, 0}
+
+	tests := []struct {
+		busy     int
+		expected string
+		connects int
+		name     string
+	}{
+		{2, "04a22b91\n", 3, "freed while waiting"},
+		{10, "", 4, "still in use"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.EndChar = "enter"
+			config.Advanced.MaxContextFailures = 1
+			s := newTestService(config)
+
+			ctx := &sharedContext{
+				mockContext: &mockContext{
+					readers: []string{"Reader 0"},
+					states:  map[string][]scard.StateFlag{"Reader 0": {scard.StatePresent, scard.StateEmpty}},
+					card:    &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+				},
+				busy: test.busy,
+			}
+			kb := &mockKeyboard{}
+			if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if kb.text() != test.expected {
+				t.Errorf("Expected %q typed, got %q", test.expected, kb.text())
+			}
+			// No retries by the retry manager on top of the sharing waits
+			if ctx.connects != test.connects {
+				t.Errorf("Expected %d connection attempts, got %d", test.connects, ctx.connects)
+			}
+			if s.restartManager.contextFailureCount != 0 {
+				t.Errorf("Expected the contention not to count as a PC/SC failure, got %d failures", s.restartManager.contextFailureCount)
+			}
+			if status := s.statusManager.GetStatus(); (status.LastErrorMessage != "") != (test.expected == "") {
+				t.Errorf("Expected an error recorded only while the reader is in use, got %q", status.LastErrorMessage)
+			}
+		})
+	}
+}
+
+func TestSharingEpisode(t *testing.T) {
+	defer func(delays []time.Duration) { sharingRetryDelays = delays }(sharingRetryDelays)
+	sharingRetryDelays = []time.Duration{0, 0, 0}
+
+	s := newTestService(DefaultConfig())
+	recorded := 0
+	s.RegisterErrorHandler(func(reader, message string) { recorded++ })
+
+	ctx := &sharedContext{
+		mockContext: &mockContext{
+			readers: []string{"Reader 0"},
+			states:  map[string][]scard.StateFlag{"Reader 0": {scard.StatePresent, scard.StatePresent, scard.StatePresent, scard.StateEmpty}},
+			card:    &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+		},
+		busy: 8,
+	}
+	kb := &mockKeyboard{}
+	for cycle := 1; cycle <= 2; cycle++ {
+		if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		// The backoff goes on where the last cycle stopped
+		if waits := s.sharing["Reader 0"].waits; waits != 3*cycle {
+			t.Errorf("Expected %d waits after cycle %d, got %d", 3*cycle, cycle, waits)
+		}
+	}
+	if recorded != 1 {
+		t.Errorf("Expected the contention recorded once, got %d errors", recorded)
+	}
+
+	if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if kb.text() != "04a22b91" {
+		t.Errorf("Expected the card typed once the reader is free, got %q", kb.text())
+	}
+	if _, ok := s.sharing["Reader 0"]; ok {
+		t.Error("Expected the episode to end with the successful connection")
+	}
+}
+
+func TestSharingShutdown(t *testing.T) {
+	defer func(delays []time.Duration) { sharingRetryDelays = delays }(sharingRetryDelays)
+	sharingRetryDelays = []time.Duration{time.Hour}
+
+	s := newTestService(DefaultConfig())
+	shutdown, cancel := context.WithCancel(context.Background())
+	s.shutdown = shutdown
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	ctx := &sharedContext{
+		mockContext: &mockContext{
+			readers: []string{"Reader 0"},
+			states:  map[string][]scard.StateFlag{"Reader 0": {scard.StatePresent}},
+		},
+		busy: 10,
+	}
+	if err := s.readNextCard(ctx, ctx.readers, &mockKeyboard{}); !errors.Is(err, errShutdownRequested) {
+		t.Errorf("Expected the wait for the other application to end with the shutdown, got %v", err)
+	}
+}
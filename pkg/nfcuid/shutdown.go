@@ -0,0 +1,97 @@
+package nfcuid
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Shutdown steps run in this order, each depending on the ones after it
+const (
+	ShutdownScanning     = iota // Stop reading and typing cards
+	ShutdownIntegrations        // Browser, hotkeys and servers that react to scans
+	ShutdownLogs                // Flush and close the event log and status file
+	ShutdownLock                // Release the single instance lock, last so no second instance starts early
+)
+
+// shutdownStep is a subsystem stopped by Shutdown
+type shutdownStep struct {
+	name  string
+	order int
+	stop  func() error
+}
+
+// shutdownRegistry collects the shutdown steps and runs them once
+type shutdownRegistry struct {
+	mu    sync.Mutex
+	steps []shutdownStep
+	once  sync.Once
+}
+
+// scanningStopTimeout bounds how long Shutdown waits for the card being read or typed.
+// A shutdown started from the reading loop itself, e.g. a self-restart, waits it out.
+const scanningStopTimeout = 3 * time.Second
+
+// scanning counts the running Start loops, the scanning step waits for them to return
+var scanning sync.WaitGroup
+
+// shutdowns is the registry used by Shutdown. Cancelling the shutdown context ends the
+// card wait and the device prompt, the card being processed is finished first.
+var shutdowns = &shutdownRegistry{
+	steps: []shutdownStep{{"scanning", ShutdownScanning, func() error {
+		cancelShutdown()
+		return waitScanningStopped(scanningStopTimeout)
+	}}},
+}
+
+// waitScanningStopped waits until the running Start loops have returned
+func waitScanningStopped(timeout time.Duration) error {
+	stopped := make(chan struct{})
+	go func() {
+		scanning.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("still reading after %v", timeout)
+	}
+}
+
+// RegisterShutdown adds a subsystem that Shutdown stops at the given order (one of the
+// Shutdown constants). Steps with the same order run in registration order.
+func RegisterShutdown(name string, order int, stop func() error) {
+	shutdowns.register(name, order, stop)
+}
+
+// Shutdown stops all registered subsystems in order, only the first call does anything.
+// A failing step is reported and doesn't keep the later ones from running.
+func Shutdown() {
+	shutdowns.run()
+}
+
+// register adds a step to the registry
+func (r *shutdownRegistry) register(name string, order int, stop func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, shutdownStep{name, order, stop})
+}
+
+// run runs the steps sorted by order, once
+func (r *shutdownRegistry) run() {
+	r.once.Do(func() {
+		r.mu.Lock()
+		steps := append([]shutdownStep(nil), r.steps...)
+		r.mu.Unlock()
+
+		sort.SliceStable(steps, func(i, j int) bool { return steps[i].order < steps[j].order })
+		for _, step := range steps {
+			if err := step.stop(); err != nil {
+				fmt.Printf("Warning: failed to stop %s: %v\n", step.name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package nfcuid
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownRegistry(t *testing.T) {
+	var calls []string
+	stop := func(name string, err error) func() error {
+		return func() error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	registry := &shutdownRegistry{}
+	registry.register("instance lock", ShutdownLock, stop("instance lock", nil))
+	registry.register("event log", ShutdownLogs, stop("event log", errors.New("disk full")))
+	registry.register("scanning", ShutdownScanning, stop("scanning", nil))
+	registry.register("browser", ShutdownIntegrations, stop("browser", nil))
+	registry.register("status file", ShutdownLogs, stop("status file", nil))
+
+	registry.run()
+	registry.run()
+
+	// Dependency order, registration order within a step, a failing step doesn't stop
+	// the later ones and nothing runs twice
+	expected := "scanning,browser,event log,status file,instance lock"
+	if result := strings.Join(calls, ","); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
+func TestWaitScanningStopped(t *testing.T) {
+	scanning.Add(1)
+	if err := waitScanningStopped(10 * time.Millisecond); err == nil {
+		t.Error("Expected an error while a loop is still running")
+	}
+
+	time.AfterFunc(10*time.Millisecond, scanning.Done)
+	if err := waitScanningStopped(time.Second); err != nil {
+		t.Errorf("Expected the loop to be waited for, got %v", err)
+	}
+}
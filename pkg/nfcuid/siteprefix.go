@@ -0,0 +1,82 @@
+package nfcuid
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// prefixSources lists the nfc.prefix_from options: the static nfc.prefix, a part of the
+// hostname or an environment variable, so one config can be shipped to every site
+var prefixSources = []string{"static", "hostname", "env"}
+
+// IsValidPrefixSource reports whether source is one of prefixSources
+func IsValidPrefixSource(source string) bool {
+	for _, option := range prefixSources {
+		if option == source {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSitePrefix computes the site prefix typed before every UID from the nfc.prefix_from
+// source. It runs once at startup, a hostname or environment variable that doesn't yield
+// a prefix is an error so a site never types UIDs without its code.
+//
+// A prefix from the hostname or environment is escaped, so a backslash in it is typed
+// literally, and it must be typable with the keyboard layout like nfc.prefix.
+func ResolveSitePrefix(config *Config) (string, error) {
+	return resolveSitePrefix(config, os.Hostname, os.Getenv)
+}
+
+// resolveSitePrefix is ResolveSitePrefix with replaceable hostname and environment lookups
+func resolveSitePrefix(config *Config, hostname func() (string, error), getenv func(string) string) (string, error) {
+	var prefix string
+	switch config.NFC.PrefixFrom {
+	case "hostname":
+		name, err := hostname()
+		if err != nil {
+			return "", fmt.Errorf("failed to get hostname: %v", err)
+		}
+		if prefix, err = hostnamePrefix(name, config.NFC.PrefixPattern); err != nil {
+			return "", err
+		}
+	case "env":
+		prefix = getenv(config.NFC.PrefixEnv)
+		if prefix == "" {
+			return "", fmt.Errorf("environment variable %s is not set", config.NFC.PrefixEnv)
+		}
+	default:
+		return config.NFC.Prefix, nil
+	}
+
+	prefix = strings.ReplaceAll(prefix, "\\", "\\\\")
+	if err := validateTypableText(config, "site prefix", prefix); err != nil {
+		return "", err
+	}
+	return prefix, nil
+}
+
+// hostnamePrefix derives the prefix from the hostname: the first capture group of the
+// pattern, or the whole match for a pattern without groups. Without a pattern the
+// hostname itself is the prefix.
+func hostnamePrefix(hostname, pattern string) (string, error) {
+	if pattern == "" {
+		return hostname, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid prefix pattern: %v", err)
+	}
+	match := re.FindStringSubmatch(hostname)
+	if match == nil {
+		return "", fmt.Errorf("prefix pattern %q doesn't match hostname %q", pattern, hostname)
+	}
+	if len(match) > 1 {
+		return match[1], nil
+	}
+	return match[0], nil
+}
@@ -0,0 +1,161 @@
+package nfcuid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHostnamePrefix(t *testing.T) {
+	tests := []struct {
+		hostname string
+		pattern  string
+		expected string
+		fails    bool
+		name     string
+	}{
+		{"BER-KASSE-01", "", "BER-KASSE-01", false, "whole hostname"},
+		{"BER-KASSE-01", `^([A-Z]+)-`, "BER", false, "first group"},
+		{"kasse01.muc.example.com", `\.([a-z]+)\.`, "muc", false, "group in domain"},
+		{"HAM-KASSE-02", `^(?:([A-Z]+)-)(KASSE)`, "HAM", false, "first of several groups"},
+		{"FRA-KASSE-03", `^[A-Z]{3}`, "FRA", false, "whole match without group"},
+		{"kasse-04", `^([A-Z]+)-`, "", true, "no match"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := hostnamePrefix(test.hostname, test.pattern)
+			if test.fails {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestResolveSitePrefix(t *testing.T) {
+	hostname := func() (string, error) { return "BER-KASSE-01", nil }
+	getenv := func(name string) string {
+		switch name {
+		case "NFCUID_SITE":
+			return "MUC"
+		case "NFCUID_SHARE":
+			return `\\MUC\`
+		case "NFCUID_MAIL":
+			return "muc@"
+		}
+		return ""
+	}
+
+	tests := []struct {
+		from     string
+		prefix   string
+		env      string
+		pattern  string
+		expected string
+		fails    bool
+		name     string
+	}{
+		{"static", "", "", "", "", false, "no prefix"},
+		{"static", "HAM-", "", "", "HAM-", false, "static"},
+		{"hostname", "", "", `^([A-Z]+)-`, "BER", false, "hostname"},
+		{"env", "", "NFCUID_SITE", "", "MUC", false, "environment"},
+		{"env", "", "NFCUID_OTHER", "", "", true, "environment not set"},
+		{"env", "", "NFCUID_SHARE", "", `\\\\MUC\\`, false, "backslashes escaped"},
+		{"env", "", "NFCUID_MAIL", "", "muc@", false, "at sign on us"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.PrefixFrom = test.from
+			config.NFC.Prefix = test.prefix
+			if test.env != "" {
+				config.NFC.PrefixEnv = test.env
+			}
+			config.NFC.PrefixPattern = test.pattern
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+
+			result, err := resolveSitePrefix(config, hostname, getenv)
+			if test.fails {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.NFC.PrefixFrom = "hostname"
+	failing := func() (string, error) { return "", errors.New("no hostname") }
+	if _, err := resolveSitePrefix(config, failing, getenv); err == nil {
+		t.Errorf("Expected a hostname error to be returned")
+	}
+
+	config = DefaultConfig()
+	config.NFC.PrefixFrom = "env"
+	config.NFC.PrefixEnv = "NFCUID_MAIL"
+	config.NFC.KeyboardLayout = "de"
+	if _, err := resolveSitePrefix(config, hostname, getenv); err == nil {
+		t.Errorf("Expected a prefix the layout can't type to be rejected")
+	}
+}
+
+func TestSitePrefixValidation(t *testing.T) {
+	tests := []struct {
+		from    string
+		env     string
+		pattern string
+		name    string
+	}{
+		{"dns", "NFCUID_SITE", "", "unknown source"},
+		{"env", "", "", "env without variable"},
+		{"static", "NFCUID_SITE", `^([A-Z]+)`, "pattern without hostname"},
+		{"hostname", "NFCUID_SITE", `^([A-Z]+`, "invalid pattern"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.PrefixFrom = test.from
+			config.NFC.PrefixEnv = test.env
+			config.NFC.PrefixPattern = test.pattern
+			if err := validateConfig(config); err == nil {
+				t.Errorf("Expected the configuration to be rejected")
+			}
+		})
+	}
+}
+
+func TestFormatOutputSitePrefix(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.EndChar = "enter"
+	config.SitePrefix = "BER"
+	s := newTestService(config)
+	profileFlags := s.flags
+	profileFlags.Prefix = "-"
+	s.readerFlags = map[string]Flags{"Reader 1": profileFlags}
+
+	if result := s.formatOutput([]byte{0x04, 0xa2, 0x2b, 0x91}, "Reader 0"); result != "BER04a22b91\\n" {
+		t.Errorf("Expected %q, got %q", "BER04a22b91\\n", result)
+	}
+	if result := s.formatOutput([]byte{0x04, 0xa2, 0x2b, 0x91}, "Reader 1"); result != "BER-04a22b91\\n" {
+		t.Errorf("Expected the site prefix before the reader prefix, got %q", result)
+	}
+}
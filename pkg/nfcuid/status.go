@@ -0,0 +1,305 @@
+package nfcuid
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is a snapshot of the service state, answering "when did it last work?"
+// without going through the logs
+type Status struct {
+	StartTime        time.Time         `json:"start_time"`
+	UptimeSeconds    int64             `json:"uptime_seconds"`
+	LastScanTime     *time.Time        `json:"last_scan_time,omitempty"`
+	LastErrorTime    *time.Time        `json:"last_error_time,omitempty"`
+	LastErrorMessage string            `json:"last_error_message,omitempty"`
+	ReaderFirmware   map[string]string `json:"reader_firmware,omitempty"` // Firmware version by reader name
+	Scanning         bool              `json:"scanning"`                  // Waiting for cards right now
+	RecentScans      []ScanRecord      `json:"recent_scans,omitempty"`    // Scan history, newest first
+}
+
+// defaultScanHistorySize is the number of recent scans kept unless configured otherwise
+const defaultScanHistorySize = 50
+
+// ScanRecord is one entry of the recent scan history
+type ScanRecord struct {
+	Time   time.Time `json:"time"`
+	UID    string    `json:"uid,omitempty"`    // Raw UID as hex, masked when configured
+	Output string    `json:"output,omitempty"` // Typed output, left out when UIDs are masked
+	Reader string    `json:"reader"`
+	Result string    `json:"result"` // "success" or "error"
+	Error  string    `json:"error,omitempty"`
+}
+
+// StatusManager tracks the service state from the scan and error sites
+type StatusManager struct {
+	mu               sync.Mutex
+	startTime        time.Time
+	lastScanTime     time.Time
+	lastErrorTime    time.Time
+	lastErrorMessage string
+	scanningSince    time.Time // When the service started waiting for cards, zero while it isn't
+	readerFirmware   map[string]string
+	scans            []ScanRecord // Ring buffer of recent scans
+	nextScan         int          // Index of the oldest scan once the buffer is full
+	scanHistorySize  int
+	maskUIDs         bool
+	statusFile       string        // Path the status is mirrored to as JSON, empty = off
+	statusDirty      chan struct{} // Wakes the status file writer, nil until a file is set
+	statusWriteMu    sync.Mutex    // Held by the status file writer during a write
+}
+
+// NewStatusManager creates a new status manager, using now as the process start time
+func NewStatusManager() *StatusManager {
+	return &StatusManager{
+		startTime:       time.Now(),
+		scanHistorySize: defaultScanHistorySize,
+	}
+}
+
+// RecordScan records a successful scan
+func (sm *StatusManager) RecordScan() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.lastScanTime = time.Now()
+	sm.queueStatusWrite()
+}
+
+// RecordError records an error with its message
+func (sm *StatusManager) RecordError(message string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.lastErrorTime = time.Now()
+	sm.lastErrorMessage = message
+	sm.queueStatusWrite()
+}
+
+// SetReaderFirmware records the firmware version reported by a reader
+func (sm *StatusManager) SetReaderFirmware(reader, firmware string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.readerFirmware == nil {
+		sm.readerFirmware = make(map[string]string)
+	}
+	sm.readerFirmware[reader] = firmware
+	sm.queueStatusWrite()
+}
+
+// SetScanHistory configures the recent scan history, a size of 0 disables it. Already
+// recorded scans are dropped.
+func (sm *StatusManager) SetScanHistory(size int, maskUIDs bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.scanHistorySize = size
+	sm.maskUIDs = maskUIDs
+	sm.scans = nil
+	sm.nextScan = 0
+}
+
+// RecordScanResult adds a scan to the recent scan history, replacing the oldest one
+// once the history is full
+func (sm *StatusManager) RecordScanResult(record ScanRecord) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.scanHistorySize <= 0 {
+		return
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if sm.maskUIDs {
+		record.UID = maskUID(record.UID)
+		record.Output = ""
+	}
+
+	defer sm.queueStatusWrite()
+	if len(sm.scans) < sm.scanHistorySize {
+		sm.scans = append(sm.scans, record)
+		return
+	}
+	sm.scans[sm.nextScan] = record
+	sm.nextScan = (sm.nextScan + 1) % sm.scanHistorySize
+}
+
+// RecentScans returns the recent scan history, newest first
+func (sm *StatusManager) RecentScans() []ScanRecord {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.recentScans()
+}
+
+// recentScans returns the scan history newest first, nil when it is empty. Must be
+// called with sm.mu held.
+func (sm *StatusManager) recentScans() []ScanRecord {
+	if len(sm.scans) == 0 {
+		return nil
+	}
+	scans := make([]ScanRecord, 0, len(sm.scans))
+	for i := len(sm.scans) - 1; i >= 0; i-- {
+		scans = append(scans, sm.scans[(sm.nextScan+i)%len(sm.scans)])
+	}
+	return scans
+}
+
+// maskUID hides all but the last 4 hex digits of a UID, enough to confirm a known badge
+func maskUID(uid string) string {
+	if len(uid) <= 4 {
+		return uid
+	}
+	return strings.Repeat("*", len(uid)-4) + uid[len(uid)-4:]
+}
+
+// SetScanning records whether the service is currently waiting for cards
+func (sm *StatusManager) SetScanning(scanning bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if !scanning {
+		sm.scanningSince = time.Time{}
+	} else if sm.scanningSince.IsZero() {
+		sm.scanningSince = time.Now()
+	}
+	sm.queueStatusWrite()
+}
+
+// IdleSince returns the time of the last card activity (last scan, or the start of
+// scanning if there was none since) and whether the service is scanning at all
+func (sm *StatusManager) IdleSince() (time.Time, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.scanningSince.IsZero() {
+		return time.Time{}, false
+	}
+	if sm.lastScanTime.After(sm.scanningSince) {
+		return sm.lastScanTime, true
+	}
+	return sm.scanningSince, true
+}
+
+// GetStatus returns the current status, computing the uptime on read
+func (sm *StatusManager) GetStatus() Status {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	status := Status{
+		StartTime:        sm.startTime,
+		UptimeSeconds:    int64(time.Since(sm.startTime).Seconds()),
+		LastErrorMessage: sm.lastErrorMessage,
+		Scanning:         !sm.scanningSince.IsZero(),
+		RecentScans:      sm.recentScans(),
+	}
+	if !sm.lastScanTime.IsZero() {
+		lastScan := sm.lastScanTime
+		status.LastScanTime = &lastScan
+	}
+	if !sm.lastErrorTime.IsZero() {
+		lastError := sm.lastErrorTime
+		status.LastErrorTime = &lastError
+	}
+	if len(sm.readerFirmware) > 0 {
+		status.ReaderFirmware = make(map[string]string, len(sm.readerFirmware))
+		for reader, firmware := range sm.readerFirmware {
+			status.ReaderFirmware[reader] = firmware
+		}
+	}
+	return status
+}
+
+// SetStatusFile mirrors the status as JSON to path on every change, for monitoring that
+// reads a file. Writes happen in the background and are best-effort, an empty path
+// stops them once a write in progress is done.
+func (sm *StatusManager) SetStatusFile(path string) {
+	sm.mu.Lock()
+	sm.statusFile = path
+	if path != "" && sm.statusDirty == nil {
+		sm.statusDirty = make(chan struct{}, 1)
+		go sm.statusFileWriter(sm.statusDirty)
+	}
+	sm.queueStatusWrite()
+	sm.mu.Unlock()
+
+	// Wait for a write to the previous path to finish
+	sm.statusWriteMu.Lock()
+	sm.statusWriteMu.Unlock()
+}
+
+// queueStatusWrite wakes the status file writer without blocking, changes made while a
+// write is pending are picked up by it. Must be called with sm.mu held.
+func (sm *StatusManager) queueStatusWrite() {
+	if sm.statusFile == "" || sm.statusDirty == nil {
+		return
+	}
+	select {
+	case sm.statusDirty <- struct{}{}:
+	default:
+	}
+}
+
+// statusFileWriter writes the latest status each time it is woken
+func (sm *StatusManager) statusFileWriter(dirty <-chan struct{}) {
+	for range dirty {
+		sm.statusWriteMu.Lock()
+		sm.mu.Lock()
+		path := sm.statusFile
+		sm.mu.Unlock()
+		if path != "" {
+			if err := writeStatusFile(path, sm.GetStatus()); err != nil {
+				log.Printf("Failed to write status file: %v", err)
+			}
+		}
+		sm.statusWriteMu.Unlock()
+	}
+}
+
+// writeStatusFile writes the status to a temporary file next to path and renames it,
+// so readers never see a partly written file
+func writeStatusFile(path string, status Status) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %v", err)
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op after the rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// Summary formats the status for the console
+func (status Status) Summary() string {
+	summary := fmt.Sprintf("Uptime: %v (started %s)", time.Duration(status.UptimeSeconds)*time.Second, status.StartTime.Format(time.RFC3339))
+
+	if status.LastScanTime != nil {
+		summary += fmt.Sprintf(", last successful scan: %s", status.LastScanTime.Format(time.RFC3339))
+	} else {
+		summary += ", no successful scan yet"
+	}
+
+	if status.LastErrorTime != nil {
+		summary += fmt.Sprintf(", last error: %s (%s)", status.LastErrorTime.Format(time.RFC3339), status.LastErrorMessage)
+	}
+
+	return summary
+}
@@ -0,0 +1,209 @@
+package nfcuid
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+func TestStatusManagerGetStatus(t *testing.T) {
+	sm := NewStatusManager()
+	sm.startTime = time.Now().Add(-90 * time.Second)
+
+	status := sm.GetStatus()
+	if status.UptimeSeconds < 90 {
+		t.Errorf("Expected uptime of at least 90s, got %d", status.UptimeSeconds)
+	}
+	if status.LastScanTime != nil || status.LastErrorTime != nil {
+		t.Errorf("Expected no scan or error before any were recorded")
+	}
+
+	sm.RecordError("reader unplugged")
+	sm.RecordScan()
+
+	status = sm.GetStatus()
+	if status.LastScanTime == nil {
+		t.Fatal("Expected the last scan time to be set")
+	}
+	if status.LastErrorTime == nil || status.LastErrorMessage != "reader unplugged" {
+		t.Fatalf("Expected the last error to be recorded, got %+v", status)
+	}
+	if status.LastScanTime.Before(*status.LastErrorTime) {
+		t.Errorf("Expected the scan to be recorded after the error")
+	}
+}
+
+func TestReadNextCardRecordsStatus(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states: map[string][]scard.StateFlag{
+			"Reader 0": {scard.StatePresent, scard.StateEmpty, scard.StatePresent, scard.StateEmpty},
+		},
+		card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}, {0x6a, 0x81}}},
+	}
+	config := DefaultConfig()
+	config.Advanced.CardReadAttempts = 1
+	s := newTestService(config)
+
+	if err := s.readNextCard(ctx, ctx.readers, &mockKeyboard{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status := s.statusManager.GetStatus(); status.LastScanTime == nil || status.LastErrorTime != nil {
+		t.Fatalf("Expected only a successful scan to be recorded, got %+v", status)
+	}
+
+	if err := s.readNextCard(ctx, ctx.readers, &mockKeyboard{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status := s.statusManager.GetStatus(); status.LastErrorTime == nil {
+		t.Errorf("Expected the failed read to be recorded as error")
+	}
+}
+
+func TestQueryReaderInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		card     *mockCard
+		expected string
+	}{
+		{"ACR122U", &mockCard{escape: []byte("ACR122U207")}, "ACR122U207"},
+		{"status word appended", &mockCard{escape: append([]byte("ACR122U215"), 0x90, 0x00)}, "ACR122U215"},
+		{"escape not supported", &mockCard{}, ""},
+		{"direct mode not supported", nil, ""},
+		{"binary response", &mockCard{escape: []byte{0x6a, 0x81}}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &mockContext{readers: []string{"ACS ACR122U"}, card: test.card}
+			s := newTestService(DefaultConfig())
+
+			s.queryReaderInfo(ctx, ctx.readers)
+			s.queryReaderInfo(ctx, ctx.readers)
+
+			if ctx.connects != 1 {
+				t.Errorf("Expected the reader to be queried once, got %d connects", ctx.connects)
+			}
+			if ctx.protocols[0] != scard.ProtocolUndefined {
+				t.Errorf("Expected a direct connection without protocol, got %v", ctx.protocols[0])
+			}
+			if firmware := s.statusManager.GetStatus().ReaderFirmware["ACS ACR122U"]; firmware != test.expected {
+				t.Errorf("Expected firmware %q, got %q", test.expected, firmware)
+			}
+		})
+	}
+}
+
+func TestStatusManagerScanHistory(t *testing.T) {
+	sm := NewStatusManager()
+	sm.SetScanHistory(3, false)
+
+	for _, uid := range []string{"01", "02", "03", "04", "05"} {
+		sm.RecordScanResult(ScanRecord{UID: uid, Reader: "Reader 0", Result: "success"})
+	}
+
+	scans := sm.RecentScans()
+	if len(scans) != 3 {
+		t.Fatalf("Expected the history to cap at 3 scans, got %d", len(scans))
+	}
+	for i, expected := range []string{"05", "04", "03"} {
+		if scans[i].UID != expected {
+			t.Errorf("Expected scan %d to be %s (newest first), got %s", i, expected, scans[i].UID)
+		}
+	}
+	// Exposed in the status file and the stdio status reply
+	if status := sm.GetStatus(); len(status.RecentScans) != 3 || status.RecentScans[0].UID != "05" {
+		t.Errorf("Expected the history in the status, got %v", status.RecentScans)
+	}
+
+	sm.SetScanHistory(0, false)
+	sm.RecordScanResult(ScanRecord{UID: "06"})
+	if scans := sm.RecentScans(); len(scans) != 0 {
+		t.Errorf("Expected no history when disabled, got %v", scans)
+	}
+	if status := sm.GetStatus(); status.RecentScans != nil {
+		t.Errorf("Expected no history in the status when disabled, got %v", status.RecentScans)
+	}
+}
+
+func TestStatusManagerScanHistoryMasked(t *testing.T) {
+	sm := NewStatusManager()
+	sm.SetScanHistory(10, true)
+	sm.RecordScanResult(ScanRecord{UID: "04a22b91", Output: "04A22B91", Reader: "Reader 0", Result: "success"})
+
+	scan := sm.RecentScans()[0]
+	if scan.UID != "****2b91" || scan.Output != "" {
+		t.Errorf("Expected the UID masked and the output left out, got %+v", scan)
+	}
+}
+
+func TestReadNextCardRecordsScanHistory(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states: map[string][]scard.StateFlag{
+			"Reader 0": {scard.StatePresent, scard.StateEmpty, scard.StatePresent | scard.StateMute, scard.StateEmpty},
+		},
+		card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+	}
+	s := newTestService(DefaultConfig())
+
+	for i := 0; i < 2; i++ {
+		if err := s.readNextCard(ctx, ctx.readers, &mockKeyboard{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	scans := s.statusManager.RecentScans()
+	if len(scans) != 2 {
+		t.Fatalf("Expected 2 scans in the history, got %d", len(scans))
+	}
+	if scans[0].Result != "error" || scans[0].Reader != "Reader 0" {
+		t.Errorf("Expected the mute card as newest failed scan, got %+v", scans[0])
+	}
+	if scans[1].Result != "success" || scans[1].UID != "04a22b91" || scans[1].Output != "04a22b91" {
+		t.Errorf("Expected the successful scan with UID and output, got %+v", scans[1])
+	}
+}
+
+func TestStatusFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	sm := NewStatusManager()
+	sm.SetStatusFile(path)
+	defer sm.SetStatusFile("") // Before the temporary directory is removed
+
+	sm.SetScanning(true)
+	sm.RecordError("reader unplugged")
+	sm.RecordScan()
+
+	// Writes happen in the background, wait for the latest status
+	var status Status
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if err := json.Unmarshal(data, &status); err != nil {
+				t.Fatalf("Expected valid JSON in the status file, got %q: %v", data, err)
+			}
+			if status.LastScanTime != nil {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the status file to show the scan, got %+v (%v)", status, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status.LastErrorMessage != "reader unplugged" || !status.Scanning {
+		t.Errorf("Expected the status file to reflect the latest status, got %+v", status)
+	}
+
+	matches, _ := filepath.Glob(path + ".*.tmp")
+	if len(matches) > 0 {
+		t.Errorf("Expected no temporary files left, got %v", matches)
+	}
+}
@@ -0,0 +1,324 @@
+package nfcuid
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// stdioCommands lists the commands accepted on stdin with -stdio
+var stdioCommands = []string{"pause", "resume", "repeat", "trigger", "change_device", "status", "shutdown"}
+
+// errNothingToRepeat is returned by Repeat before the first card was typed
+var errNothingToRepeat = errors.New("no card typed yet")
+
+// StdioCommand is a line sent to the service on stdin with -stdio
+type StdioCommand struct {
+	ID     string `json:"id,omitempty"`     // Echoed in the response, to match it to the command
+	Cmd    string `json:"cmd"`              // One of stdioCommands
+	Device string `json:"device,omitempty"` // Device number or reader name for change_device
+}
+
+// stdioResponse answers a command
+type stdioResponse struct {
+	Type  string `json:"type"` // Always "response"
+	ID    string `json:"id,omitempty"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// stdioScan is the event for a typed card
+type stdioScan struct {
+	Type   string    `json:"type"` // Always "scan"
+	Time   time.Time `json:"time"`
+	UIDs   []string  `json:"uids"`   // Raw UIDs as hex, several with read_all
+	Reader string    `json:"reader"` // Name of the reader that was tapped
+	Output string    `json:"output"` // Output as typed, with Enter and Tab as line breaks and tabs
+}
+
+// stdioError is the event for an error recorded by the service
+type stdioError struct {
+	Type    string    `json:"type"` // Always "error"
+	Time    time.Time `json:"time"`
+	Reader  string    `json:"reader,omitempty"` // Set if the error belongs to a reader
+	Message string    `json:"message"`
+}
+
+// stdioStatus is the event answering the status command, also sent when the state changes
+type stdioStatus struct {
+	Type    string   `json:"type"`         // Always "status"
+	ID      string   `json:"id,omitempty"` // Set when it answers a status command
+	Paused  bool     `json:"paused"`
+	Readers []string `json:"readers"` // Readers watched for cards
+	Status
+}
+
+// StdioServer is the -stdio control protocol for a supervisor running nfcuid as a child
+// process: commands arrive as JSON lines on stdin, responses and events leave as JSON
+// lines on stdout
+type StdioServer struct {
+	service Service
+	status  *StatusManager
+	mu      sync.Mutex // Keeps lines from different goroutines apart
+	out     io.Writer
+	exit    func() // Shuts the process down, replaceable in tests
+}
+
+// NewStdioServer creates the protocol server writing to out and subscribes it to the
+// scan and error events of the service
+func NewStdioServer(service Service, statusManager *StatusManager, out io.Writer) *StdioServer {
+	ss := &StdioServer{
+		service: service,
+		status:  statusManager,
+		out:     out,
+		exit:    func() { SafeExit(0, "Shutdown requested over stdio", nil) },
+	}
+	service.RegisterScanHandler(ss.handleScan)
+	service.RegisterErrorHandler(ss.handleError)
+	service.RegisterStateHandler(func() { ss.sendStatus("") })
+	return ss
+}
+
+// Serve handles the commands read from in until it is closed, then shuts down: a
+// managed child outliving its supervisor would keep typing unattended
+func (ss *StdioServer) Serve(in io.Reader) {
+	ss.sendStatus("")
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		ss.handleLine(scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil {
+		logWarnf("Reading stdio commands failed: %v", err)
+	}
+	fmt.Println("Stdin closed, shutting down")
+	ss.exit()
+}
+
+// handleLine runs one command line and writes the response
+func (ss *StdioServer) handleLine(line []byte) {
+	var command StdioCommand
+	if err := json.Unmarshal(line, &command); err != nil {
+		ss.send(stdioResponse{Type: "response", Error: fmt.Sprintf("invalid command: %v", err)})
+		return
+	}
+
+	err := ss.run(command)
+	response := stdioResponse{Type: "response", ID: command.ID, OK: err == nil}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	ss.send(response)
+
+	switch {
+	case err != nil:
+	case command.Cmd == "status":
+		ss.sendStatus(command.ID)
+	case command.Cmd == "shutdown":
+		ss.exit()
+	}
+}
+
+// run executes a command, the response is written by the caller
+func (ss *StdioServer) run(command StdioCommand) error {
+	switch command.Cmd {
+	case "pause":
+		ss.service.SetPaused(true)
+	case "resume":
+		ss.service.SetPaused(false)
+	case "repeat":
+		return ss.service.Repeat()
+	case "trigger":
+		return ss.service.Trigger()
+	case "change_device":
+		if command.Device == "" {
+			return fmt.Errorf("change_device needs a device")
+		}
+		ss.service.ChangeDevice(command.Device)
+	case "status", "shutdown":
+		// Answered after the response
+	default:
+		return fmt.Errorf("unknown command %q (commands: %v)", command.Cmd, stdioCommands)
+	}
+	return nil
+}
+
+// handleScan is the scan handler writing scan events
+func (ss *StdioServer) handleScan(event ScanEvent) {
+	ss.send(stdioScan{Type: "scan", Time: event.Time, UIDs: event.UIDs, Reader: event.Reader, Output: pipeLine(event.Output)})
+}
+
+// handleError is the error handler writing error events
+func (ss *StdioServer) handleError(reader, message string) {
+	ss.send(stdioError{Type: "error", Time: time.Now(), Reader: reader, Message: message})
+}
+
+// sendStatus writes a status event, id is set when it answers a status command
+func (ss *StdioServer) sendStatus(id string) {
+	ss.send(stdioStatus{
+		Type:    "status",
+		ID:      id,
+		Paused:  ss.service.Paused(),
+		Readers: ss.service.ActiveReaders(),
+		Status:  ss.status.GetStatus(),
+	})
+}
+
+// send writes the message as one JSON line
+func (ss *StdioServer) send(message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		logWarnf("Failed to encode stdio message: %v", err)
+		return
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if _, err := fmt.Fprintf(ss.out, "%s\n", data); err != nil {
+		logWarnf("Failed to write stdio message: %v", err)
+	}
+}
+
+// SetPaused pauses or resumes reading. Cards presented while paused are ignored until
+// they are removed and presented again.
+func (s *service) SetPaused(paused bool) {
+	if s.paused.Swap(paused) == paused {
+		return
+	}
+	if paused {
+		fmt.Println("Reading paused")
+	} else {
+		fmt.Println("Reading resumed")
+	}
+	s.notifyStateHandlers()
+}
+
+// Paused reports whether reading is paused
+func (s *service) Paused() bool {
+	return s.paused.Load()
+}
+
+// Repeat types the output of the last typed card again
+func (s *service) Repeat() error {
+	s.typingMu.Lock()
+	output, kb := s.lastOutput, s.lastKeyboard
+	s.typingMu.Unlock()
+	if kb == nil {
+		return errNothingToRepeat
+	}
+
+	fmt.Println("Typing the last output again")
+	if _, err := s.writeOutput(output, kb); err != nil {
+		return fmt.Errorf("failed to write keyboard output: %v", err)
+	}
+	return nil
+}
+
+// rememberOutput keeps the typed output for Repeat
+func (s *service) rememberOutput(output string, kb keyboard) {
+	s.typingMu.Lock()
+	defer s.typingMu.Unlock()
+	s.lastOutput, s.lastKeyboard = output, kb
+}
+
+// deviceChangeRetry is how often ChangeDevice cancels the card wait until the loop took the change
+const deviceChangeRetry = 100 * time.Millisecond
+
+// ChangeDevice switches the watched reader to device, a device number or (part of) a
+// reader name. The switch happens at the next card wait, a pending wait is cancelled
+// for it. A device that doesn't resolve is reported and the current reader kept.
+func (s *service) ChangeDevice(device string) {
+	s.deviceMu.Lock()
+	s.pendingDevice = device
+	s.deviceMu.Unlock()
+
+	// A cancel landing just before the wait starts is lost, so it is repeated until the
+	// loop took the change. The loop checks for a change after announcing its wait, so
+	// one of both sees the other.
+	go func() {
+		for s.deviceChangePending() && s.waitingForCard.Load() {
+			s.watchdogMu.Lock()
+			if s.cancelWait != nil {
+				s.cancelWait()
+			}
+			s.watchdogMu.Unlock()
+			time.Sleep(deviceChangeRetry)
+		}
+	}()
+}
+
+// deviceChangePending reports whether ChangeDevice was called since the last reselection
+func (s *service) deviceChangePending() bool {
+	s.deviceMu.Lock()
+	defer s.deviceMu.Unlock()
+	return s.pendingDevice != ""
+}
+
+// takePendingDevice returns the device requested by ChangeDevice and clears it
+func (s *service) takePendingDevice() string {
+	s.deviceMu.Lock()
+	defer s.deviceMu.Unlock()
+	device := s.pendingDevice
+	s.pendingDevice = ""
+	return device
+}
+
+// ActiveReaders returns the readers watched for cards, empty while not reading
+func (s *service) ActiveReaders() []string {
+	s.deviceMu.Lock()
+	defer s.deviceMu.Unlock()
+	return append([]string{}, s.activeReaders...)
+}
+
+// setActiveReaders records the readers watched for cards and reports the change
+func (s *service) setActiveReaders(readers []string) {
+	s.deviceMu.Lock()
+	s.activeReaders = append([]string(nil), readers...)
+	s.deviceMu.Unlock()
+	s.notifyStateHandlers()
+}
+
+// RegisterErrorHandler adds a handler called with every error the service records,
+// reader is empty if the error isn't tied to a reader
+func (s *service) RegisterErrorHandler(handler func(reader, message string)) {
+	s.scanHandlersMu.Lock()
+	defer s.scanHandlersMu.Unlock()
+	s.errorHandlers = append(s.errorHandlers, handler)
+}
+
+// RegisterStateHandler adds a handler called when reading is paused or resumed or the
+// watched readers change
+func (s *service) RegisterStateHandler(handler func()) {
+	s.scanHandlersMu.Lock()
+	defer s.scanHandlersMu.Unlock()
+	s.stateHandlers = append(s.stateHandlers, handler)
+}
+
+// notifyErrorHandlers passes a recorded error to the error handlers
+func (s *service) notifyErrorHandlers(reader, message string) {
+	s.scanHandlersMu.Lock()
+	handlers := append([]func(string, string){}, s.errorHandlers...)
+	s.scanHandlersMu.Unlock()
+
+	for _, handler := range handlers {
+		handler(reader, message)
+	}
+}
+
+// notifyStateHandlers calls the state handlers
+func (s *service) notifyStateHandlers() {
+	s.scanHandlersMu.Lock()
+	handlers := append([]func(){}, s.stateHandlers...)
+	s.scanHandlersMu.Unlock()
+
+	for _, handler := range handlers {
+		handler()
+	}
+}
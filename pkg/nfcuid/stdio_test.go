@@ -0,0 +1,294 @@
+package nfcuid
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// stdioTestServer returns a protocol server for a test service, writing to the buffer
+// and counting shutdowns instead of exiting
+func stdioTestServer(s *service, out *bytes.Buffer, exits *int) *StdioServer {
+	ss := NewStdioServer(s, s.statusManager, out)
+	ss.exit = func() { *exits++ }
+	return ss
+}
+
+// stdioLines decodes the JSON lines written by the server, scan events are written
+// from their own goroutine
+func stdioLines(t *testing.T, ss *StdioServer, out *bytes.Buffer) []map[string]interface{} {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var message map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("Expected a JSON line, got %q: %v", line, err)
+		}
+		lines = append(lines, message)
+	}
+	return lines
+}
+
+func TestStdioCommands(t *testing.T) {
+	s := newTestService(DefaultConfig())
+	var out bytes.Buffer
+	exits := 0
+	ss := stdioTestServer(s, &out, &exits)
+
+	ss.Serve(strings.NewReader(`{"id":"1","cmd":"pause"}
+{"id":"2","cmd":"status"}
+{"id":"3","cmd":"launch"}
+not json
+
+{"id":"4","cmd":"resume"}
+{"id":"5","cmd":"shutdown"}
+`))
+
+	expected := []struct {
+		kind   string
+		id     string
+		ok     bool
+		paused bool
+	}{
+		{"status", "", false, false}, // On start
+		{"status", "", false, true},  // Paused
+		{"response", "1", true, false},
+		{"response", "2", true, false},
+		{"status", "2", false, true},
+		{"response", "3", false, false},
+		{"response", "", false, false}, // Invalid JSON
+		{"status", "", false, false},   // Resumed
+		{"response", "4", true, false},
+		{"response", "5", true, false},
+	}
+	lines := stdioLines(t, ss, &out)
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d: %s", len(expected), len(lines), out.String())
+	}
+	for i, want := range expected {
+		line := lines[i]
+		id, _ := line["id"].(string)
+		if line["type"] != want.kind || id != want.id {
+			t.Errorf("Line %d: expected %s %q, got %v", i+1, want.kind, want.id, line)
+			continue
+		}
+		if want.kind == "response" && line["ok"] != want.ok {
+			t.Errorf("Line %d: expected ok %v, got %v", i+1, want.ok, line)
+		}
+		if want.kind == "status" && line["paused"] != want.paused {
+			t.Errorf("Line %d: expected paused %v, got %v", i+1, want.paused, line)
+		}
+	}
+
+	// Once for the shutdown command, once for the closed stdin
+	if exits != 2 {
+		t.Errorf("Expected 2 shutdowns, got %d", exits)
+	}
+}
+
+func TestStdioRepeatAndErrors(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.EndChar = "enter"
+	s := newTestService(config)
+	var out bytes.Buffer
+	exits := 0
+	ss := stdioTestServer(s, &out, &exits)
+
+	ss.handleLine([]byte(`{"id":"1","cmd":"repeat"}`))
+	kb := &mockKeyboard{}
+	scanCards(s, kb, 1)
+	ss.handleLine([]byte(`{"id":"2","cmd":"repeat"}`))
+	if expected := "04a22b91\n04a22b91\n"; kb.text() != expected {
+		t.Errorf("Expected the card typed again, got %q", kb.text())
+	}
+
+	s.recordError("Reader 0", "card is mute")
+
+	var responses, errorEvents []map[string]interface{}
+	for _, line := range stdioLines(t, ss, &out) {
+		switch line["type"] {
+		case "response":
+			responses = append(responses, line)
+		case "error":
+			errorEvents = append(errorEvents, line)
+		}
+	}
+	if len(responses) != 2 || responses[0]["ok"] != false || responses[0]["error"] != errNothingToRepeat.Error() || responses[1]["ok"] != true {
+		t.Errorf("Expected repeat to fail before the first card and succeed after, got %v", responses)
+	}
+	if len(errorEvents) != 1 || errorEvents[0]["reader"] != "Reader 0" || errorEvents[0]["message"] != "card is mute" {
+		t.Errorf("Expected one error event, got %v", errorEvents)
+	}
+}
+
+func TestReleaseKeysWaitForRepeat(t *testing.T) {
+	s := newTestService(DefaultConfig())
+	kb := &mockKeyboard{}
+
+	// A Repeat in progress holds the typing lock, the release keys wait for it
+	s.typingMu.Lock()
+	done := make(chan error)
+	go func() { done <- s.writeKeys("\\e", kb) }()
+	select {
+	case <-done:
+		t.Fatalf("Expected the release keys to wait while the output is typed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.typingMu.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(kb.typed) == 0 {
+		t.Errorf("Expected the release keys typed once the lock was released")
+	}
+}
+
+func TestStdioTrigger(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.Trigger = "manual"
+	s := newTestService(config)
+	var out bytes.Buffer
+	exits := 0
+	ss := stdioTestServer(s, &out, &exits)
+
+	ss.handleLine([]byte(`{"id":"1","cmd":"trigger"}`))
+	if len(s.triggers) != 1 {
+		t.Errorf("Expected a pending trigger")
+	}
+
+	s.config.NFC.Trigger = "auto"
+	ss.handleLine([]byte(`{"id":"2","cmd":"trigger"}`))
+	lines := stdioLines(t, ss, &out)
+	if len(lines) != 2 || lines[0]["ok"] != true || lines[1]["ok"] != false || lines[1]["error"] != errTriggerAuto.Error() {
+		t.Errorf("Expected the trigger accepted only with trigger manual, got %v", lines)
+	}
+}
+
+func TestPausedCardIgnored(t *testing.T) {
+	config := DefaultConfig()
+	config.Advanced.AutoReconnect = false
+	s := newTestService(config)
+	s.SetPaused(true)
+
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states:  map[string][]scard.StateFlag{"Reader 0": {scard.StatePresent}},
+		card:    &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+	}
+	kb := &mockKeyboard{}
+	if err := s.readNextCard(ctx, ctx.readers, kb); !errors.Is(err, errMockExhausted) {
+		t.Fatalf("Expected the wait to go on past the ignored card, got %v", err)
+	}
+	if kb.text() != "" || ctx.connects != 0 {
+		t.Errorf("Expected the card to be ignored while paused, typed %q with %d connects", kb.text(), ctx.connects)
+	}
+}
+
+func TestChangeDevice(t *testing.T) {
+	tests := []struct {
+		device   string
+		expected string
+		name     string
+	}{
+		{"exit", "Exit", "by name"},
+		{"3", "Entrance", "unknown number keeps the reader"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.Devices = []string{"Entrance"}
+			config.NFC.EndChar = "enter"
+			config.Advanced.AutoReconnect = false
+			s := newTestService(config)
+
+			ctx := &mockContext{
+				readers: []string{"Entrance", "Exit"},
+				states: map[string][]scard.StateFlag{
+					"Entrance": {scard.StatePresent, scard.StateEmpty},
+					"Exit":     {scard.StatePresent, scard.StateEmpty},
+				},
+				card: &mockCard{responses: [][]byte{{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}},
+			}
+			selectedReaders, err := s.selectReaders(ctx.readers)
+			if err != nil {
+				t.Fatalf("Unexpected error selecting readers: %v", err)
+			}
+
+			s.ChangeDevice(test.device)
+			kb := &mockKeyboard{}
+			if err := s.cardReadingLoop(ctx, selectedReaders, kb); !errors.Is(err, errMockExhausted) {
+				t.Fatalf("Expected the loop to end with the exhausted mock, got %v", err)
+			}
+			if kb.text() != "04a22b91\n" {
+				t.Errorf("Expected one card typed, got %q", kb.text())
+			}
+			if readers := s.ActiveReaders(); len(readers) != 1 || readers[0] != test.expected {
+				t.Errorf("Expected to watch %s, got %v", test.expected, readers)
+			}
+		})
+	}
+}
+
+// lossyCancelContext is a reader whose status wait ignores the first cancel, like a
+// cancel arriving just before the wait started
+type lossyCancelContext struct {
+	*mockContext
+	cancels   atomic.Int32
+	cancelled chan struct{}
+}
+
+func (c *lossyCancelContext) GetStatusChange(readerStates []scard.ReaderState, timeout time.Duration) error {
+	<-c.cancelled
+	return scard.ErrCancelled
+}
+
+// cancel drops the first cancel and ends the wait on the second
+func (c *lossyCancelContext) cancel() error {
+	if c.cancels.Add(1) == 2 {
+		close(c.cancelled)
+	}
+	return nil
+}
+
+func TestChangeDeviceLostCancel(t *testing.T) {
+	ctx := &lossyCancelContext{
+		mockContext: &mockContext{readers: []string{"Entrance", "Exit"}},
+		cancelled:   make(chan struct{}),
+	}
+	s := newTestService(DefaultConfig())
+	s.setCancelWait(ctx.cancel)
+
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := s.waitUntilCardPresent(ctx, []string{"Entrance"})
+		result <- err
+	}()
+	for !s.waitingForCard.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.ChangeDevice("exit")
+	select {
+	case err := <-result:
+		if !errors.Is(err, errReadersChanged) {
+			t.Errorf("Expected errReadersChanged, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the repeated cancel to end the wait")
+	}
+
+	// A change requested before the wait starts ends it right away
+	if _, _, err := s.waitUntilCardPresent(ctx, []string{"Entrance"}); !errors.Is(err, errReadersChanged) {
+		t.Errorf("Expected errReadersChanged before waiting, got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package nfcuid
+
+import (
+	"math/rand"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/micmonay/keybd_event"
+)
+
+type keySet struct {
+	code  int
+	shift bool
+}
+
+// keyboard is the part of keybd_event.KeyBonding used to type output,
+// abstracted so typed key sequences can be verified in tests
+type keyboard interface {
+	SetKeys(keys ...int)
+	HasSHIFT(bool)
+	HasCTRL(bool)
+	HasSuper(bool)
+	Launching() error
+}
+
+// keyboard must stay satisfied by the real key bonding
+var _ keyboard = &keybd_event.KeyBonding{}
+
+// unicodeTyper is implemented by keyboards that can type a character by its codepoint,
+// independent of the active keyboard layout. KeyboardWrite uses it for printable
+// characters, keys like Enter and Tab are still sent as key codes.
+type unicodeTyper interface {
+	TypeUnicode(r rune) error
+}
+
+// TypeProfile describes the pause between typed keys, for target applications
+// that drop input arriving in an instant burst
+type TypeProfile struct {
+	Delay  time.Duration // Fixed pause between keys
+	Jitter time.Duration // Random extra pause of up to this duration
+}
+
+var typeProfiles = map[string]TypeProfile{
+	"instant": {},
+	"steady":  {Delay: 15 * time.Millisecond},
+	"human":   {Delay: 30 * time.Millisecond, Jitter: 70 * time.Millisecond},
+}
+
+// StringToTypeProfile returns the type profile with the given name
+func StringToTypeProfile(name string) (TypeProfile, bool) {
+	profile, ok := typeProfiles[name]
+	return profile, ok
+}
+
+// TypeProfileOptions lists the type profile names for help and error messages
+func TypeProfileOptions() string {
+	return "instant, steady, human"
+}
+
+// keyPacer pauses between typed keys according to a type profile
+type keyPacer struct {
+	profile TypeProfile
+	sleep   func(time.Duration) // Replaceable in tests
+	jitter  func(n int64) int64 // Random value in [0, n), replaceable in tests
+}
+
+// newKeyPacer creates a pacer for the profile, or nil if keys are typed instantly
+func newKeyPacer(profile TypeProfile) *keyPacer {
+	if profile.Delay == 0 && profile.Jitter == 0 {
+		return nil
+	}
+	return &keyPacer{
+		profile: profile,
+		sleep:   time.Sleep,
+		jitter:  rand.Int63n,
+	}
+}
+
+// pause waits before the next key, a nil pacer doesn't wait
+func (p *keyPacer) pause() {
+	if p == nil {
+		return
+	}
+
+	delay := p.profile.Delay
+	if p.profile.Jitter > 0 {
+		delay += time.Duration(p.jitter(int64(p.profile.Jitter)))
+	}
+	if delay > 0 {
+		p.sleep(delay)
+	}
+}
+
+// setShortcutModifier presses or releases the modifier for shortcuts like select all,
+// Cmd on macOS and Ctrl elsewhere
+func setShortcutModifier(kb keyboard, pressed bool) {
+	if runtime.GOOS == "darwin" {
+		kb.HasSuper(pressed)
+	} else {
+		kb.HasCTRL(pressed)
+	}
+}
+
+// CapsLockReport tells what the CAPS Lock protection did while a scan was typed
+type CapsLockReport struct {
+	Detectable bool   // The platform reads the CAPS Lock state, false on Linux and macOS
+	Strategy   string // nfc.capslock_strategy in effect
+	WasOn      bool   // CAPS Lock was on, so it was toggled off or compensated
+}
+
+// String summarizes the report for the logs: unavailable, off, toggled or compensated
+func (r CapsLockReport) String() string {
+	switch {
+	case !r.Detectable:
+		return "unavailable"
+	case !r.WasOn:
+		return "off"
+	case r.Strategy == "compensate":
+		return "compensated"
+	default:
+		return "toggled"
+	}
+}
+
+// capsLockStrategies lists the nfc.capslock_strategy options: toggle switches CAPS Lock
+// off while typing, compensate leaves it on and inverts Shift for letters instead
+var capsLockStrategies = []string{"toggle", "compensate"}
+
+// capsLockStrategy is the active CAPS Lock strategy, set once from the configuration at startup
+var capsLockStrategy = "toggle"
+
+// isCapsLockOn detects the CAPS Lock state, replaceable in tests
+var isCapsLockOn = (*CapsLockManager).IsCapsLockOn
+
+// IsValidCapsLockStrategy reports whether strategy is one of capsLockStrategies
+func IsValidCapsLockStrategy(strategy string) bool {
+	for _, option := range capsLockStrategies {
+		if option == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCapsLockStrategy sets how KeyboardWrite deals with CAPS Lock, keeping toggle if the
+// strategy is unknown
+func SetCapsLockStrategy(strategy string) {
+	if IsValidCapsLockStrategy(strategy) {
+		capsLockStrategy = strategy
+	}
+}
+
+// outputUnescaper turns the output as typed into text, the keys without a character
+// become control characters so escapeOutput can restore them
+var outputUnescaper = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\b`, "\b", `\a`, "\a", `\e`, "\x1b", `\\`, `\`, `\"`, `"`)
+
+// outputEscaper is the reverse of outputUnescaper, a backslash is doubled so it is typed literally
+var outputEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`, "\b", `\b`, "\a", `\a`, "\x1b", `\e`)
+
+// unescapeOutput returns the output as text for a transformation, see escapeOutput
+func unescapeOutput(output string) string {
+	return outputUnescaper.Replace(output)
+}
+
+// escapeOutput returns text for KeyboardWrite, which types it literally apart from the
+// control characters for Enter, Tab, Backspace, select all and Escape
+func escapeOutput(text string) string {
+	return outputEscaper.Replace(text)
+}
+
+// KeyboardWrite emulate keyboard input from string with CAPS Lock protection,
+// pausing between keys as set by the pacer (nil types instantly)
+func KeyboardWrite(textInput string, kb keyboard, pacer *keyPacer) error {
+	_, err := keyboardWriteReport(textInput, kb, pacer)
+	return err
+}
+
+// keyboardWriteReport is KeyboardWrite, also reporting what the CAPS Lock protection did
+func keyboardWriteReport(textInput string, kb keyboard, pacer *keyPacer) (CapsLockReport, error) {
+	// Create CAPS Lock manager
+	capsManager := NewCapsLockManager(kb)
+	report := CapsLockReport{Detectable: capsLockDetectable, Strategy: capsLockStrategy}
+
+	// With compensate CAPS Lock stays as it is, letters are typed with the opposite
+	// Shift state so they come out in the intended case
+	invertLetterShift := false
+	if capsLockStrategy == "compensate" {
+		invertLetterShift = isCapsLockOn(capsManager)
+		report.WasOn = invertLetterShift
+	} else {
+		// Disable CAPS Lock if it's on
+		if err := capsManager.DisableCapsLock(); err != nil {
+			return report, err
+		}
+		report.WasOn = capsManager.originalState
+
+		// Defer restoration of CAPS Lock state
+		defer func() {
+			capsManager.RestoreCapsLock() // Ignore error in defer
+		}()
+	}
+
+	//Should we skip next character in string
+	//Used if we found some escape sequence
+	skip := false
+	typed := false
+	// What is typed so far, to clean up after a typing error
+	progress := writeProgress{}
+	for i, c := range textInput {
+		if !skip {
+			if typed {
+				pacer.pause()
+			}
+			typed = true
+
+			// Printable character to type, 0 for keys without one
+			var char rune
+			key := keyChar
+			if c != '\\' {
+				char = c
+			} else if i+1 == len(textInput) {
+				//Trailing backslash without a sequence, typed literally
+				char = '\\'
+			} else {
+				//Found backslash escape character
+				//Check next character
+				switch textInput[i+1] {
+				case 'n':
+					//Found newline character sequence
+					kb.SetKeys(names["ENTER"].code)
+					key = keyLeave
+					skip = true
+				case '\\':
+					//Found backslash character sequence
+					char = '\\'
+					skip = true
+				case 'b':
+					//Found backspace character sequence
+					kb.SetKeys(names["BACKSPACE"].code)
+					key = keyBackspace
+					skip = true
+				case 'e':
+					//Found escape key sequence
+					kb.SetKeys(names["ESCAPE"].code)
+					key = keyLeave
+					skip = true
+				case 'a':
+					//Found select all sequence, typed as a shortcut
+					kb.SetKeys(names["a"].code)
+					kb.HasSHIFT(false)
+					setShortcutModifier(kb, true)
+					key = keySelectAll
+					skip = true
+				case 't':
+					//Found tab character sequence
+					kb.SetKeys(names["TAB"].code)
+					key = keyLeave
+					skip = true
+				case '"':
+					//Found double quote character sequence
+					char = '"'
+					skip = true
+				default:
+					//Nothing special, jsut backslash output
+					char = '\\'
+				}
+			}
+
+			if char != 0 {
+				if ut, ok := kb.(unicodeTyper); ok {
+					if err := ut.TypeUnicode(char); err != nil {
+						return report, progress.fail(err)
+					}
+					progress.record(key)
+					continue
+				}
+				typedKey, ok := typedKeys[string(char)]
+				if !ok {
+					logWarnf("Character %q can't be typed with the keyboard layout, skipped", char)
+					continue
+				}
+				shift := typedKey.shift
+				if invertLetterShift && (unicode.IsUpper(char) || unicode.IsLower(char)) {
+					shift = !shift
+				}
+				kb.SetKeys(typedKey.code)
+				kb.HasSHIFT(shift)
+			}
+			var err = kb.Launching()
+			setShortcutModifier(kb, false)
+			if err != nil {
+				return report, progress.fail(err)
+			}
+			progress.record(key)
+		} else {
+			skip = false
+		}
+
+	}
+	return report, nil
+
+}
@@ -0,0 +1,178 @@
+package nfcuid
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyboardWriteTypeProfiles(t *testing.T) {
+	tests := []struct {
+		profile  string
+		jitter   int64
+		expected []time.Duration
+		name     string
+	}{
+		{"instant", 0, nil, "instant types without pauses"},
+		{"steady", 0, []time.Duration{15 * time.Millisecond, 15 * time.Millisecond, 15 * time.Millisecond}, "steady pauses between every key"},
+		{"human", int64(25 * time.Millisecond), []time.Duration{55 * time.Millisecond, 55 * time.Millisecond, 55 * time.Millisecond}, "human adds jitter to the base pause"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			profile, ok := StringToTypeProfile(test.profile)
+			if !ok {
+				t.Fatalf("Unknown type profile %s", test.profile)
+			}
+
+			var slept []time.Duration
+			pacer := newKeyPacer(profile)
+			if pacer != nil {
+				pacer.sleep = func(d time.Duration) { slept = append(slept, d) }
+				pacer.jitter = func(n int64) int64 {
+					if test.jitter >= n {
+						t.Fatalf("Jitter %d out of range [0, %d)", test.jitter, n)
+					}
+					return test.jitter
+				}
+			}
+
+			kb := &mockKeyboard{}
+			if err := KeyboardWrite("ab\\n1", kb, pacer); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result := kb.text(); result != "ab\n1" {
+				t.Errorf("Expected typed text %q, got %q", "ab\n1", result)
+			}
+
+			if len(slept) != len(test.expected) {
+				t.Fatalf("Expected %d pauses, got %v", len(test.expected), slept)
+			}
+			for i, delay := range test.expected {
+				if slept[i] != delay {
+					t.Errorf("Pause %d: expected %v, got %v", i, delay, slept[i])
+				}
+			}
+		})
+	}
+}
+
+// mockUnicodeKeyboard records characters typed by codepoint, other keys are recorded
+// by the embedded mockKeyboard
+type mockUnicodeKeyboard struct {
+	*mockKeyboard
+	runes []rune
+}
+
+func (k *mockUnicodeKeyboard) TypeUnicode(r rune) error {
+	k.runes = append(k.runes, r)
+	return nil
+}
+
+func TestKeyboardWriteUnicode(t *testing.T) {
+	tests := []struct {
+		input string
+		runes string
+		keys  string
+		name  string
+	}{
+		{"04:a2", "04:a2", "", "characters typed by codepoint"},
+		{"AB\\n", "AB", "\n", "enter still typed as key code"},
+		{"a\\\\b\\\"\\tc", "a\\b\"c", "\t", "escaped characters typed by codepoint"},
+		{"x\\a\\b", "x", "^a\b", "clear keys typed as key codes"},
+		{"a\\", "a\\", "", "trailing backslash typed literally"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kb := &mockUnicodeKeyboard{mockKeyboard: &mockKeyboard{}}
+			if err := KeyboardWrite(test.input, kb, nil); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if runes := string(kb.runes); runes != test.runes {
+				t.Errorf("Expected %q typed by codepoint, got %q", test.runes, runes)
+			}
+			if keys := kb.text(); keys != test.keys {
+				t.Errorf("Expected %q typed as key codes, got %q", test.keys, keys)
+			}
+		})
+	}
+}
+
+func TestKeyboardWriteCapsLockCompensate(t *testing.T) {
+	tests := []struct {
+		strategy string
+		capsLock bool
+		keys     string
+		name     string
+	}{
+		{"compensate", false, "04A2:b9", "caps lock off types as is"},
+		{"compensate", true, "04a2:B9", "caps lock on inverts shift for letters"},
+		{"toggle", true, "04A2:b9", "toggle leaves shift alone"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetCapsLockStrategy(test.strategy)
+			isCapsLockOn = func(*CapsLockManager) bool { return test.capsLock }
+			defer func() {
+				SetCapsLockStrategy("toggle")
+				isCapsLockOn = (*CapsLockManager).IsCapsLockOn
+			}()
+
+			kb := &mockKeyboard{}
+			if err := KeyboardWrite("04A2:b9", kb, nil); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			// Keys as pressed, with CAPS Lock on "a" comes out as "A"
+			if keys := kb.text(); keys != test.keys {
+				t.Errorf("Expected keys %q, got %q", test.keys, keys)
+			}
+		})
+	}
+}
+
+func TestKeyboardWriteReportsCapsLock(t *testing.T) {
+	tests := []struct {
+		strategy string
+		capsLock bool
+		summary  string
+		name     string
+	}{
+		{"toggle", false, "off", "caps lock off"},
+		{"toggle", true, "toggled", "caps lock toggled off"},
+		{"compensate", true, "compensated", "caps lock compensated"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetCapsLockStrategy(test.strategy)
+			isCapsLockOn = func(*CapsLockManager) bool { return test.capsLock }
+			defer func() {
+				SetCapsLockStrategy("toggle")
+				isCapsLockOn = (*CapsLockManager).IsCapsLockOn
+			}()
+
+			report, err := keyboardWriteReport("04a2", &mockKeyboard{}, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if report.WasOn != test.capsLock || report.Strategy != test.strategy {
+				t.Errorf("Expected CAPS Lock on %v with %s, got %+v", test.capsLock, test.strategy, report)
+			}
+			if report.Detectable != capsLockDetectable {
+				t.Errorf("Expected detectable %v, got %v", capsLockDetectable, report.Detectable)
+			}
+
+			// The summary only tells what happened where the state can be read
+			report.Detectable = true
+			if summary := report.String(); summary != test.summary {
+				t.Errorf("Expected %q, got %q", test.summary, summary)
+			}
+			report.Detectable = false
+			if summary := report.String(); summary != "unavailable" {
+				t.Errorf("Expected \"unavailable\" without detection, got %q", summary)
+			}
+		})
+	}
+}
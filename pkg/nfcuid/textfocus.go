@@ -0,0 +1,102 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// textFocusPoll is how often the focused control is checked while waiting for a text field
+const textFocusPoll = 100 * time.Millisecond
+
+// maxTextFocusWaitMs is the largest nfc.text_focus_wait_ms, the card may be removed meanwhile
+const maxTextFocusWaitMs = 10000
+
+// errNoTextFocus is returned when nfc.require_text_focus found no text field to type into
+var errNoTextFocus = errors.New("no text field focused")
+
+// focusState is what the focused control of the foreground window takes
+type focusState int
+
+const (
+	focusUnknown focusState = iota // Can't be told, e.g. browser content or no platform support
+	focusText                      // A text field, typing goes ahead
+	focusNotText                   // A button, list, menu or no focused control at all
+)
+
+// textFocusClasses are lowercase window classes of Win32 controls, true for text fields
+// and false for controls that would act on typed keys instead (Enter presses a button).
+// Classes starting with richedit are text fields too.
+var textFocusClasses = map[string]bool{
+	"edit":              true,
+	"button":            false,
+	"combolbox":         false,
+	"listbox":           false,
+	"syslistview32":     false,
+	"systreeview32":     false,
+	"systabcontrol32":   false,
+	"toolbarwindow32":   false,
+	"msctls_trackbar32": false,
+	"#32768":            false, // Menu
+}
+
+// classifyFocusClass tells what a control of the window class takes, window classes
+// are case-insensitive
+func classifyFocusClass(class string) focusState {
+	class = strings.ToLower(class)
+	if strings.HasPrefix(class, "richedit") {
+		return focusText
+	}
+	text, known := textFocusClasses[class]
+	switch {
+	case !known:
+		return focusUnknown
+	case text:
+		return focusText
+	default:
+		return focusNotText
+	}
+}
+
+// waitForTextFocus polls the focused control until it takes text, for at most wait.
+// Controls that can't be classified count as text fields, so typing goes ahead where
+// the check isn't possible. It returns false if no text field was focused in time.
+func waitForTextFocus(focused func() focusState, wait, poll time.Duration, sleep func(time.Duration)) bool {
+	for waited := time.Duration(0); ; waited += poll {
+		if focused() != focusNotText {
+			return true
+		}
+		if waited >= wait {
+			return false
+		}
+		sleep(poll)
+	}
+}
+
+// checkTextFocus holds the typing back until a text field is focused with
+// nfc.require_text_focus, returning errNoTextFocus if none was within text_focus_wait_ms
+func (s *service) checkTextFocus() error {
+	if !s.config.NFC.RequireTextFocus {
+		return nil
+	}
+	wait := time.Duration(s.config.NFC.TextFocusWaitMs) * time.Millisecond
+	if !waitForTextFocus(focusedControl, wait, textFocusPoll, time.Sleep) {
+		return errNoTextFocus
+	}
+	return nil
+}
+
+// handleUnfocusedCard reports a card that wasn't typed for lack of a text field and
+// waits for it to be removed, so it isn't read again while it rests on the reader
+func (s *service) handleUnfocusedCard(ctx cardContext, selectedReaders []string, index int) error {
+	fmt.Println("No text field focused, card not typed")
+	s.notificationManager.NotifyErrorThrottled("keyboard-focus", "Kein Textfeld ausgewählt, Karten-ID wurde nicht eingegeben.")
+	s.recordError(selectedReaders[index], errNoTextFocus.Error())
+	s.audioManager.PlayErrorSound()
+
+	if isTriggeredMode(s.config.NFC.Trigger) {
+		return nil
+	}
+	return s.waitForCardRemoval(ctx, selectedReaders, index)
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package nfcuid
+
+// textFocusAvail reports whether nfc.require_text_focus can check the focused control
+const textFocusAvail = false
+
+// focusedControl can't tell the focused control on this platform, so typing goes ahead
+func focusedControl() focusState {
+	return focusUnknown
+}
@@ -0,0 +1,72 @@
+package nfcuid
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitForTextFocus(t *testing.T) {
+	tests := []struct {
+		states   []focusState
+		wait     time.Duration
+		expected bool
+		polls    int
+		name     string
+	}{
+		{[]focusState{focusText}, 2 * time.Second, true, 1, "text field"},
+		{[]focusState{focusUnknown}, 2 * time.Second, true, 1, "unknown counts as text"},
+		{[]focusState{focusNotText, focusNotText, focusText}, 2 * time.Second, true, 3, "buffered until text field"},
+		{[]focusState{focusNotText}, 300 * time.Millisecond, false, 4, "skipped after wait"},
+		{[]focusState{focusNotText}, 0, false, 1, "skipped at once"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			polls := 0
+			focused := func() focusState {
+				state := test.states[len(test.states)-1]
+				if polls < len(test.states) {
+					state = test.states[polls]
+				}
+				polls++
+				return state
+			}
+			var slept time.Duration
+			sleep := func(d time.Duration) { slept += d }
+
+			if result := waitForTextFocus(focused, test.wait, textFocusPoll, sleep); result != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, result)
+			}
+			if polls != test.polls {
+				t.Errorf("Expected %d polls, got %d", test.polls, polls)
+			}
+			if slept > test.wait {
+				t.Errorf("Expected to wait at most %v, waited %v", test.wait, slept)
+			}
+		})
+	}
+}
+
+func TestClassifyFocusClass(t *testing.T) {
+	tests := []struct {
+		class    string
+		expected focusState
+	}{
+		{"Edit", focusText},
+		{"RichEdit20W", focusText},
+		{"RICHEDIT50W", focusText},
+		{"BUTTON", focusNotText},
+		{"Button", focusNotText},
+		{"SysListView32", focusNotText},
+		{"#32768", focusNotText},
+		{"Chrome_RenderWidgetHostHWND", focusUnknown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.class, func(t *testing.T) {
+			if result := classifyFocusClass(test.class); result != test.expected {
+				t.Errorf("Expected %d, got %d", test.expected, result)
+			}
+		})
+	}
+}
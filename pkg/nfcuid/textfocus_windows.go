@@ -0,0 +1,53 @@
+package nfcuid
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	getGUIThreadInfo = user32.NewProc("GetGUIThreadInfo")
+	getClassName     = user32.NewProc("GetClassNameW")
+)
+
+// textFocusAvail reports whether nfc.require_text_focus can check the focused control
+const textFocusAvail = true
+
+// guiInMenuMode is GUI_INMENUMODE of GUITHREADINFO, set while a menu is open
+const guiInMenuMode = 0x0004
+
+// guiThreadInfo mirrors the Win32 GUITHREADINFO structure
+type guiThreadInfo struct {
+	cbSize        uint32
+	flags         uint32
+	hwndActive    uintptr
+	hwndFocus     uintptr
+	hwndCapture   uintptr
+	hwndMenuOwner uintptr
+	hwndMoveSize  uintptr
+	hwndCaret     uintptr
+	rcCaret       [4]int32
+}
+
+// focusedControl tells what the focused control of the foreground window takes: a
+// blinking caret means text input, otherwise the window class of the control decides
+func focusedControl() focusState {
+	info := guiThreadInfo{}
+	info.cbSize = uint32(unsafe.Sizeof(info))
+	if ret, _, _ := getGUIThreadInfo.Call(0, uintptr(unsafe.Pointer(&info))); ret == 0 {
+		return focusUnknown
+	}
+	switch {
+	case info.flags&guiInMenuMode != 0, info.hwndFocus == 0:
+		return focusNotText
+	case info.hwndCaret != 0:
+		return focusText
+	}
+
+	class := make([]uint16, 256)
+	n, _, _ := getClassName.Call(info.hwndFocus, uintptr(unsafe.Pointer(&class[0])), uintptr(len(class)))
+	if n == 0 {
+		return focusUnknown
+	}
+	return classifyFocusClass(syscall.UTF16ToString(class[:n]))
+}
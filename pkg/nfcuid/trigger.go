@@ -0,0 +1,200 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ebfe/scard"
+	"github.com/micmonay/keybd_event"
+)
+
+// triggerModes lists the nfc.trigger options: auto reads every presented card, manual
+// reads the card on the reader only when triggered, so cards passing by aren't read, and
+// hotkey does the same when the global hotkey nfc.trigger_hotkey is pressed
+var triggerModes = []string{"auto", "manual", "hotkey"}
+
+// errTriggerAuto is returned by Trigger with nfc.trigger auto, every card is read
+// without a trigger then
+var errTriggerAuto = errors.New("reads are only triggered with nfc.trigger manual or hotkey")
+
+// IsValidTriggerMode reports whether mode is one of triggerModes
+func IsValidTriggerMode(mode string) bool {
+	for _, option := range triggerModes {
+		if option == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// isTriggeredMode reports whether cards are only read on a trigger with the mode, not
+// when presented
+func isTriggeredMode(mode string) bool {
+	return mode == "manual" || mode == "hotkey"
+}
+
+// triggerHotkeyKeys are the keys a trigger hotkey can end with, by their keybd_event
+// code: the Linux input event code, and the scan code on Windows
+var triggerHotkeyKeys = map[string]int{
+	"a": keybd_event.VK_A, "b": keybd_event.VK_B, "c": keybd_event.VK_C, "d": keybd_event.VK_D,
+	"e": keybd_event.VK_E, "f": keybd_event.VK_F, "g": keybd_event.VK_G, "h": keybd_event.VK_H,
+	"i": keybd_event.VK_I, "j": keybd_event.VK_J, "k": keybd_event.VK_K, "l": keybd_event.VK_L,
+	"m": keybd_event.VK_M, "n": keybd_event.VK_N, "o": keybd_event.VK_O, "p": keybd_event.VK_P,
+	"q": keybd_event.VK_Q, "r": keybd_event.VK_R, "s": keybd_event.VK_S, "t": keybd_event.VK_T,
+	"u": keybd_event.VK_U, "v": keybd_event.VK_V, "w": keybd_event.VK_W, "x": keybd_event.VK_X,
+	"y": keybd_event.VK_Y, "z": keybd_event.VK_Z,
+	"0": keybd_event.VK_0, "1": keybd_event.VK_1, "2": keybd_event.VK_2, "3": keybd_event.VK_3,
+	"4": keybd_event.VK_4, "5": keybd_event.VK_5, "6": keybd_event.VK_6, "7": keybd_event.VK_7,
+	"8": keybd_event.VK_8, "9": keybd_event.VK_9,
+	"f1": keybd_event.VK_F1, "f2": keybd_event.VK_F2, "f3": keybd_event.VK_F3, "f4": keybd_event.VK_F4,
+	"f5": keybd_event.VK_F5, "f6": keybd_event.VK_F6, "f7": keybd_event.VK_F7, "f8": keybd_event.VK_F8,
+	"f9": keybd_event.VK_F9, "f10": keybd_event.VK_F10, "f11": keybd_event.VK_F11, "f12": keybd_event.VK_F12,
+	"space": keybd_event.VK_SPACE,
+}
+
+// triggerHotkey is a parsed nfc.trigger_hotkey
+type triggerHotkey struct {
+	ctrl  bool
+	shift bool
+	alt   bool
+	super bool // Windows key, Super or Meta on Linux
+	key   int  // Code of the key in triggerHotkeyKeys
+}
+
+// parseTriggerHotkey parses a hotkey like "ctrl+alt+n": the modifiers ctrl, shift, alt
+// and super joined by +, then one key of triggerHotkeyKeys. Ctrl, alt or super is
+// required, otherwise typing text anywhere would trigger reads.
+func parseTriggerHotkey(spec string) (triggerHotkey, error) {
+	var hotkey triggerHotkey
+	parts := strings.Split(strings.ToLower(strings.ReplaceAll(spec, " ", "")), "+")
+	for _, modifier := range parts[:len(parts)-1] {
+		switch modifier {
+		case "ctrl":
+			hotkey.ctrl = true
+		case "shift":
+			hotkey.shift = true
+		case "alt":
+			hotkey.alt = true
+		case "super":
+			hotkey.super = true
+		default:
+			return hotkey, fmt.Errorf("unknown modifier %q in %q (options: ctrl, shift, alt, super)", modifier, spec)
+		}
+	}
+	key, ok := triggerHotkeyKeys[parts[len(parts)-1]]
+	if !ok {
+		return hotkey, fmt.Errorf("unknown key %q in %q (options: a-z, 0-9, f1-f12, space)", parts[len(parts)-1], spec)
+	}
+	if !hotkey.ctrl && !hotkey.alt && !hotkey.super {
+		return hotkey, fmt.Errorf("%q needs ctrl, alt or super, otherwise typing text would trigger reads", spec)
+	}
+	hotkey.key = key
+	return hotkey, nil
+}
+
+// Trigger requests a single read of the card on the reader with nfc.trigger manual or
+// hotkey. Triggers arriving while a triggered read is in progress are dropped.
+func (s *service) Trigger() error {
+	if !isTriggeredMode(s.config.NFC.Trigger) {
+		return errTriggerAuto
+	}
+	select {
+	case s.triggers <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// readOnTrigger waits for a trigger and reads the card that is on a reader at that
+// moment. Without a card the error sound is played.
+func (s *service) readOnTrigger(ctx cardContext, selectedReaders []string, kb keyboard) error {
+	s.printScanProgress("Waiting for the trigger...\n")
+	if err := s.waitForTrigger(); err != nil {
+		return err
+	}
+	// Triggers pressed during this read don't start another one
+	defer s.drainTriggers()
+	// Every triggered read is confirmed, the card may rest on the reader between them
+	for _, reader := range selectedReaders {
+		s.presentBeepRemoved(reader)
+	}
+
+	index, mute, err := s.presentCard(ctx, selectedReaders)
+	if err != nil {
+		s.notificationManager.NotifyErrorThrottled("card-error", "Karte konnte nicht erkannt werden. Bitte NFC-Lesegerät überprüfen.")
+		s.recordError("", err.Error())
+		if s.config.Advanced.AutoReconnect {
+			return nil
+		}
+		return err
+	}
+	if index < 0 {
+		fmt.Println("Triggered without a card on the reader")
+		s.audioManager.PlayErrorSound()
+		return nil
+	}
+	if mute {
+		fmt.Println("Card is present but not responding (mute), skipping")
+		s.notificationManager.NotifyErrorThrottled("card-mute", "Karte nicht lesbar/beschädigt. Bitte andere Karte verwenden.")
+		s.recordError(selectedReaders[index], "card is mute")
+		s.audioManager.PlayErrorSound()
+		return nil
+	}
+
+	return s.handleProcessCard(ctx, selectedReaders, index, kb)
+}
+
+// waitForTrigger blocks until Trigger is called or a shutdown signal arrives, sending
+// watchdog heartbeats while idle
+func (s *service) waitForTrigger() error {
+	var heartbeat <-chan time.Time
+	if timeout := s.pollTimeout(); timeout > 0 {
+		ticker := time.NewTicker(timeout)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		s.heartbeat()
+		select {
+		case <-s.triggers:
+			return nil
+		case <-s.shutdown.Done():
+			return errShutdownRequested
+		case <-heartbeat:
+		}
+	}
+}
+
+// drainTriggers drops a pending trigger
+func (s *service) drainTriggers() {
+	select {
+	case <-s.triggers:
+	default:
+	}
+}
+
+// presentCard returns the index of the first reader with a card on it and whether the
+// card is mute, or -1 without waiting if no reader has a card
+func (s *service) presentCard(ctx cardContext, readers []string) (int, bool, error) {
+	rs := make([]scard.ReaderState, len(readers))
+	for i := range rs {
+		rs[i].Reader = readers[i]
+		rs[i].CurrentState = scard.StateUnaware
+	}
+
+	err := ctx.GetStatusChange(rs, 0)
+	s.heartbeat()
+	logReaderStateTransitions(rs)
+	if err != nil && err != scard.ErrTimeout {
+		return -1, false, fmt.Errorf("failed to get reader states: %v", err)
+	}
+	for i := range rs {
+		if rs[i].EventState&scard.StatePresent != 0 {
+			return i, rs[i].EventState&scard.StateMute != 0, nil
+		}
+	}
+	return -1, false, nil
+}
@@ -0,0 +1,179 @@
+package nfcuid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// triggerHotkeyAvailable reports whether nfc.trigger hotkey is supported on this platform
+const triggerHotkeyAvailable = true
+
+// inputDevicesGlob matches the input devices the trigger hotkey is read from, replaceable in tests
+var inputDevicesGlob = "/dev/input/event*"
+
+// inputDeviceRescan is how often keyboards connected after startup are looked for
+const inputDeviceRescan = 5 * time.Second
+
+// virtualKeyboardName is the name of the uinput device keybd_event types the output
+// with, its keys are never the hotkey
+const virtualKeyboardName = "keybd interface"
+
+// Input event types and key values of linux/input.h
+const (
+	evKey      = 0x01 // EV_KEY
+	keyRelease = 0
+	keyPress   = 1
+)
+
+// Input event codes of the modifier keys, left and right
+var (
+	ctrlKeys  = []uint16{29, 97}
+	shiftKeys = []uint16{42, 54}
+	altKeys   = []uint16{56, 100}
+	superKeys = []uint16{125, 126}
+)
+
+// inputEvent mirrors struct input_event of linux/input.h
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// hotkeyWatcher reads the keys of all keyboards from their input devices and triggers
+// a read when the hotkey is pressed on one of them. The keys still reach the focused
+// application, the input devices can't be grabbed without taking the whole keyboard.
+type hotkeyWatcher struct {
+	mu      sync.Mutex
+	hotkey  triggerHotkey
+	trigger func()
+	devices map[string]*os.File
+	done    chan struct{}
+}
+
+// watchTriggerHotkey triggers a read when nfc.trigger_hotkey is pressed on a keyboard.
+// Reading the input devices needs the user in the input group, like typing needs
+// access to uinput. The returned function stops watching.
+func (s *service) watchTriggerHotkey() (func(), error) {
+	hotkey, err := parseTriggerHotkey(s.config.NFC.TriggerHotkey)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &hotkeyWatcher{
+		hotkey:  hotkey,
+		trigger: func() { s.Trigger() },
+		devices: make(map[string]*os.File),
+		done:    make(chan struct{}),
+	}
+	if w.scan() == 0 {
+		return nil, fmt.Errorf("no keyboard readable in %s, add the user to the input group", filepath.Dir(inputDevicesGlob))
+	}
+	go w.rescan()
+
+	fmt.Printf("nfc.trigger hotkey: reads are started with %s\n", s.config.NFC.TriggerHotkey)
+	return w.close, nil
+}
+
+// scan opens the input devices that aren't watched yet and returns how many are watched
+func (w *hotkeyWatcher) scan() int {
+	paths, _ := filepath.Glob(inputDevicesGlob)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, path := range paths {
+		if _, ok := w.devices[path]; ok || isVirtualKeyboard(path) {
+			continue
+		}
+		device, err := os.Open(path)
+		if err != nil {
+			logDebugf("Input device %s not readable for the trigger hotkey: %v", path, err)
+			continue
+		}
+		w.devices[path] = device
+		go w.read(path, device)
+	}
+	return len(w.devices)
+}
+
+// rescan looks for newly connected keyboards until the watcher is closed
+func (w *hotkeyWatcher) rescan() {
+	ticker := time.NewTicker(inputDeviceRescan)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.scan()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// isVirtualKeyboard reports whether the input device is the one keybd_event types with
+func isVirtualKeyboard(path string) bool {
+	name, err := os.ReadFile(filepath.Join("/sys/class/input", filepath.Base(path), "device/name"))
+	return err == nil && strings.TrimSpace(string(name)) == virtualKeyboardName
+}
+
+// read follows the keys of one input device until it is disconnected or the watcher closed
+func (w *hotkeyWatcher) read(path string, device *os.File) {
+	pressed := make(map[uint16]bool)
+	for {
+		var event inputEvent
+		if err := binary.Read(device, binary.NativeEndian, &event); err != nil {
+			w.mu.Lock()
+			if w.devices[path] == device {
+				delete(w.devices, path)
+			}
+			w.mu.Unlock()
+			device.Close()
+			return
+		}
+		if w.hotkey.pressedWith(event, pressed) {
+			w.trigger()
+		}
+	}
+}
+
+// pressedWith tracks the keys held on a device and reports whether the event presses
+// the hotkey's key with exactly its modifiers. Key repeats don't count, holding the
+// hotkey triggers a single read.
+func (hotkey triggerHotkey) pressedWith(event inputEvent, pressed map[uint16]bool) bool {
+	if event.Type != evKey {
+		return false
+	}
+	switch event.Value {
+	case keyRelease:
+		delete(pressed, event.Code)
+		return false
+	case keyPress:
+		pressed[event.Code] = true
+	default:
+		return false
+	}
+
+	held := func(codes []uint16) bool {
+		return pressed[codes[0]] || pressed[codes[1]]
+	}
+	return int(event.Code) == hotkey.key && held(ctrlKeys) == hotkey.ctrl && held(shiftKeys) == hotkey.shift &&
+		held(altKeys) == hotkey.alt && held(superKeys) == hotkey.super
+}
+
+// close stops watching and closes the input devices
+func (w *hotkeyWatcher) close() {
+	close(w.done)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for path, device := range w.devices {
+		device.Close()
+		delete(w.devices, path)
+	}
+}
@@ -0,0 +1,88 @@
+package nfcuid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// keyEvent returns the input event of a key press (1), release (0) or repeat (2)
+func keyEvent(code uint16, value int32) inputEvent {
+	return inputEvent{Type: evKey, Code: code, Value: value}
+}
+
+func TestTriggerHotkeyPressed(t *testing.T) {
+	hotkey, err := parseTriggerHotkey("ctrl+alt+n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := uint16(triggerHotkeyKeys["n"])
+
+	tests := []struct {
+		events   []inputEvent
+		expected int
+		name     string
+	}{
+		{[]inputEvent{keyEvent(29, 1), keyEvent(56, 1), keyEvent(n, 1)}, 1, "left modifiers"},
+		{[]inputEvent{keyEvent(97, 1), keyEvent(100, 1), keyEvent(n, 1)}, 1, "right modifiers"},
+		{[]inputEvent{keyEvent(29, 1), keyEvent(56, 1), keyEvent(n, 1), keyEvent(n, 2), keyEvent(n, 2)}, 1, "repeat ignored"},
+		{[]inputEvent{keyEvent(29, 1), keyEvent(56, 1), keyEvent(n, 1), keyEvent(n, 0), keyEvent(n, 1)}, 2, "pressed twice"},
+		{[]inputEvent{keyEvent(29, 1), keyEvent(n, 1)}, 0, "modifier missing"},
+		{[]inputEvent{keyEvent(29, 1), keyEvent(56, 1), keyEvent(42, 1), keyEvent(n, 1)}, 0, "extra modifier"},
+		{[]inputEvent{keyEvent(29, 1), keyEvent(56, 1), keyEvent(56, 0), keyEvent(n, 1)}, 0, "modifier released"},
+		{[]inputEvent{{Type: 0x02, Code: n, Value: 1}}, 0, "not a key event"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pressed := make(map[uint16]bool)
+			triggers := 0
+			for _, event := range test.events {
+				if hotkey.pressedWith(event, pressed) {
+					triggers++
+				}
+			}
+			if triggers != test.expected {
+				t.Errorf("Expected %d triggers, got %d", test.expected, triggers)
+			}
+		})
+	}
+}
+
+func TestWatchTriggerHotkey(t *testing.T) {
+	dir := t.TempDir()
+	originalGlob := inputDevicesGlob
+	inputDevicesGlob = filepath.Join(dir, "event*")
+	defer func() { inputDevicesGlob = originalGlob }()
+
+	config := DefaultConfig()
+	config.NFC.Trigger = "hotkey"
+	s := newTestService(config)
+
+	// Without a readable input device the hotkey can't work
+	if _, err := s.watchTriggerHotkey(); err == nil {
+		t.Fatal("Expected an error without input devices")
+	}
+
+	var events bytes.Buffer
+	for _, event := range []inputEvent{keyEvent(29, 1), keyEvent(56, 1), keyEvent(uint16(triggerHotkeyKeys["n"]), 1)} {
+		binary.Write(&events, binary.NativeEndian, event)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "event0"), events.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, err := s.watchTriggerHotkey()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer stop()
+	select {
+	case <-s.triggers:
+	case <-time.After(time.Second):
+		t.Error("Expected the hotkey to trigger a read")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !windows
+
+package nfcuid
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// triggerHotkeyAvailable reports whether nfc.trigger hotkey is supported on this platform
+const triggerHotkeyAvailable = false
+
+// watchTriggerHotkey has no global hotkey to watch on this platform, validateConfig
+// rejects nfc.trigger hotkey before
+func (s *service) watchTriggerHotkey() (func(), error) {
+	return nil, fmt.Errorf("global hotkeys are not supported on %s", runtime.GOOS)
+}
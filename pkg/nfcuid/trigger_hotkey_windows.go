@@ -0,0 +1,106 @@
+package nfcuid
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+var (
+	registerHotKey     = user32.NewProc("RegisterHotKey")
+	unregisterHotKey   = user32.NewProc("UnregisterHotKey")
+	getMessage         = user32.NewProc("GetMessageW")
+	postThreadMessage  = user32.NewProc("PostThreadMessageW")
+	mapVirtualKey      = user32.NewProc("MapVirtualKeyW")
+	getCurrentThreadID = kernel32.NewProc("GetCurrentThreadId")
+)
+
+// triggerHotkeyAvailable reports whether nfc.trigger hotkey is supported on this platform
+const triggerHotkeyAvailable = true
+
+const (
+	modAlt       = 0x0001 // MOD_ALT
+	modControl   = 0x0002 // MOD_CONTROL
+	modShift     = 0x0004 // MOD_SHIFT
+	modWin       = 0x0008 // MOD_WIN
+	modNoRepeat  = 0x4000 // MOD_NOREPEAT, holding the hotkey triggers a single read
+	wmHotkey     = 0x0312 // WM_HOTKEY
+	wmQuit       = 0x0012 // WM_QUIT
+	mapvkVscToVk = 1      // MAPVK_VSC_TO_VK
+	hotkeyID     = 1      // Identifier of the trigger hotkey, unique within the thread
+)
+
+// winMsg mirrors the Win32 MSG structure
+type winMsg struct {
+	hwnd     uintptr
+	message  uint32
+	wParam   uintptr
+	lParam   uintptr
+	time     uint32
+	pt       [2]int32
+	lPrivate uint32
+}
+
+// modifiers returns the RegisterHotKey modifiers of the hotkey
+func (hotkey triggerHotkey) modifiers() uintptr {
+	mods := uintptr(modNoRepeat)
+	if hotkey.ctrl {
+		mods |= modControl
+	}
+	if hotkey.shift {
+		mods |= modShift
+	}
+	if hotkey.alt {
+		mods |= modAlt
+	}
+	if hotkey.super {
+		mods |= modWin
+	}
+	return mods
+}
+
+// watchTriggerHotkey registers nfc.trigger_hotkey as a global hotkey that triggers a
+// read. The hotkey belongs to the thread that registered it, so a locked thread waits
+// for its messages. The returned function unregisters it.
+func (s *service) watchTriggerHotkey() (func(), error) {
+	hotkey, err := parseTriggerHotkey(s.config.NFC.TriggerHotkey)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := make(chan error, 1)
+	var threadID uintptr
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		threadID, _, _ = getCurrentThreadID.Call()
+		vk, _, _ := mapVirtualKey.Call(uintptr(hotkey.key), mapvkVscToVk)
+		if ret, _, err := registerHotKey.Call(0, hotkeyID, hotkey.modifiers(), vk); ret == 0 {
+			registered <- fmt.Errorf("already used by another application: %v", err)
+			return
+		}
+		defer unregisterHotKey.Call(0, hotkeyID)
+		registered <- nil
+
+		var msg winMsg
+		for {
+			// 0 is WM_QUIT, -1 an error
+			ret, _, _ := getMessage.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+			if int32(ret) <= 0 {
+				return
+			}
+			if msg.message == wmHotkey {
+				s.Trigger()
+			}
+		}
+	}()
+	if err := <-registered; err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("nfc.trigger hotkey: reads are started with %s\n", s.config.NFC.TriggerHotkey)
+	return func() {
+		postThreadMessage.Call(threadID, wmQuit, 0, 0)
+	}, nil
+}
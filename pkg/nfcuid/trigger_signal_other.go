@@ -0,0 +1,36 @@
+//go:build !windows
+
+package nfcuid
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// triggerSignalAvailable reports whether the standalone binary can be triggered by a
+// signal, without -stdio
+const triggerSignalAvailable = true
+
+// watchTriggerSignal triggers a read on SIGUSR1, so a hotkey of the desktop or a button
+// daemon can run e.g. "pkill -USR1 nfcuid". The returned function stops watching.
+func (s *service) watchTriggerSignal() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				s.Trigger()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
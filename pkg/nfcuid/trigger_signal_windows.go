@@ -0,0 +1,14 @@
+package nfcuid
+
+import "fmt"
+
+// triggerSignalAvailable reports whether the standalone binary can be triggered by a
+// signal, without -stdio
+const triggerSignalAvailable = false
+
+// watchTriggerSignal has no signal to watch on Windows, reads are only triggered by the
+// -stdio trigger command or Reader.Trigger of a host program
+func (s *service) watchTriggerSignal() func() {
+	fmt.Println("nfc.trigger manual: reads are started by the stdio trigger command or Reader.Trigger")
+	return func() {}
+}
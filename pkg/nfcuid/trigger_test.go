@@ -0,0 +1,158 @@
+package nfcuid
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+func TestReadOnTrigger(t *testing.T) {
+	uidResponse := []byte{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}
+
+	tests := []struct {
+		states     []scard.StateFlag
+		expected   string
+		connects   int
+		errorSound bool
+		name       string
+	}{
+		{[]scard.StateFlag{scard.StatePresent}, "04a22b91\n", 1, false, "card present"},
+		{[]scard.StateFlag{scard.StateEmpty}, "", 0, true, "no card"},
+		{[]scard.StateFlag{scard.StatePresent | scard.StateMute}, "", 0, true, "mute card"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &mockContext{
+				readers: []string{"Reader 0", "Reader 1"},
+				states:  map[string][]scard.StateFlag{"Reader 1": test.states},
+				card:    &mockCard{responses: [][]byte{uidResponse}},
+			}
+			config := DefaultConfig()
+			config.NFC.Trigger = "manual"
+			config.NFC.EndChar = "enter"
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+			played := make(chan string, 2)
+			s.audioManager.enabled = true
+			s.audioManager.play = func(sound string) { played <- sound }
+
+			// A second trigger before the read is dropped, the card is read once
+			for i := 0; i < 2; i++ {
+				if err := s.Trigger(); err != nil {
+					t.Fatalf("Unexpected trigger error: %v", err)
+				}
+			}
+			kb := &mockKeyboard{}
+			if err := s.readNextCard(ctx, ctx.readers, kb); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result := kb.text(); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+			if ctx.connects != test.connects {
+				t.Errorf("Expected %d connects, got %d", test.connects, ctx.connects)
+			}
+			if len(s.triggers) != 0 {
+				t.Errorf("Expected no pending trigger after the read")
+			}
+
+			// Sounds play in the background
+			errorSound := false
+			for waiting := true; waiting; {
+				select {
+				case sound := <-played:
+					errorSound = errorSound || sound == s.audioManager.errorSound
+				case <-time.After(100 * time.Millisecond):
+					waiting = false
+				}
+			}
+			if errorSound != test.errorSound {
+				t.Errorf("Expected error sound %v, got %v", test.errorSound, errorSound)
+			}
+		})
+	}
+}
+
+func TestReadOnTriggerShutdown(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.Trigger = "manual"
+	s := newTestService(config)
+	shutdown, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.shutdown = shutdown
+
+	// Without a trigger the wait only ends on shutdown, no card is looked at
+	ctx := &mockContext{readers: []string{"Reader 0"}}
+	if err := s.readNextCard(ctx, ctx.readers, &mockKeyboard{}); !errors.Is(err, errShutdownRequested) {
+		t.Errorf("Expected the shutdown to end the trigger wait, got %v", err)
+	}
+
+	config = DefaultConfig()
+	config.NFC.Trigger = "manual"
+	config.NFC.OnReleaseKeys = []string{"escape"}
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected on release keys with a manual trigger to be rejected")
+	}
+	config.NFC.OnReleaseKeys = nil
+
+	// Without a trigger signal (Windows) only -stdio can start a read
+	if err := validateConfig(config); (err == nil) != triggerSignalAvailable {
+		t.Errorf("Expected manual trigger without -stdio valid %v on %s, got %v", triggerSignalAvailable, runtime.GOOS, err)
+	}
+	config.Stdio = true
+	config.NFC.Device = 1
+	if err := validateConfig(config); err != nil {
+		t.Errorf("Expected manual trigger with -stdio to be valid, got %v", err)
+	}
+	config.NFC.Trigger = "button"
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected an unknown trigger to be rejected")
+	}
+}
+
+func TestParseTriggerHotkey(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected triggerHotkey
+		valid    bool
+		name     string
+	}{
+		{"ctrl+alt+n", triggerHotkey{ctrl: true, alt: true, key: triggerHotkeyKeys["n"]}, true, "letter"},
+		{"Super + F12", triggerHotkey{super: true, key: triggerHotkeyKeys["f12"]}, true, "case and spaces ignored"},
+		{"ctrl+shift+5", triggerHotkey{ctrl: true, shift: true, key: triggerHotkeyKeys["5"]}, true, "digit"},
+		{"shift+a", triggerHotkey{}, false, "shift only"},
+		{"n", triggerHotkey{}, false, "no modifier"},
+		{"ctrl+hyper+n", triggerHotkey{}, false, "unknown modifier"},
+		{"ctrl+enter", triggerHotkey{}, false, "unknown key"},
+		{"", triggerHotkey{}, false, "empty"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hotkey, err := parseTriggerHotkey(test.spec)
+			if (err == nil) != test.valid {
+				t.Fatalf("Expected valid %v, got %v", test.valid, err)
+			}
+			if test.valid && hotkey != test.expected {
+				t.Errorf("Expected %+v, got %+v", test.expected, hotkey)
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.NFC.Trigger = "hotkey"
+	if err := validateConfig(config); (err == nil) != triggerHotkeyAvailable {
+		t.Errorf("Expected trigger hotkey valid %v on %s, got %v", triggerHotkeyAvailable, runtime.GOOS, err)
+	}
+	config.NFC.TriggerHotkey = "n"
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected a hotkey without modifier to be rejected")
+	}
+}
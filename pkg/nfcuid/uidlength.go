@@ -0,0 +1,90 @@
+package nfcuid
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxUIDPadBytes is the largest nfc.uid_pad_bytes, the triple size UID of ISO 14443
+const maxUIDPadBytes = 10
+
+// defaultMinUIDBytes is the default nfc.min_uid_bytes, the single size UID of ISO 14443
+const defaultMinUIDBytes = 4
+
+// uidByteSelections lists the nfc.uid_bytes options: the whole UID, or its first or
+// last 4 bytes so mixed 4 and 7 byte card stock gives outputs of one length
+var uidByteSelections = []string{"all", "first4", "last4"}
+
+// IsValidUIDBytes reports whether selection is one of uidByteSelections
+func IsValidUIDBytes(selection string) bool {
+	for _, option := range uidByteSelections {
+		if option == selection {
+			return true
+		}
+	}
+	return false
+}
+
+// parseUIDByteRange parses an nfc.uid_byte_range "first:last", the positions of the
+// first and last byte to output counted from 1. An empty range returns 0, 0.
+func parseUIDByteRange(byteRange string) (int, int, error) {
+	if byteRange == "" {
+		return 0, 0, nil
+	}
+	parts := strings.Split(byteRange, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("uid byte range must be first:last, got: %s", byteRange)
+	}
+	first, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid first byte in uid byte range %s: %v", byteRange, err)
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid last byte in uid byte range %s: %v", byteRange, err)
+	}
+	if first < 1 || last < first || last > maxUIDPadBytes {
+		return 0, 0, fmt.Errorf("uid byte range must satisfy 1 <= first <= last <= %d, got: %s", maxUIDPadBytes, byteRange)
+	}
+	return first, last, nil
+}
+
+// normalizeUID applies the UID length rules to the UID as read from the card, before
+// reverse and the other format flags: uid_byte_range or uid_bytes first pick a part of
+// longer UIDs, then uid_pad_bytes adds leading zero bytes to shorter UIDs. UIDs longer
+// than the pad length are kept whole. The result is a new slice.
+func (flags Flags) normalizeUID(uid []byte) []byte {
+	selected := uid
+	if first, last, _ := parseUIDByteRange(flags.UIDByteRange); last > len(uid) {
+		logWarnf("UID has %d bytes, uid_byte_range %s is out of range, typing the whole UID", len(uid), flags.UIDByteRange)
+	} else if last > 0 {
+		selected = uid[first-1 : last]
+	}
+	if len(uid) > 4 {
+		switch flags.UIDBytes {
+		case "first4":
+			selected = uid[:4]
+		case "last4":
+			selected = uid[len(uid)-4:]
+		}
+	}
+
+	width := len(selected)
+	if flags.UIDPadBytes > width {
+		width = flags.UIDPadBytes
+	}
+	normalized := make([]byte, width)
+	copy(normalized[width-len(selected):], selected)
+	return normalized
+}
+
+// checkUIDLength rejects a UID shorter than nfc.min_uid_bytes, as glitchy reads return
+// truncated UIDs that would otherwise be typed
+func (s *service) checkUIDLength(uid []byte) error {
+	if len(uid) >= s.config.NFC.MinUIDBytes {
+		return nil
+	}
+	logWarnf("Rejected short UID read: %d bytes [% x]", len(uid), uid)
+	return fmt.Errorf("UID has %d bytes, at least %d expected", len(uid), s.config.NFC.MinUIDBytes)
+}
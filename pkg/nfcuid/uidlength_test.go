@@ -0,0 +1,206 @@
+package nfcuid
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestFormatOutputUIDLength(t *testing.T) {
+	uid4 := []byte{0x04, 0xa2, 0x2b, 0x91}
+	uid7 := []byte{0x04, 0xae, 0x65, 0xca, 0x82, 0x49, 0x80}
+
+	tests := []struct {
+		uid      []byte
+		uidBytes string
+		padBytes int
+		decimal  bool
+		reverse  bool
+		expected string
+		name     string
+	}{
+		{uid7, "all", 0, false, false, "04ae65ca824980", "whole uid"},
+		{uid7, "first4", 0, false, false, "04ae65ca", "first 4 bytes"},
+		{uid7, "last4", 0, false, false, "ca824980", "last 4 bytes"},
+		{uid4, "last4", 0, false, false, "04a22b91", "short uid unchanged"},
+		{uid7, "last4", 0, true, false, "2152301258", "decimal of last 4 bytes"},
+		{uid7, "first4", 0, false, true, "ca65ae04", "selection before reverse"},
+		{uid4, "all", 7, false, false, "00000004a22b91", "padded to 7 bytes"},
+		{uid7, "all", 7, false, false, "04ae65ca824980", "already at pad length"},
+		{uid7, "all", 4, false, false, "04ae65ca824980", "longer uid kept whole"},
+		{uid4, "all", 7, false, true, "912ba204000000", "padding before reverse"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.UIDBytes = test.uidBytes
+			config.NFC.UIDPadBytes = test.padBytes
+			config.NFC.Decimal = test.decimal
+			config.NFC.Reverse = test.reverse
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			uid := append([]byte(nil), test.uid...)
+			if result := s.formatOutput(uid, "Reader 0"); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestValidateConfigUIDLength(t *testing.T) {
+	tests := []struct {
+		uidBytes string
+		padBytes int
+		decimal  bool
+		valid    bool
+		name     string
+	}{
+		{"first4", 0, true, true, "selection with decimal"},
+		{"middle4", 0, false, false, "unknown selection"},
+		{"all", -1, false, false, "negative padding"},
+		{"all", 11, false, false, "padding beyond 10 bytes"},
+		{"all", 4, true, true, "padding to 4 bytes with decimal"},
+		{"all", 7, true, false, "padding to 7 bytes with decimal"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.UIDBytes = test.uidBytes
+			config.NFC.UIDPadBytes = test.padBytes
+			config.NFC.Decimal = test.decimal
+			if err := validateConfig(config); (err == nil) != test.valid {
+				t.Errorf("Expected valid %v, got error %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestReadNextCardMinUIDBytes(t *testing.T) {
+	tests := []struct {
+		responses [][]byte
+		minBytes  int
+		expected  string
+		name      string
+	}{
+		{[][]byte{{0x04, 0x90, 0x00}, {0x04, 0x90, 0x00}}, 4, "", "1 byte rejected"},
+		{[][]byte{{0x90, 0x00}, {0x90, 0x00}}, 4, "", "empty rejected"},
+		{[][]byte{{0x04, 0x90, 0x00}, {0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00}}, 4, "04a22b91", "retry reads the whole uid"},
+		{[][]byte{{0x04, 0x90, 0x00}}, 0, "04", "filter off"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			card := &mockCard{responses: test.responses}
+			ctx := &mockContext{
+				readers: []string{"Reader 0"},
+				states:  map[string][]scard.StateFlag{"Reader 0": {scard.StatePresent, scard.StateEmpty}},
+				cards:   map[scard.Protocol]*mockCard{scard.ProtocolAny: card},
+			}
+			config := DefaultConfig()
+			config.NFC.MinUIDBytes = test.minBytes
+			config.Advanced.CardReadAttempts = 2
+			config.Advanced.CardReadDelayMs = 0
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			kb := &mockKeyboard{}
+			s.readNextCard(ctx, ctx.readers, kb)
+			if result := kb.text(); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+			if message := s.statusManager.GetStatus().LastErrorMessage; test.expected == "" && !strings.Contains(message, "at least 4 expected") {
+				t.Errorf("Expected the short read to be recorded as an error, got %q", message)
+			}
+		})
+	}
+}
+
+func TestFormatOutputUIDByteRange(t *testing.T) {
+	uid4 := []byte{0x04, 0xa2, 0x2b, 0x91}
+	uid7 := []byte{0x04, 0xae, 0x65, 0xca, 0x82, 0x49, 0x80}
+
+	tests := []struct {
+		uid       []byte
+		byteRange string
+		padBytes  int
+		decimal   bool
+		reverse   bool
+		expected  string
+		name      string
+	}{
+		{uid7, "2:5", 0, false, false, "ae65ca82", "bytes 2 to 5"},
+		{uid7, "1:4", 0, false, false, "04ae65ca", "first 4 bytes"},
+		{uid7, "7:7", 0, false, false, "80", "single byte"},
+		{uid7, "2:5", 0, false, true, "82ca65ae", "range before reverse"},
+		{uid7, "2:5", 0, true, false, "2194302382", "decimal of the range"},
+		{uid7, "2:5", 0, true, true, "2925906562", "decimal of the reversed range"},
+		{uid7, "2:3", 4, false, false, "0000ae65", "padded after the range"},
+		{uid4, "2:5", 0, false, false, "04a22b91", "short uid typed whole"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.UIDByteRange = test.byteRange
+			config.NFC.UIDPadBytes = test.padBytes
+			config.NFC.Decimal = test.decimal
+			config.NFC.Reverse = test.reverse
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+
+			uid := append([]byte(nil), test.uid...)
+			if result := s.formatOutput(uid, "Reader 0"); result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+			if !bytes.Equal(uid, test.uid) {
+				t.Errorf("Expected the read UID to be left unchanged, got % x", uid)
+			}
+		})
+	}
+}
+
+func TestValidateConfigUIDByteRange(t *testing.T) {
+	tests := []struct {
+		byteRange string
+		uidBytes  string
+		decimal   bool
+		valid     bool
+		name      string
+	}{
+		{"", "first4", true, true, "no range"},
+		{"2:5", "all", true, true, "4 bytes with decimal"},
+		{" 2 : 5 ", "all", false, true, "spaces"},
+		{"1:7", "all", false, true, "7 bytes"},
+		{"1:7", "all", true, false, "7 bytes with decimal"},
+		{"2:5", "last4", false, false, "combined with uid_bytes"},
+		{"0:4", "all", false, false, "counted from 0"},
+		{"5:2", "all", false, false, "last before first"},
+		{"1:11", "all", false, false, "beyond 10 bytes"},
+		{"2", "all", false, false, "single number"},
+		{"2-5", "all", false, false, "hyphen"},
+		{"a:b", "all", false, false, "not numbers"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.UIDByteRange = test.byteRange
+			config.NFC.UIDBytes = test.uidBytes
+			config.NFC.Decimal = test.decimal
+			if err := validateConfig(config); (err == nil) != test.valid {
+				t.Errorf("Expected valid %v, got error %v", test.valid, err)
+			}
+		})
+	}
+}
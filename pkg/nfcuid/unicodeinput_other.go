@@ -0,0 +1,11 @@
+//go:build !windows
+
+package nfcuid
+
+// unicodeInputAvail reports whether nfc.windows_unicode_input is supported on this platform
+const unicodeInputAvail = false
+
+// newUnicodeKeyboard returns the keyboard unchanged, typing by codepoint needs SendInput
+func newUnicodeKeyboard(kb keyboard) keyboard {
+	return kb
+}
@@ -0,0 +1,63 @@
+package nfcuid
+
+import (
+	"fmt"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var sendInput = user32.NewProc("SendInput")
+
+const (
+	inputKeyboard    = 1      // INPUT_KEYBOARD
+	keyeventfKeyUp   = 0x0002 // KEYEVENTF_KEYUP
+	keyeventfUnicode = 0x0004 // KEYEVENTF_UNICODE
+)
+
+// unicodeInputAvail reports whether nfc.windows_unicode_input is supported on this platform
+const unicodeInputAvail = true
+
+// keybdInput mirrors the Win32 KEYBDINPUT structure
+type keybdInput struct {
+	wVk         uint16
+	wScan       uint16
+	dwFlags     uint32
+	time        uint32
+	dwExtraInfo uintptr
+}
+
+// keyboardInput mirrors the Win32 INPUT structure for keyboard input, padded to the
+// size of its largest union member (MOUSEINPUT)
+type keyboardInput struct {
+	inputType uint32
+	ki        keybdInput
+	padding   uint64
+}
+
+// unicodeKeyboard types printable characters with SendInput and KEYEVENTF_UNICODE, so
+// they don't depend on the active keyboard layout, other keys go through the wrapped keyboard
+type unicodeKeyboard struct {
+	keyboard
+}
+
+// newUnicodeKeyboard wraps the keyboard to type characters by codepoint
+func newUnicodeKeyboard(kb keyboard) keyboard {
+	return &unicodeKeyboard{kb}
+}
+
+// TypeUnicode presses and releases the character, as a surrogate pair outside the BMP
+func (k *unicodeKeyboard) TypeUnicode(r rune) error {
+	var inputs []keyboardInput
+	for _, unit := range utf16.Encode([]rune{r}) {
+		inputs = append(inputs,
+			keyboardInput{inputType: inputKeyboard, ki: keybdInput{wScan: unit, dwFlags: keyeventfUnicode}},
+			keyboardInput{inputType: inputKeyboard, ki: keybdInput{wScan: unit, dwFlags: keyeventfUnicode | keyeventfKeyUp}},
+		)
+	}
+
+	sent, _, err := sendInput.Call(uintptr(len(inputs)), uintptr(unsafe.Pointer(&inputs[0])), unsafe.Sizeof(inputs[0]))
+	if int(sent) != len(inputs) {
+		return fmt.Errorf("SendInput typed %d of %d events for %q: %v", sent, len(inputs), r, err)
+	}
+	return nil
+}
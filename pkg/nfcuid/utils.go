@@ -0,0 +1,1117 @@
+package nfcuid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gen2brain/beeep"
+	mp3 "github.com/hajimehoshi/go-mp3"
+	"github.com/skratchdot/open-golang/open"
+)
+
+// exitOnce makes sure cleanup and exit in SafeExit run only once
+var exitOnce sync.Once
+
+// shutdownCtx is cancelled when a shutdown signal is received, so blocking
+// prompts can stop waiting for input and exit through SafeExit
+var shutdownCtx, cancelShutdown = context.WithCancel(context.Background())
+
+// RegisterSingleInstance registers the acquired instance lock so Shutdown releases it
+func RegisterSingleInstance(singleInstance *SingleInstance) {
+	RegisterShutdown("instance lock", ShutdownLock, func() error {
+		singleInstance.Release()
+		return nil
+	})
+}
+
+// NotificationManager handles system notifications with throttling
+type NotificationManager struct {
+	enabled           bool
+	showSuccess       bool
+	showErrors        bool
+	lastNotifications map[string]time.Time // Track last notification time per error type
+	errorCounts       map[string]int       // Track consecutive error counts per type
+	autoRestart       bool                 // Process was started by a self-restart
+	cooldownUntil     time.Time            // Error notifications are held back until this time
+	maxSendFailures   int                  // Consecutive delivery failures before suspending (0 = never)
+	sendFailures      int                  // Current run of consecutive delivery failures
+	suspended         bool                 // Desktop notifications suspended after repeated failures
+	lastProbe         time.Time            // Last delivery attempt while suspended
+	templates         NotificationTemplates
+	mu                sync.Mutex               // Guards device, set by the reading loop while other goroutines notify
+	device            string                   // Selected reader(s), for the {device} placeholder
+	throttleJitter    time.Duration            // Maximum random extension of the throttle windows (0 = none)
+	jitters           map[string]time.Duration // Current throttle window extension per error type
+	randInt63n        func(n int64) int64      // Random value in [0, n), replaceable in tests
+	categories        map[string]bool          // Per-category notification switch, missing = on
+}
+
+// Built-in notification texts, used when the template is empty
+const (
+	defaultSuccessTitle     = "NFC Karten-Lesung erfolgreich"
+	defaultSuccessBody      = "Card UID: {uid}"
+	defaultErrorTitle       = "NFC Reader-Fehler"
+	defaultSystemErrorTitle = "NFC System-Fehler"
+	defaultErrorBody        = "{message}"
+	defaultInfoTitle        = "{title}"
+	defaultInfoBody         = "{message}"
+)
+
+// notifyFunc and alertFunc deliver desktop notifications, replaceable in tests
+var (
+	notifyFunc = beeep.Notify
+	alertFunc  = beeep.Alert
+)
+
+// notificationProbeInterval is how often a suspended notification manager retries delivery
+const notificationProbeInterval = 5 * time.Minute
+
+// NewNotificationManager creates a new notification manager
+func NewNotificationManager(config *Config) *NotificationManager {
+	nm := &NotificationManager{
+		enabled:           config.Notifications.Enabled,
+		showSuccess:       config.Notifications.ShowSuccess,
+		showErrors:        config.Notifications.ShowErrors,
+		lastNotifications: make(map[string]time.Time),
+		errorCounts:       make(map[string]int),
+		autoRestart:       config.AutoRestart,
+		maxSendFailures:   config.Notifications.MaxSendFailures,
+		templates:         config.Notifications.Templates,
+		throttleJitter:    time.Duration(config.Notifications.ThrottleJitter) * time.Second,
+		jitters:           make(map[string]time.Duration),
+		randInt63n:        rand.Int63n,
+		categories:        config.Notifications.Categories,
+	}
+
+	// After a self-restart, give the fresh process a grace period before error
+	// notifications resume so a flapping reader doesn't produce a toast storm
+	if nm.autoRestart && config.Notifications.RestartCooldown > 0 {
+		nm.cooldownUntil = time.Now().Add(time.Duration(config.Notifications.RestartCooldown) * time.Second)
+	}
+
+	return nm
+}
+
+// IsAutoRestart reports whether the process was started by a self-restart
+func (nm *NotificationManager) IsAutoRestart() bool {
+	return nm.autoRestart
+}
+
+// inCooldown checks if error notifications are currently held back after a self-restart
+func (nm *NotificationManager) inCooldown() bool {
+	return !nm.cooldownUntil.IsZero() && time.Now().Before(nm.cooldownUntil)
+}
+
+// SetDevice sets the reader name(s) used for the {device} placeholder
+func (nm *NotificationManager) SetDevice(device string) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	nm.device = device
+}
+
+// currentDevice returns the reader name(s) for the {device} placeholder
+func (nm *NotificationManager) currentDevice() string {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	return nm.device
+}
+
+// expandNotificationTemplate replaces the placeholders in a notification template,
+// using the fallback text if the template is empty
+func expandNotificationTemplate(template, fallback, uid, device, message, title string) string {
+	if template == "" {
+		template = fallback
+	}
+	return strings.NewReplacer("{uid}", uid, "{device}", device, "{message}", message, "{title}", title).Replace(template)
+}
+
+// successTexts returns the title and body of a success notification
+func (nm *NotificationManager) successTexts(uid, device string) (string, string) {
+	return expandNotificationTemplate(nm.templates.SuccessTitle, defaultSuccessTitle, uid, device, "", ""),
+		expandNotificationTemplate(nm.templates.SuccessBody, defaultSuccessBody, uid, device, "", "")
+}
+
+// errorTexts returns the title and body of an error notification, with the repeat count in the title
+func (nm *NotificationManager) errorTexts(fallbackTitle, message string, count int) (string, string) {
+	device := nm.currentDevice()
+	title := expandNotificationTemplate(nm.templates.ErrorTitle, fallbackTitle, "", device, message, "")
+	if count > 1 {
+		title = fmt.Sprintf("%s (x%d)", title, count)
+	}
+	return title, expandNotificationTemplate(nm.templates.ErrorBody, defaultErrorBody, "", device, message, "")
+}
+
+// infoTexts returns the title and body of an informational notification
+func (nm *NotificationManager) infoTexts(title, message string) (string, string) {
+	device := nm.currentDevice()
+	return expandNotificationTemplate(nm.templates.InfoTitle, defaultInfoTitle, "", device, message, title),
+		expandNotificationTemplate(nm.templates.InfoBody, defaultInfoBody, "", device, message, title)
+}
+
+// NotifySuccess sends a success notification for the card read on the device (only when
+// transitioning from error state). The success sound is played by the AudioManager,
+// independent of this.
+func (nm *NotificationManager) NotifySuccess(uid, device string) {
+	if !nm.enabled {
+		return
+	}
+
+	// Only notify success if we had previous errors (recovering from error state)
+	if nm.hasRecentErrors() {
+		if nm.showSuccess {
+			title, body := nm.successTexts(uid, device)
+			nm.send(notifyFunc, "success", title, body)
+		}
+
+		// Clear error counts on successful operation, also without the success
+		// notification so error titles and throttling start over
+		nm.clearErrorCounts()
+	}
+}
+
+// NotifyError sends an error notification with smart throttling
+func (nm *NotificationManager) NotifyError(message string) {
+	if !nm.enabled || !nm.showErrors {
+		return
+	}
+
+	errorType := nm.categorizeError(message)
+
+	if nm.inCooldown() {
+		log.Printf("Error notification suppressed during restart cooldown: %s", message)
+	} else if nm.shouldNotifyError(errorType, message) {
+		title, body := nm.errorTexts(defaultErrorTitle, message, nm.errorCounts[errorType])
+		nm.send(alertFunc, "error", title, body)
+
+		nm.recordNotification(errorType)
+	}
+
+	nm.errorCounts[errorType]++
+}
+
+// NotifyErrorThrottled sends throttled error notifications for system failures
+func (nm *NotificationManager) NotifyErrorThrottled(errorType, message string) {
+	if !nm.enabled || !nm.showErrors {
+		return
+	}
+
+	if nm.inCooldown() {
+		log.Printf("Error notification suppressed during restart cooldown: %s", message)
+	} else if nm.shouldNotifyError(errorType, message) {
+		title, body := nm.errorTexts(defaultSystemErrorTitle, message, nm.errorCounts[errorType])
+		nm.send(alertFunc, "error", title, body)
+
+		nm.recordNotification(errorType)
+	}
+
+	nm.errorCounts[errorType]++
+}
+
+// NotifyInfo sends an informational notification
+func (nm *NotificationManager) NotifyInfo(title, message string) {
+	if !nm.enabled {
+		return
+	}
+
+	title, body := nm.infoTexts(title, message)
+	nm.send(notifyFunc, "info", title, body)
+}
+
+// send delivers a desktop notification and tracks consecutive delivery failures.
+// After too many failures in a row (e.g. no notification daemon on a headless Linux
+// install) desktop notifications are suspended for the session and messages only go
+// to the log. A probe is sent now and then, and a successful one re-enables them.
+func (nm *NotificationManager) send(notify func(title, message, appIcon string) error, kind, title, message string) {
+	if nm.suspended {
+		if time.Since(nm.lastProbe) < notificationProbeInterval {
+			log.Printf("%s: %s", title, message)
+			return
+		}
+		nm.lastProbe = time.Now()
+	}
+
+	if err := notify(title, message, ""); err != nil {
+		nm.sendFailures++
+		if nm.suspended {
+			log.Printf("%s: %s", title, message)
+			return
+		}
+
+		log.Printf("Failed to send %s notification: %v", kind, err)
+		if nm.maxSendFailures > 0 && nm.sendFailures >= nm.maxSendFailures {
+			nm.suspended = true
+			nm.lastProbe = time.Now()
+			log.Printf("Desktop notifications disabled after %d consecutive failures, logging to console only", nm.sendFailures)
+		}
+		return
+	}
+
+	if nm.suspended {
+		log.Printf("Desktop notifications are working again, re-enabling")
+		nm.suspended = false
+	}
+	nm.sendFailures = 0
+}
+
+// SendTestNotification sends a sample notification regardless of the enabled setting
+// and returns the underlying error so installers can diagnose the notification stack
+func (nm *NotificationManager) SendTestNotification() error {
+	return beeep.Notify("NFC Lesegerät", "Testbenachrichtigung - Benachrichtigungen funktionieren", "")
+}
+
+// BrowserManager handles browser operations
+type BrowserManager struct {
+	fullscreen bool
+}
+
+// NewBrowserManager creates a new browser manager
+func NewBrowserManager(fullscreen bool) *BrowserManager {
+	return &BrowserManager{
+		fullscreen: fullscreen,
+	}
+}
+
+// OpenURL opens the specified URL in the default browser
+func (bm *BrowserManager) OpenURL(url string) error {
+	fmt.Printf("Opening browser at: %s\n", url)
+
+	if bm.fullscreen {
+		return bm.openFullscreen(url)
+	}
+
+	return bm.openMaximized(url)
+}
+
+// openMaximized opens URL in maximized browser window
+func (bm *BrowserManager) openMaximized(url string) error {
+	switch runtime.GOOS {
+	case "windows":
+		// On Windows, try to open maximized
+		cmd := exec.Command("cmd", "/c", "start", "/max", url)
+		return cmd.Start()
+	case "darwin":
+		// On macOS, open normally first, then try to maximize
+		if err := open.Run(url); err != nil {
+			return err
+		}
+		// Give browser time to load
+		time.Sleep(500 * time.Millisecond)
+		// Try to maximize using AppleScript
+		script := `tell application "System Events" to tell process "Safari" to set frontmost to true`
+		exec.Command("osascript", "-e", script).Run()
+		return nil
+	case "linux":
+		// On Linux, open normally - window managers handle maximization differently
+		return open.Run(url)
+	default:
+		return open.Run(url)
+	}
+}
+
+// openFullscreen opens URL in fullscreen browser window
+func (bm *BrowserManager) openFullscreen(url string) error {
+	switch runtime.GOOS {
+	case "windows":
+		// Windows: Use Chrome with kiosk mode if available, fallback to maximized
+		chromeCmd := exec.Command("chrome", "--kiosk", url)
+		if err := chromeCmd.Start(); err != nil {
+			// Fallback to Edge kiosk mode
+			edgeCmd := exec.Command("msedge", "--kiosk", url)
+			if err := edgeCmd.Start(); err != nil {
+				// Fallback to maximized window
+				return bm.openMaximized(url)
+			}
+		}
+		return nil
+	case "darwin":
+		// macOS: Try Chrome kiosk mode, fallback to Safari fullscreen
+		chromeCmd := exec.Command("/Applications/Google Chrome.app/Contents/MacOS/Google Chrome", "--kiosk", url)
+		if err := chromeCmd.Start(); err != nil {
+			// Fallback to Safari with fullscreen AppleScript
+			if err := open.Run(url); err != nil {
+				return err
+			}
+			time.Sleep(1 * time.Second)
+			script := `
+				tell application "Safari"
+					activate
+					tell application "System Events"
+						key code 36 using {command down, control down}
+					end tell
+				end tell`
+			exec.Command("osascript", "-e", script).Run()
+		}
+		return nil
+	case "linux":
+		// Linux: Try various browsers with fullscreen flags
+		browsers := [][]string{
+			{"google-chrome", "--kiosk", url},
+			{"chromium-browser", "--kiosk", url},
+			{"firefox", "--kiosk", url},
+		}
+
+		for _, browserCmd := range browsers {
+			cmd := exec.Command(browserCmd[0], browserCmd[1:]...)
+			if err := cmd.Start(); err == nil {
+				return nil
+			}
+		}
+
+		// Fallback to default browser
+		if err := open.Run(url); err != nil {
+			return err
+		}
+
+		// Try to make it fullscreen using xdotool if available
+		time.Sleep(1 * time.Second)
+		exec.Command("xdotool", "key", "F11").Run()
+		return nil
+	default:
+		return open.Run(url)
+	}
+}
+
+// RetryManager handles retry logic with exponential backoff
+type RetryManager struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	logf        func(format string, args ...interface{}) // Retry messages, nil prints to stdout
+}
+
+// NewRetryManager creates a new retry manager
+func NewRetryManager(maxAttempts int, baseDelaySeconds int) *RetryManager {
+	return NewRetryManagerWithDelay(maxAttempts, time.Duration(baseDelaySeconds)*time.Second)
+}
+
+// NewRetryManagerWithDelay creates a new retry manager with a sub-second base delay
+func NewRetryManagerWithDelay(maxAttempts int, baseDelay time.Duration) *RetryManager {
+	return &RetryManager{
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+	}
+}
+
+// SetLogger routes the retry messages to logf, e.g. logWarnf, instead of stdout
+func (rm *RetryManager) SetLogger(logf func(format string, args ...interface{})) {
+	rm.logf = logf
+}
+
+// permanentError marks an error that another attempt can't fix, e.g. a wrong key
+type permanentError struct {
+	err error
+}
+
+// Error returns the message of the wrapped error
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error for errors.Is
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// permanent wraps err so Retry returns it at once instead of trying again
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Retry executes the given function with retry logic
+func (rm *RetryManager) Retry(operation func() error) error {
+	var lastErr error
+
+	for attempt := 1; attempt <= rm.maxAttempts; attempt++ {
+		err := operation()
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+
+		if attempt < rm.maxAttempts {
+			delay := time.Duration(attempt) * rm.baseDelay
+			if rm.logf != nil {
+				rm.logf("Attempt %d failed: %v. Retrying in %v...", attempt, err, delay)
+			} else {
+				fmt.Printf("Attempt %d failed: %v. Retrying in %v...\n", attempt, err, delay)
+			}
+			time.Sleep(delay)
+		}
+	}
+
+	return fmt.Errorf("operation failed after %d attempts, last error: %w", rm.maxAttempts, lastErr)
+}
+
+// SafeExit performs a graceful shutdown through Shutdown and exits
+func SafeExit(code int, message string, notificationManager *NotificationManager) {
+	// Only the first caller cleans up and exits, e.g. when Ctrl+C arrives while
+	// the main goroutine is already shutting down
+	exitOnce.Do(func() {
+		if message != "" {
+			fmt.Println(message)
+			if notificationManager != nil {
+				notificationManager.NotifyError(message)
+			}
+		}
+
+		// Stop scanning, close the logs and release the instance lock
+		Shutdown()
+
+		os.Exit(code)
+	})
+}
+
+// restartModes lists the advanced.restart_mode options: self starts the new process
+// itself, supervised exits with advanced.supervised_exit_code for a supervisor to restart it
+var restartModes = []string{"self", "supervised"}
+
+// IsValidRestartMode reports whether mode is one of restartModes
+func IsValidRestartMode(mode string) bool {
+	for _, option := range restartModes {
+		if option == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// RestartManager handles application self-restart functionality
+type RestartManager struct {
+	mu                  sync.Mutex // The watchdog tracks failures from its own goroutine
+	config              *Config
+	notificationManager *NotificationManager
+	eventLogger         *EventLogger
+	contextFailureCount int
+	pcscdRestarted      bool                                 // restart_pcscd was tried in this failure streak
+	goos                string                               // runtime.GOOS, replaceable in tests
+	runCommand          func(command string) ([]byte, error) // runs the pcscd restart command
+	exit                func(code int)                       // exits for restart_mode supervised
+}
+
+// NewRestartManager creates a new restart manager
+func NewRestartManager(config *Config, notificationManager *NotificationManager, eventLogger *EventLogger) *RestartManager {
+	return &RestartManager{
+		config:              config,
+		notificationManager: notificationManager,
+		eventLogger:         eventLogger,
+		contextFailureCount: 0,
+		goos:                runtime.GOOS,
+		runCommand:          runPcscdRestartCommand,
+		exit:                func(code int) { SafeExit(code, "", nil) },
+	}
+}
+
+// TrackContextFailure increments the context failure counter and triggers restart if threshold reached
+func (rm *RestartManager) TrackContextFailure(err error) bool {
+	return rm.trackSystemFailure("PC/SC Context", err)
+}
+
+// TrackSystemFailure increments the system failure counter and triggers restart if threshold reached
+// Use this for any PC/SC system-level errors (readers, connections, system operations)
+func (rm *RestartManager) TrackSystemFailure(operation string, err error) bool {
+	return rm.trackSystemFailure(operation, err)
+}
+
+// trackSystemFailure is the internal implementation for tracking any PC/SC system failure
+func (rm *RestartManager) trackSystemFailure(operation string, err error) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.contextFailureCount++
+
+	fmt.Printf("PC/SC %s failure %d/%d: %v\n", operation, rm.contextFailureCount, rm.config.Advanced.MaxContextFailures, err)
+
+	if rm.contextFailureCount >= rm.config.Advanced.MaxContextFailures {
+		switch rm.escalation() {
+		case escalatePcscd:
+			rm.restartPcscd(operation)
+			return false
+		case escalateSelfRestart:
+			rm.performSelfRestart(operation)
+			return true // This will never actually return due to restart, but for clarity
+		default:
+			if rm.contextFailureCount == rm.config.Advanced.MaxContextFailures {
+				fmt.Println("Self-restart is disabled, not restarting the application")
+			}
+			return false
+		}
+	}
+
+	return false
+}
+
+// ResetFailureCount resets the context failure counter (called on successful context establishment)
+func (rm *RestartManager) ResetFailureCount() {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.contextFailureCount > 0 || rm.pcscdRestarted {
+		fmt.Printf("PC/SC Context established successfully, resetting failure count\n")
+		rm.contextFailureCount = 0
+		rm.pcscdRestarted = false
+	}
+}
+
+// performSelfRestart performs the actual application restart, or leaves it to the
+// supervisor with restart_mode supervised
+func (rm *RestartManager) performSelfRestart(operation string) {
+	if rm.config.Advanced.RestartMode == "supervised" {
+		rm.exitForSupervisor(operation)
+		return
+	}
+
+	message := fmt.Sprintf("Maximale PC/SC %s Fehler erreicht (%d). Anwendung wird neu gestartet...", operation, rm.config.Advanced.MaxContextFailures)
+	fmt.Println(message)
+	rm.eventLogger.Log(Event{Type: EventRestart, Operation: operation, DelaySeconds: rm.config.Advanced.RestartDelay})
+
+	if rm.notificationManager != nil {
+		rm.notificationManager.NotifyInfo("NFC Lesegerät", message)
+	}
+
+	// Give time for notifications to be displayed
+	time.Sleep(2 * time.Second)
+
+	if rm.config.Advanced.RestartDelay > 0 {
+		fmt.Printf("Waiting %d seconds before restart...\n", rm.config.Advanced.RestartDelay)
+		time.Sleep(time.Duration(rm.config.Advanced.RestartDelay) * time.Second)
+	}
+
+	// Get the current executable path and arguments
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Failed to get executable path for restart: %v\n", err)
+		SafeExit(1, "Cannot restart application", rm.notificationManager)
+		return
+	}
+
+	// Get original arguments (excluding the program name) and add restart flag
+	args := os.Args[1:]
+	args = append(args, "--auto-restart")
+
+	fmt.Printf("Restarting application: %s %v\n", executable, args)
+
+	// Start new process
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	err = cmd.Start()
+	if err != nil {
+		errorMsg := fmt.Sprintf("Failed to restart application: %v", err)
+		fmt.Println(errorMsg)
+		if rm.notificationManager != nil {
+			rm.notificationManager.NotifyError(errorMsg)
+		}
+		SafeExit(1, "Restart failed", rm.notificationManager)
+		return
+	}
+
+	// Notify about successful restart initiation
+	if rm.notificationManager != nil {
+		rm.notificationManager.NotifyInfo("NFC Lesegerät", "Anwendungsneustart erfolgreich eingeleitet")
+	}
+
+	fmt.Println("New process started successfully. Exiting current instance.")
+	os.Exit(0)
+}
+
+// exitForSupervisor exits with the supervised exit code instead of starting a new
+// process, so a supervisor like systemd or NSSM restarts the application without racing
+// a child started by us. The supervisor handles the restart delay.
+func (rm *RestartManager) exitForSupervisor(operation string) {
+	code := rm.config.Advanced.SupervisedExit
+	message := fmt.Sprintf("Maximale PC/SC %s Fehler erreicht (%d). Anwendung wird beendet und vom Dienst neu gestartet...", operation, rm.config.Advanced.MaxContextFailures)
+	fmt.Println(message)
+	log.Printf("Exiting with code %d, the supervisor should restart the application", code)
+	rm.eventLogger.Log(Event{Type: EventRestart, Operation: operation})
+
+	if rm.notificationManager != nil {
+		rm.notificationManager.NotifyInfo("NFC Lesegerät", message)
+	}
+
+	rm.exit(code)
+}
+
+// notificationCategories lists the error categories of categorizeError, which can be
+// switched off one by one with notifications.categories
+var notificationCategories = []string{
+	"pc-sc-context", "reader-error", "card-error", "keyboard-error", "browser-error", "service-error", "general-error",
+}
+
+// isNotificationCategory reports whether name is one of notificationCategories
+func isNotificationCategory(name string) bool {
+	for _, category := range notificationCategories {
+		if category == name {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationCategory maps an error type to its notification category. The finer
+// types passed to NotifyErrorThrottled fall under the category they belong to.
+func notificationCategory(errorType string) string {
+	switch {
+	case isNotificationCategory(errorType):
+		return errorType
+	case strings.HasPrefix(errorType, "card-"):
+		return "card-error"
+	case strings.HasPrefix(errorType, "keyboard-"):
+		return "keyboard-error"
+	case strings.HasPrefix(errorType, "reader-"):
+		return "reader-error"
+	case errorType == "service-outage" || errorType == "watchdog":
+		return "service-error"
+	default:
+		return "general-error"
+	}
+}
+
+// categorizeError categorizes error messages into types for throttling
+func (nm *NotificationManager) categorizeError(message string) string {
+	switch {
+	case strings.Contains(message, "PC/SC Context"):
+		return "pc-sc-context"
+	case strings.Contains(message, "Reader"):
+		return "reader-error"
+	case strings.Contains(message, "Card"):
+		return "card-error"
+	case strings.Contains(message, "Keyboard"):
+		return "keyboard-error"
+	case strings.Contains(message, "Browser"):
+		return "browser-error"
+	case strings.Contains(message, "Service"):
+		return "service-error"
+	default:
+		return "general-error"
+	}
+}
+
+// shouldNotifyError determines if an error notification should be sent based on throttling rules
+func (nm *NotificationManager) shouldNotifyError(errorType, message string) bool {
+	return nm.shouldNotifyErrorAt(errorType, time.Now())
+}
+
+// shouldNotifyErrorAt applies the throttling rules at the given time. The time based
+// windows are extended by the jitter drawn for the error type, so machines that hit
+// the same outage spread their repeated notifications.
+func (nm *NotificationManager) shouldNotifyErrorAt(errorType string, now time.Time) bool {
+	if enabled, ok := nm.categories[notificationCategory(errorType)]; ok && !enabled {
+		return false
+	}
+
+	// Always notify first occurrence of any error type
+	lastNotification, exists := nm.lastNotifications[errorType]
+	if !exists {
+		return true
+	}
+	lastNotification = lastNotification.Add(nm.jitters[errorType])
+
+	// Get error count for this type
+	count := nm.errorCounts[errorType]
+
+	// Throttling rules based on error count and type
+	switch errorType {
+	case "pc-sc-context", "reader-error":
+		// Critical system errors: notify on 1st, 3rd, 5th, then every 10th
+		if count == 0 || count == 2 || count == 4 {
+			return true
+		}
+		if count >= 10 && count%10 == 0 {
+			return true
+		}
+		// Also notify if it's been more than 5 minutes since last notification
+		if now.Sub(lastNotification) > 5*time.Minute {
+			return true
+		}
+	case "card-error":
+		// Card errors: notify on 1st, then every 5th, or after 2 minutes
+		if count == 0 || count%5 == 0 {
+			return true
+		}
+		if now.Sub(lastNotification) > 2*time.Minute {
+			return true
+		}
+	case "service-error":
+		// Service errors: notify on 1st, 2nd, then every 5th
+		if count <= 1 || count%5 == 0 {
+			return true
+		}
+		if now.Sub(lastNotification) > 3*time.Minute {
+			return true
+		}
+	default:
+		// Other errors: notify on 1st, then every 3rd, or after 1 minute
+		if count == 0 || count%3 == 0 {
+			return true
+		}
+		if now.Sub(lastNotification) > 1*time.Minute {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordNotification remembers when an error notification was sent and draws the
+// jitter for the next throttle window of its type
+func (nm *NotificationManager) recordNotification(errorType string) {
+	nm.lastNotifications[errorType] = time.Now()
+	nm.jitters[errorType] = nm.drawThrottleJitter()
+}
+
+// drawThrottleJitter returns a random throttle window extension in [0, throttleJitter]
+func (nm *NotificationManager) drawThrottleJitter() time.Duration {
+	if nm.throttleJitter <= 0 {
+		return 0
+	}
+	return time.Duration(nm.randInt63n(int64(nm.throttleJitter) + 1))
+}
+
+// hasRecentErrors checks if there were any recent errors (for success notification logic)
+func (nm *NotificationManager) hasRecentErrors() bool {
+	for _, count := range nm.errorCounts {
+		if count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// clearErrorCounts resets all error counters (called on successful operation)
+func (nm *NotificationManager) clearErrorCounts() {
+	nm.errorCounts = make(map[string]int)
+}
+
+// AudioManager handles audio feedback for successful scans and errors
+type AudioManager struct {
+	enabled      bool
+	successSound string
+	errorSound   string
+	volume       int
+
+	mu         sync.Mutex // Guards the detected tools below, sounds play on background goroutines
+	player     string     // Audio player detected at startup (Linux), empty if none is available
+	pulseAudio bool       // PulseAudio (pactl) is available for the built-in beep (Linux)
+	beepTool   bool       // The beep command is available for built-in sounds (Linux)
+	pcSpeaker  bool       // Drive the PC speaker directly when no beep tool is available (Linux)
+
+	play func(sound string) // Plays a sound in the background, replaceable in tests
+}
+
+// lookPath finds executables, replaceable in tests
+var lookPath = exec.LookPath
+
+// linuxAudioPlayers lists the audio players tried on Linux, in order of preference
+var linuxAudioPlayers = []string{"mpg123", "ffplay", "paplay", "aplay"}
+
+// NewAudioManager creates a new audio manager
+func NewAudioManager(config *Config) *AudioManager {
+	am := &AudioManager{
+		enabled:      config.Audio.Enabled && audioSupported,
+		successSound: config.Audio.SuccessSound,
+		errorSound:   config.Audio.ErrorSound,
+		volume:       config.Audio.Volume,
+		pcSpeaker:    config.Audio.PCSpeaker,
+	}
+	am.play = am.playSound
+
+	if config.Audio.Enabled && !audioSupported {
+		fmt.Println("Audio feedback is not available in this build")
+	}
+
+	// Probe for audio tools once instead of on every played sound
+	if am.enabled && runtime.GOOS == "linux" {
+		if am.isSoundFile(am.successSound) || am.isSoundFile(am.errorSound) {
+			am.detectPlayer()
+		}
+		if am.isBuiltinSound(am.successSound) || am.isBuiltinSound(am.errorSound) {
+			am.detectBeepTools()
+		}
+	}
+
+	return am
+}
+
+// detectPlayer looks for an installed audio player and remembers it (Linux)
+func (am *AudioManager) detectPlayer() {
+	player := detectAudioPlayer()
+
+	am.mu.Lock()
+	am.player = player
+	am.mu.Unlock()
+
+	if player == "" {
+		log.Printf("No audio player found (tried %s), custom sound files will not be played", strings.Join(linuxAudioPlayers, ", "))
+	} else {
+		fmt.Printf("Using audio player: %s\n", player)
+	}
+}
+
+// detectBeepTools checks which tools are available for the built-in sounds (Linux)
+func (am *AudioManager) detectBeepTools() {
+	_, err := lookPath("pactl")
+	pulseAudio := err == nil && exec.Command("pactl", "list", "short", "modules").Run() == nil
+	_, err = lookPath("beep")
+	beepTool := err == nil
+
+	am.mu.Lock()
+	am.pulseAudio = pulseAudio
+	am.beepTool = beepTool
+	am.mu.Unlock()
+}
+
+// beepTools returns the detected tools for the built-in sounds
+func (am *AudioManager) beepTools() (pulseAudio, beepTool bool) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return am.pulseAudio, am.beepTool
+}
+
+// playPCSpeaker sounds the PC speaker if enabled and reports whether it did.
+// It is switched off after the first failure, usually missing console permissions.
+func (am *AudioManager) playPCSpeaker(frequency int, duration time.Duration) bool {
+	am.mu.Lock()
+	enabled := am.pcSpeaker
+	am.mu.Unlock()
+	if !enabled {
+		return false
+	}
+
+	if err := pcSpeakerBeep(frequency, duration); err != nil {
+		log.Printf("PC speaker not available: %v, falling back to terminal bell", err)
+		am.mu.Lock()
+		am.pcSpeaker = false
+		am.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// audioPlayer returns the detected audio player
+func (am *AudioManager) audioPlayer() string {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return am.player
+}
+
+// isSoundFile checks if the configured sound refers to a file rather than a built-in sound
+func (am *AudioManager) isSoundFile(sound string) bool {
+	switch sound {
+	case "beep", "error", "none", "":
+		return false
+	}
+	return true
+}
+
+// isBuiltinSound checks if the configured sound is one of the built-in system sounds
+func (am *AudioManager) isBuiltinSound(sound string) bool {
+	return sound == "beep" || sound == "error"
+}
+
+// detectAudioPlayer returns the first installed Linux audio player, or an empty string if there is none
+func detectAudioPlayer() string {
+	for _, player := range linuxAudioPlayers {
+		if _, err := lookPath(player); err == nil {
+			return player
+		}
+	}
+	return ""
+}
+
+// PlaySuccessSound plays the configured success sound
+func (am *AudioManager) PlaySuccessSound() {
+	if !am.enabled {
+		return
+	}
+
+	go am.play(am.successSound)
+}
+
+// PlayErrorSound plays the configured error sound
+func (am *AudioManager) PlayErrorSound() {
+	if !am.enabled {
+		return
+	}
+
+	go am.play(am.errorSound)
+}
+
+// PlayTestSounds synchronously plays the success and error sounds regardless of the enabled setting
+func (am *AudioManager) PlayTestSounds() {
+	fmt.Printf("Success sound: %s\n", am.successSound)
+	am.playSound(am.successSound)
+
+	time.Sleep(500 * time.Millisecond)
+
+	fmt.Printf("Error sound: %s\n", am.errorSound)
+	am.playSound(am.errorSound)
+}
+
+// playSound plays the specified sound
+func (am *AudioManager) playSound(soundType string) {
+	switch soundType {
+	case "beep":
+		am.playSystemBeep()
+	case "error":
+		am.playSystemError()
+	case "none", "":
+		// No sound
+		return
+	default:
+		// Assume it's a file path
+		am.playAudioFile(soundType)
+	}
+}
+
+// playSystemBeep plays a system beep sound
+func (am *AudioManager) playSystemBeep() {
+	switch runtime.GOOS {
+	case "windows":
+		// Windows system beep
+		exec.Command("rundll32", "user32.dll,MessageBeep", "64").Run()
+	case "darwin":
+		// macOS system beep
+		exec.Command("afplay", "/System/Library/Sounds/Ping.aiff").Run()
+	case "linux":
+		// Linux system beep - use the tools detected at startup
+		pulseAudio, beepTool := am.beepTools()
+		if pulseAudio {
+			// PulseAudio available
+			exec.Command("pactl", "upload-sample", "/usr/share/sounds/freedesktop/stereo/complete.oga", "beep").Run()
+			if err := exec.Command("pactl", "play-sample", "beep").Run(); err != nil {
+				log.Printf("Failed to play beep via PulseAudio: %v, re-detecting audio tools", err)
+				am.detectBeepTools()
+			}
+		} else if beepTool {
+			// beep command available
+			if err := exec.Command("beep", "-f", "800", "-l", "200").Run(); err != nil {
+				log.Printf("Failed to play beep: %v, re-detecting audio tools", err)
+				am.detectBeepTools()
+			}
+		} else if !am.playPCSpeaker(800, 200*time.Millisecond) {
+			// Fallback to terminal bell
+			fmt.Print("\a")
+		}
+	default:
+		// Fallback to terminal bell
+		fmt.Print("\a")
+	}
+}
+
+// playSystemError plays a system error sound
+func (am *AudioManager) playSystemError() {
+	switch runtime.GOOS {
+	case "windows":
+		// Windows error sound
+		exec.Command("rundll32", "user32.dll,MessageBeep", "16").Run()
+	case "darwin":
+		// macOS error sound
+		exec.Command("afplay", "/System/Library/Sounds/Sosumi.aiff").Run()
+	case "linux":
+		// Linux error sound - lower pitch beeps
+		if _, beepTool := am.beepTools(); beepTool {
+			if err := exec.Command("beep", "-f", "300", "-l", "500").Run(); err != nil {
+				log.Printf("Failed to play error beep: %v, re-detecting audio tools", err)
+				am.detectBeepTools()
+			}
+		} else if !am.playPCSpeaker(300, 500*time.Millisecond) {
+			// Multiple terminal bells for error
+			fmt.Print("\a\a")
+		}
+	default:
+		// Fallback to double terminal bell
+		fmt.Print("\a\a")
+	}
+}
+
+// playAudioFile plays an audio file from the specified path
+func (am *AudioManager) playAudioFile(filePath string) {
+	// Check if file exists
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		log.Printf("Audio file not found: %s", filePath)
+		return
+	}
+
+	// Check if it's an MP3 file - use go-mp3 for validation
+	if strings.HasSuffix(strings.ToLower(filePath), ".mp3") {
+		am.playMP3File(filePath)
+		return
+	}
+
+	// For other file types, use system players directly
+	am.playWithSystemPlayer(filePath)
+}
+
+// playMP3File plays an MP3 file using go-mp3 library
+func (am *AudioManager) playMP3File(filePath string) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		log.Printf("Failed to open MP3 file %s: %v", filePath, err)
+		return
+	}
+	defer f.Close()
+
+	// Verify it's a valid MP3 file
+	_, err = mp3.NewDecoder(f)
+	if err != nil {
+		log.Printf("Failed to create MP3 decoder for %s: %v", filePath, err)
+		// Fall back to system player
+		am.playWithSystemPlayer(filePath)
+		return
+	}
+
+	// Use system player for actual playback since oto has dependency issues
+	am.playWithSystemPlayer(filePath)
+}
+
+// playWithSystemPlayer plays audio files using system players
+func (am *AudioManager) playWithSystemPlayer(filePath string) {
+	switch runtime.GOOS {
+	case "windows":
+		// On Windows, use PowerShell with MediaPlayer
+		cmd := exec.Command("powershell", "-c", fmt.Sprintf(`
+			Add-Type -AssemblyName presentationCore
+			$mediaPlayer = New-Object system.windows.media.mediaplayer
+			$mediaPlayer.open('%s')
+			$mediaPlayer.Play()
+			Start-Sleep -Seconds 2
+		`, filePath))
+		cmd.Run()
+	case "darwin":
+		// macOS - use afplay
+		exec.Command("afplay", filePath).Run()
+	case "linux":
+		// Linux - use the audio player detected at startup
+		player := am.audioPlayer()
+		if player == "" {
+			// Already reported once at startup
+			return
+		}
+
+		var err error
+		if player == "ffplay" {
+			err = exec.Command(player, "-nodisp", "-autoexit", filePath).Run()
+		} else {
+			err = exec.Command(player, filePath).Run()
+		}
+		if err != nil {
+			log.Printf("Failed to play %s with %s: %v, re-detecting audio player", filePath, player, err)
+			am.detectPlayer()
+		}
+	default:
+		log.Printf("Audio file playback not supported on this platform: %s", filePath)
+	}
+}
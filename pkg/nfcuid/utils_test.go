@@ -0,0 +1,258 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAudioPlayerDetectedOnce(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("audio player detection only runs on Linux")
+	}
+	if !audioSupported {
+		t.Skip("audio is compiled out")
+	}
+
+	lookups := make(map[string]int)
+	originalLookPath := lookPath
+	lookPath = func(file string) (string, error) {
+		lookups[file]++
+		return "", errors.New("not found")
+	}
+	defer func() { lookPath = originalLookPath }()
+
+	soundFile := filepath.Join(t.TempDir(), "success.wav")
+	if err := os.WriteFile(soundFile, []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.Audio.Enabled = true
+	config.Audio.SuccessSound = soundFile
+	config.Audio.ErrorSound = "none"
+
+	am := NewAudioManager(config)
+	for i := 0; i < 3; i++ {
+		am.playSound(am.successSound)
+	}
+
+	for _, player := range linuxAudioPlayers {
+		if lookups[player] != 1 {
+			t.Errorf("Expected %s to be probed once, got %d", player, lookups[player])
+		}
+	}
+	if lookups["beep"] != 0 || lookups["pactl"] != 0 {
+		t.Errorf("Expected no beep tool probing when only sound files are configured, got %v", lookups)
+	}
+}
+
+func TestRestartManagerSelfRestartDisabled(t *testing.T) {
+	config := DefaultConfig()
+	config.Advanced.SelfRestart = false
+	config.Advanced.MaxContextFailures = 2
+	rm := NewRestartManager(config, nil, nil)
+
+	// performSelfRestart would exec a new process and exit, so reaching the end proves it wasn't called
+	for i := 0; i < 5; i++ {
+		if rm.TrackContextFailure(errors.New("context failure")) {
+			t.Fatalf("Expected no restart with self-restart disabled, failure %d triggered one", i+1)
+		}
+	}
+}
+
+func TestNotificationTemplates(t *testing.T) {
+	tests := []struct {
+		templates NotificationTemplates
+		kind      string
+		title     string
+		body      string
+		name      string
+	}{
+		{NotificationTemplates{}, "success", "NFC Karten-Lesung erfolgreich", "Card UID: 04a22b91", "success defaults"},
+		{NotificationTemplates{SuccessTitle: "ACME Zutritt", SuccessBody: "{uid} an {device}"}, "success", "ACME Zutritt", "04a22b91 an Reader 0", "success placeholders"},
+		{NotificationTemplates{}, "error", "NFC Reader-Fehler (x2)", "Karte defekt", "error defaults with count"},
+		{NotificationTemplates{ErrorTitle: "ACME: {device}", ErrorBody: "Fehler: {message}"}, "error", "ACME: Reader 1 (x2)", "Fehler: Karte defekt", "error placeholders"},
+		{NotificationTemplates{}, "info", "Hinweis", "Update verfügbar", "info defaults"},
+		{NotificationTemplates{InfoTitle: "ACME {title}"}, "info", "ACME Hinweis", "Update verfügbar", "info title placeholder"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Notifications.Templates = test.templates
+			nm := NewNotificationManager(config)
+			nm.SetDevice("Reader 1")
+
+			var title, body string
+			switch test.kind {
+			case "success":
+				title, body = nm.successTexts("04a22b91", "Reader 0")
+			case "error":
+				title, body = nm.errorTexts(defaultErrorTitle, "Karte defekt", 2)
+			case "info":
+				title, body = nm.infoTexts("Hinweis", "Update verfügbar")
+			}
+
+			if title != test.title {
+				t.Errorf("Expected title %q, got %q", test.title, title)
+			}
+			if body != test.body {
+				t.Errorf("Expected body %q, got %q", test.body, body)
+			}
+		})
+	}
+}
+
+func TestNotificationDeviceConcurrent(t *testing.T) {
+	nm := NewNotificationManager(DefaultConfig())
+
+	// ChangeDevice sets the device while the reading loop builds notification texts,
+	// run with -race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			nm.SetDevice(fmt.Sprintf("Reader %d", i))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		nm.infoTexts("Hinweis", "Gerät gewechselt")
+	}
+	<-done
+
+	if device := nm.currentDevice(); device != "Reader 99" {
+		t.Errorf("Expected the last device set, got %q", device)
+	}
+}
+
+func TestThrottleJitterBounded(t *testing.T) {
+	tests := []struct {
+		jitter int
+		name   string
+	}{
+		{0, "disabled"},
+		{1, "one second"},
+		{30, "thirty seconds"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Notifications.ThrottleJitter = test.jitter
+			nm := NewNotificationManager(config)
+
+			limit := time.Duration(test.jitter) * time.Second
+			for i := 0; i < 1000; i++ {
+				if jitter := nm.drawThrottleJitter(); jitter < 0 || jitter > limit {
+					t.Fatalf("Jitter %v outside [0, %v]", jitter, limit)
+				}
+			}
+		})
+	}
+}
+
+func TestShouldNotifyErrorJitter(t *testing.T) {
+	tests := []struct {
+		elapsed time.Duration
+		notify  bool
+		name    string
+	}{
+		{2*time.Minute + 10*time.Second, false, "inside jittered window"},
+		{2*time.Minute + 31*time.Second, true, "after jittered window"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Notifications.ThrottleJitter = 30
+			nm := NewNotificationManager(config)
+			nm.randInt63n = func(n int64) int64 { return n - 1 } // Maximum jitter
+
+			nm.recordNotification("card-error")
+			nm.errorCounts["card-error"] = 1
+			now := nm.lastNotifications["card-error"].Add(test.elapsed)
+
+			if notify := nm.shouldNotifyErrorAt("card-error", now); notify != test.notify {
+				t.Errorf("Expected notify %v, got %v", test.notify, notify)
+			}
+		})
+	}
+}
+
+func TestShouldNotifyErrorCategories(t *testing.T) {
+	tests := []struct {
+		categories map[string]bool
+		errorType  string
+		notify     bool
+		name       string
+	}{
+		{nil, "card-error", true, "default on"},
+		{map[string]bool{"card-error": false}, "card-error", false, "category off"},
+		{map[string]bool{"card-error": false}, "card-mute", false, "finer type off"},
+		{map[string]bool{"card-error": false}, "pc-sc-context", true, "other category on"},
+		{map[string]bool{"service-error": false}, "watchdog", false, "watchdog off"},
+		{map[string]bool{"reader-error": false}, "reader-fallback", false, "reader fallback off"},
+		{map[string]bool{"general-error": true}, "update-check-error", true, "explicitly on"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Notifications.Categories = test.categories
+			nm := NewNotificationManager(config)
+
+			// Every count notifies without the switch: the first occurrence, then
+			// windows long expired
+			for count := 0; count < 12; count++ {
+				nm.errorCounts[test.errorType] = count
+				if count > 0 {
+					nm.lastNotifications[test.errorType] = time.Now().Add(-time.Hour)
+				}
+				if notify := nm.shouldNotifyError(test.errorType, "Fehler"); notify != test.notify {
+					t.Fatalf("Expected notify %v at count %d, got %v", test.notify, count, notify)
+				}
+			}
+		})
+	}
+}
+
+func TestRestartManagerSupervisedExit(t *testing.T) {
+	config := DefaultConfig()
+	config.Advanced.RestartMode = "supervised"
+	config.Advanced.SupervisedExit = 42
+	config.Advanced.MaxContextFailures = 2
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	rm := NewRestartManager(config, nil, nil)
+	var codes []int
+	rm.exit = func(code int) { codes = append(codes, code) }
+
+	// Starting a new process would exit the test binary, so returning here proves it wasn't exec'd
+	if rm.TrackContextFailure(errors.New("context failure")) {
+		t.Fatalf("Expected no restart before the failure limit")
+	}
+	if !rm.TrackContextFailure(errors.New("context failure")) {
+		t.Fatalf("Expected a restart at the failure limit")
+	}
+	if len(codes) != 1 || codes[0] != 42 {
+		t.Errorf("Expected exit code 42, got %v", codes)
+	}
+
+	for _, code := range []int{0, 1, 256} {
+		config.Advanced.SupervisedExit = code
+		if err := validateConfig(config); err == nil {
+			t.Errorf("Expected supervised exit code %d to be rejected", code)
+		}
+	}
+	config.Advanced.SupervisedExit = 75
+	config.Advanced.RestartMode = "systemd"
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected an unknown restart mode to be rejected")
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package nfcuid
 
 // Version represents the current version of the application
 const Version = "1.2.3"
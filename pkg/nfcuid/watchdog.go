@@ -0,0 +1,176 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// errWatchdogRestart ends the card reading loop after the watchdog found it unresponsive,
+// so the service loop reconnects with a fresh PC/SC context
+var errWatchdogRestart = errors.New("card reading loop unresponsive, restarted by watchdog")
+
+// minWatchdogSeconds is the smallest accepted watchdog threshold
+const minWatchdogSeconds = 10
+
+// stopsReadingLoop reports whether the error ends the card reading loop without retrying
+func stopsReadingLoop(err error) bool {
+	return errors.Is(err, errShutdownRequested) || errors.Is(err, errWatchdogRestart)
+}
+
+// watchdogThreshold is how long the reading loop may go without a heartbeat, 0 if the watchdog is disabled
+func (s *service) watchdogThreshold() time.Duration {
+	return time.Duration(s.config.Advanced.WatchdogSeconds) * time.Second
+}
+
+// pollTimeout is the timeout of the card waits: infinite without the watchdog, otherwise
+// a quarter of its threshold so an idle loop still sends heartbeats
+func (s *service) pollTimeout() time.Duration {
+	if s.watchdogThreshold() == 0 {
+		return -1
+	}
+	return s.watchdogThreshold() / 4
+}
+
+// heartbeat tells the watchdog that the reading loop is alive. Outside the loop, e.g.
+// for a Repeat from another goroutine, it does nothing.
+func (s *service) heartbeat() {
+	now := time.Now().UnixNano()
+	for {
+		aliveAt := s.aliveAt.Load()
+		if aliveAt == 0 || s.aliveAt.CompareAndSwap(aliveAt, now) {
+			return
+		}
+	}
+}
+
+// whileAlive runs fn with heartbeats every poll timeout, for steps of the reading loop
+// that may take longer than the watchdog threshold without hanging in PC/SC, like typing
+// a long output with a slow type profile
+func (s *service) whileAlive(fn func()) {
+	s.heartbeat()
+	defer s.heartbeat()
+	if s.watchdogThreshold() == 0 {
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.pollTimeout())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.heartbeat()
+			case <-done:
+				return
+			}
+		}
+	}()
+	fn()
+	close(done)
+}
+
+// setCancelWait sets the function that unblocks a pending card wait, nil outside the service loop
+func (s *service) setCancelWait(cancel func() error) {
+	s.watchdogMu.Lock()
+	defer s.watchdogMu.Unlock()
+	s.cancelWait = cancel
+}
+
+// waitForStatusChange waits until a reader state changes, polling with the poll timeout
+// and sending a heartbeat after each poll
+func (s *service) waitForStatusChange(ctx cardContext, rs []scard.ReaderState) error {
+	return s.waitForStatusChangeEvery(ctx, rs, 0, nil)
+}
+
+// waitForStatusChangeEvery is waitForStatusChange calling tick every interval while no
+// state changes, a nil tick is never called
+func (s *service) waitForStatusChangeEvery(ctx cardContext, rs []scard.ReaderState, interval time.Duration, tick func()) error {
+	nextTick := time.Now().Add(interval)
+	for {
+		timeout := s.pollTimeout()
+		if tick != nil {
+			untilTick := time.Until(nextTick)
+			if untilTick < 0 {
+				untilTick = 0
+			}
+			if timeout < 0 || untilTick < timeout {
+				timeout = untilTick
+			}
+		}
+
+		err := ctx.GetStatusChange(rs, timeout)
+		s.heartbeat()
+		if err == nil || err == scard.ErrTimeout {
+			// The loop recovered by itself from whatever the watchdog tried to restart, a
+			// later error of the wait is not the watchdog's cancel
+			s.watchdogTripped.Store(false)
+		}
+		if err != scard.ErrTimeout {
+			return err
+		}
+		if tick != nil && !time.Now().Before(nextTick) {
+			tick()
+			nextTick = time.Now().Add(interval)
+		}
+	}
+}
+
+// monitorWatchdog periodically checks that the reading loop sends heartbeats
+func (s *service) monitorWatchdog() {
+	ticker := time.NewTicker(s.pollTimeout())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.checkWatchdog(now)
+		case <-s.shutdown.Done():
+			return
+		}
+	}
+}
+
+// checkWatchdog restarts the reading loop when its last heartbeat is older than the
+// threshold, by cancelling the pending PC/SC wait. When that didn't end the loop within
+// another threshold, e.g. because it hangs in Connect or Transmit which the cancel can't
+// interrupt, the hang is counted as a PC/SC failure so the RestartManager escalates.
+func (s *service) checkWatchdog(now time.Time) {
+	aliveAt := s.aliveAt.Load()
+	if aliveAt == 0 {
+		// Not in the reading loop, e.g. reconnecting or at the device prompt
+		return
+	}
+
+	stale := now.Sub(time.Unix(0, aliveAt))
+	if stale < s.watchdogThreshold() {
+		return
+	}
+
+	message := fmt.Sprintf("card reading loop unresponsive for %v", stale.Round(time.Second))
+	escalate := s.watchdogTripped.Load()
+	if escalate {
+		fmt.Printf("Watchdog: %s, cancelling the wait didn't restart it\n", message)
+	} else {
+		fmt.Printf("Watchdog: %s, restarting it\n", message)
+	}
+	s.notificationManager.NotifyErrorThrottled("watchdog", "NFC-Kartenlesen reagiert nicht mehr und wird neu gestartet.")
+	s.recordError("", message)
+	if escalate {
+		s.restartManager.TrackSystemFailure("Card Reading Loop", errors.New(message))
+	}
+
+	// Give the restart a full threshold before reporting again
+	s.aliveAt.Store(now.UnixNano())
+	s.watchdogTripped.Store(true)
+
+	s.watchdogMu.Lock()
+	defer s.watchdogMu.Unlock()
+	if s.cancelWait != nil {
+		s.cancelWait()
+	}
+}
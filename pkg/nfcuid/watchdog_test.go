@@ -0,0 +1,133 @@
+package nfcuid
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// stalledContext is a reader whose status wait never returns until it is cancelled
+type stalledContext struct {
+	*mockContext
+	cancelled chan struct{}
+}
+
+func (c *stalledContext) GetStatusChange(readerStates []scard.ReaderState, timeout time.Duration) error {
+	<-c.cancelled
+	return scard.ErrCancelled
+}
+
+func TestWatchdogRestartsStalledLoop(t *testing.T) {
+	ctx := &stalledContext{
+		mockContext: &mockContext{readers: []string{"Reader 0"}},
+		cancelled:   make(chan struct{}),
+	}
+	config := DefaultConfig()
+	config.Advanced.WatchdogSeconds = 10
+	config.Advanced.RetryAttempts = 3
+	s := newTestService(config)
+	s.setCancelWait(func() error {
+		close(ctx.cancelled)
+		return nil
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		result <- s.cardReadingLoop(ctx, ctx.readers, &mockKeyboard{})
+	}()
+
+	// A fresh heartbeat keeps the loop running
+	time.Sleep(50 * time.Millisecond)
+	s.checkWatchdog(time.Now())
+	select {
+	case err := <-result:
+		t.Fatalf("Expected the loop to keep waiting, it returned %v", err)
+	default:
+	}
+
+	// Past the threshold the wait is cancelled and the loop ends for a restart
+	s.checkWatchdog(time.Now().Add(time.Minute))
+	select {
+	case err := <-result:
+		if !errors.Is(err, errWatchdogRestart) {
+			t.Errorf("Expected errWatchdogRestart, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the stalled loop to be restarted")
+	}
+
+	if alive := s.aliveAt.Load(); alive != 0 {
+		t.Errorf("Expected no heartbeat outside the loop, got %d", alive)
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	config := DefaultConfig()
+	s := newTestService(config)
+	if timeout := s.pollTimeout(); timeout != -1 {
+		t.Errorf("Expected an infinite wait without watchdog, got %v", timeout)
+	}
+
+	config.Advanced.WatchdogSeconds = 20
+	if timeout := s.pollTimeout(); timeout != 5*time.Second {
+		t.Errorf("Expected a 5s poll for a 20s watchdog, got %v", timeout)
+	}
+}
+
+func TestWatchdogEscalatesHungLoop(t *testing.T) {
+	config := DefaultConfig()
+	config.Advanced.WatchdogSeconds = 10
+	config.Advanced.MaxContextFailures = 5
+	s := newTestService(config)
+	cancels := 0
+	s.setCancelWait(func() error {
+		cancels++
+		return nil
+	})
+	s.aliveAt.Store(time.Now().UnixNano())
+
+	// The first stale check cancels the wait, the loop hangs elsewhere and doesn't end
+	now := time.Now().Add(time.Minute)
+	s.checkWatchdog(now)
+	if cancels != 1 || s.restartManager.contextFailureCount != 0 {
+		t.Fatalf("Expected a cancel without a failure, got %d cancels and %d failures", cancels, s.restartManager.contextFailureCount)
+	}
+
+	// Still stale a threshold later: the hang counts towards the restart
+	s.checkWatchdog(now.Add(time.Minute))
+	if s.restartManager.contextFailureCount != 1 {
+		t.Errorf("Expected the hang to be counted as a failure, got %d", s.restartManager.contextFailureCount)
+	}
+}
+
+func TestWatchdogTripClearedBySuccessfulWait(t *testing.T) {
+	ctx := &mockContext{
+		readers: []string{"Reader 0"},
+		states:  map[string][]scard.StateFlag{"Reader 0": {scard.StateEmpty}},
+	}
+	s := newTestService(DefaultConfig())
+	s.watchdogTripped.Store(true)
+
+	// The first wait succeeds with an empty reader, the second fails on its own
+	_, _, err := s.waitUntilCardPresent(ctx, ctx.readers)
+	if err == nil || errors.Is(err, errWatchdogRestart) {
+		t.Errorf("Expected the wait error instead of a watchdog restart, got %v", err)
+	}
+	if s.watchdogTripped.Load() {
+		t.Error("Expected a successful wait to clear the watchdog trip")
+	}
+}
+
+func TestHeartbeatOutsideLoop(t *testing.T) {
+	config := DefaultConfig()
+	config.Advanced.WatchdogSeconds = 10
+	s := newTestService(config)
+
+	// A Repeat typing outside the loop must not make the watchdog watch it
+	s.whileAlive(func() {})
+	if alive := s.aliveAt.Load(); alive != 0 {
+		t.Errorf("Expected no heartbeat outside the loop, got %d", alive)
+	}
+}
@@ -0,0 +1,33 @@
+package nfcuid
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// WiegandFormat types the UID as a fixed width Wiegand number, the facility code
+// followed by the card number, both zero padded to the widest value of their bit
+// length. The standard 26-bit format (8 facility bits, 16 card bits) gives FFFCCCCC.
+type WiegandFormat struct {
+	FacilityCode uint32
+	FacilityBits int
+	CardBits     int
+}
+
+// CardNumber returns the card number carried in the Wiegand frame: the low CardBits
+// of the UID read as little-endian number, the same byte order as the decimal output.
+// Only the first 4 bytes are used, so 7 byte UIDs work as well.
+func (w *WiegandFormat) CardNumber(uid []byte) uint32 {
+	var number uint64
+	for i := 0; i < len(uid) && i < 4; i++ {
+		number |= uint64(uid[i]) << (8 * i)
+	}
+	return uint32(number & (1<<w.CardBits - 1))
+}
+
+// Format returns the facility code and card number as one fixed width digit string
+func (w *WiegandFormat) Format(uid []byte) string {
+	facilityWidth := len(strconv.FormatUint(1<<w.FacilityBits-1, 10))
+	cardWidth := len(strconv.FormatUint(1<<w.CardBits-1, 10))
+	return fmt.Sprintf("%0*d%0*d", facilityWidth, w.FacilityCode, cardWidth, w.CardNumber(uid))
+}
@@ -0,0 +1,75 @@
+package nfcuid
+
+import "testing"
+
+func TestWiegandFormat(t *testing.T) {
+	tests := []struct {
+		uid          []byte
+		facilityCode uint32
+		facilityBits int
+		cardBits     int
+		expected     string
+		name         string
+	}{
+		// 26-bit H10301: facility 0-255 (3 digits), card 0-65535 (5 digits)
+		{[]byte{0x6e, 0xb2, 0x00, 0x00}, 123, 8, 16, "12345678", "26-bit facility 123 card 45678"},
+		{[]byte{0x01, 0x00, 0x00, 0x00}, 1, 8, 16, "00100001", "26-bit padded"},
+		{[]byte{0xff, 0xff, 0x00, 0x00}, 255, 8, 16, "25565535", "26-bit maximum"},
+		{[]byte{0x6e, 0xb2, 0x2b, 0x91}, 123, 8, 16, "12345678", "26-bit drops the high UID bytes"},
+		// 37-bit H10304: facility 0-65535 (5 digits), card 0-524287 (6 digits)
+		{[]byte{0x6e, 0xb2, 0x07, 0x00}, 4660, 16, 19, "04660504430", "37-bit H10304"},
+		// 7 byte UIDs use the first 4 bytes
+		{[]byte{0x04, 0xa2, 0x2b, 0x91, 0x90, 0x00, 0x01}, 10, 8, 16, "01041476", "7 byte UID"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wiegand := &WiegandFormat{FacilityCode: test.facilityCode, FacilityBits: test.facilityBits, CardBits: test.cardBits}
+			if result := wiegand.Format(test.uid); result != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestValidateConfigWiegand(t *testing.T) {
+	tests := []struct {
+		facilityCode int
+		facilityBits int
+		cardBits     int
+		isValid      bool
+		name         string
+	}{
+		{123, 8, 16, true, "26-bit"},
+		{65535, 16, 19, true, "37-bit"},
+		{256, 8, 16, false, "facility code out of range"},
+		{-1, 8, 16, false, "negative facility code"},
+		{0, 0, 16, false, "no facility bits"},
+		{0, 8, 33, false, "too many card bits"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.Wiegand.Enabled = true
+			config.NFC.Wiegand.FacilityCode = test.facilityCode
+			config.NFC.Wiegand.FacilityBits = test.facilityBits
+			config.NFC.Wiegand.CardBits = test.cardBits
+			if err := validateConfig(config); test.isValid != (err == nil) {
+				t.Errorf("Expected valid=%v, got error: %v", test.isValid, err)
+			}
+		})
+	}
+}
+
+func TestFormatOutputWiegand(t *testing.T) {
+	config := DefaultConfig()
+	config.NFC.Wiegand.Enabled = true
+	config.NFC.Wiegand.FacilityCode = 123
+	config.NFC.EndChar = "enter"
+	s := newTestService(config)
+
+	if result := s.formatOutput([]byte{0x6e, 0xb2, 0x00, 0x00}, "Reader 0"); result != "12345678\\n" {
+		t.Errorf("Expected the Wiegand number with end char, got %q", result)
+	}
+}
@@ -0,0 +1,113 @@
+package nfcuid
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// writeErrorActions lists the nfc.on_write_error options for a typing error partway
+// through the output: keep leaves the typed part, erase removes it with backspaces and
+// retry erases it and types the output once more
+var writeErrorActions = []string{"keep", "erase", "retry"}
+
+// IsValidWriteErrorAction reports whether action is one of writeErrorActions
+func IsValidWriteErrorAction(action string) bool {
+	for _, option := range writeErrorActions {
+		if option == action {
+			return true
+		}
+	}
+	return false
+}
+
+// typedKey is the kind of a key typed by KeyboardWrite, as far as erasing it is concerned
+type typedKey int
+
+const (
+	keyChar      typedKey = iota // A character, one backspace erases it
+	keyBackspace                 // Erases the last typed character
+	keySelectAll                 // Select all, the next character replaces the selection
+	keyLeave                     // Enter, Tab or Escape, the output may be submitted or in another field
+)
+
+// writeProgress tracks what KeyboardWrite typed so far
+type writeProgress struct {
+	typed int  // Characters typed since the field was last left or selected, backspaces can erase them
+	left  bool // Enter, Tab or Escape was typed
+}
+
+// record counts a key that was typed
+func (p *writeProgress) record(key typedKey) {
+	switch key {
+	case keyChar:
+		p.typed++
+	case keyBackspace:
+		if p.typed > 0 {
+			p.typed--
+		}
+	case keySelectAll:
+		p.typed = 0
+	case keyLeave:
+		p.typed = 0
+		p.left = true
+	}
+}
+
+// fail returns the typing error with what was typed before it
+func (p *writeProgress) fail(err error) error {
+	return &partialWriteError{err: err, writeProgress: *p}
+}
+
+// partialWriteError is a typing error of KeyboardWrite, telling what was typed before it
+type partialWriteError struct {
+	writeProgress
+	err error
+}
+
+// Error returns the typing error
+func (e *partialWriteError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the typing error
+func (e *partialWriteError) Unwrap() error {
+	return e.err
+}
+
+// writeKeys types keys that aren't card output, e.g. the release keys, holding the
+// typing lock so they can't interleave with a Repeat
+func (s *service) writeKeys(keys string, kb keyboard) error {
+	s.typingMu.Lock()
+	defer s.typingMu.Unlock()
+	return KeyboardWrite(keys, kb, s.keyPacer)
+}
+
+// writeOutput types the output, handling a typing error partway through as set by
+// nfc.on_write_error. The typed part is erased with backspaces so no partial UID stays
+// in the field, a retry only follows if no Enter, Tab or Escape was typed before the
+// error, since the output may have been submitted already.
+func (s *service) writeOutput(output string, kb keyboard) (CapsLockReport, error) {
+	s.typingMu.Lock()
+	defer s.typingMu.Unlock()
+
+	report, err := keyboardWriteReport(output, kb, s.keyPacer)
+	var partial *partialWriteError
+	if err == nil || s.config.NFC.OnWriteError == "keep" || !errors.As(err, &partial) {
+		return report, err
+	}
+
+	if partial.typed > 0 {
+		log.Printf("Typing failed after %d characters, erasing them: %v", partial.typed, err)
+		if eraseErr := KeyboardWrite(strings.Repeat("\\b", partial.typed), kb, s.keyPacer); eraseErr != nil {
+			return report, fmt.Errorf("%v, erasing the typed characters failed too: %v", err, eraseErr)
+		}
+	}
+	if s.config.NFC.OnWriteError != "retry" || partial.left {
+		return report, err
+	}
+
+	log.Printf("Typing the output again after a typing error: %v", err)
+	return keyboardWriteReport(output, kb, s.keyPacer)
+}
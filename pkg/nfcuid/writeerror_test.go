@@ -0,0 +1,66 @@
+package nfcuid
+
+import (
+	"errors"
+	"testing"
+)
+
+// flakyKeyboard is a keyboard whose key press fails once, after the given number of key presses
+type flakyKeyboard struct {
+	mockKeyboard
+	failAfter int
+	presses   int
+}
+
+func (k *flakyKeyboard) Launching() error {
+	k.presses++
+	if k.presses == k.failAfter+1 {
+		return errors.New("focus lost")
+	}
+	return k.mockKeyboard.Launching()
+}
+
+func TestWriteOutputOnWriteError(t *testing.T) {
+	tests := []struct {
+		action    string
+		output    string
+		failAfter int
+		expected  string
+		fails     bool
+		name      string
+	}{
+		{"keep", "04a22b91\\n", 3, "04a", true, "keep partial"},
+		{"erase", "04a22b91\\n", 3, "04a\b\b\b", true, "erase partial"},
+		{"retry", "04a22b91\\n", 3, "04a\b\b\b04a22b91\n", false, "retry after erase"},
+		{"retry", "04a22b91\\n", 0, "04a22b91\n", false, "retry without typed characters"},
+		{"retry", "\\a\\b04a22b91\\n", 4, "^a\b04\b\b^a\b04a22b91\n", false, "retry after clear prefix"},
+		{"retry", "04\\t22b91\\n", 5, "04\t22\b\b", true, "no retry after tab"},
+		{"erase", "04\\b22\\n", 5, "04\b22\b\b\b", true, "erase counts backspaces"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.NFC.OnWriteError = test.action
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			s := newTestService(config)
+			kb := &flakyKeyboard{failAfter: test.failAfter}
+
+			_, err := s.writeOutput(test.output, kb)
+			if (err != nil) != test.fails {
+				t.Errorf("Expected failure %v, got %v", test.fails, err)
+			}
+			if kb.text() != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, kb.text())
+			}
+		})
+	}
+
+	config := DefaultConfig()
+	config.NFC.OnWriteError = "undo"
+	if err := validateConfig(config); err == nil {
+		t.Errorf("Expected an unknown on_write_error to be rejected")
+	}
+}